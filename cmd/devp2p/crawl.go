@@ -17,16 +17,24 @@
 package main
 
 import (
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"peerInfoCollect/log"
 	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/record"
+	"gopkg.in/urfave/cli.v1"
 )
 
 type crawler struct {
 	input     nodeSet
 	output    nodeSet
-	disc      resolver
+	protocols []discProtocol
 	iters     []enode.Iterator
 	inputIter enode.Iterator
 	ch        chan *enode.Node
@@ -34,21 +42,37 @@ type crawler struct {
 
 	// settings
 	revalidateInterval time.Duration
+	checkpointInterval time.Duration // daemon mode only
+	checkpoint         func(nodeSet) // called with the output set every checkpointInterval
+
+	// RLPx dialing of validated nodes, enabled by setting prober.
+	prober     *rlpxProber
+	probeQueue []*enode.Node
+	probeSet   map[enode.ID]bool // nodes queued or being dialed
 }
 
 type resolver interface {
 	RequestENR(*enode.Node) (*enode.Node, error)
 }
 
-func newCrawler(input nodeSet, disc resolver, iters ...enode.Iterator) *crawler {
+// discProtocol is a discovery protocol nodes are validated on.
+type discProtocol struct {
+	name string // recorded in the node set, e.g. discv4
+	disc resolver
+}
+
+// newCrawler creates a crawler validating the nodes found by the iterators on
+// each of the given discovery protocols.
+func newCrawler(input nodeSet, protocols []discProtocol, iters ...enode.Iterator) *crawler {
 	c := &crawler{
 		input:     input,
 		output:    make(nodeSet, len(input)),
-		disc:      disc,
+		protocols: protocols,
 		iters:     iters,
 		inputIter: enode.IterNodes(input.nodes()),
 		ch:        make(chan *enode.Node),
 		closed:    make(chan struct{}),
+		probeSet:  make(map[enode.ID]bool),
 	}
 	c.iters = append(c.iters, c.inputIter)
 	// Copy input to output initially. Any nodes that fail validation
@@ -60,22 +84,64 @@ func newCrawler(input nodeSet, disc resolver, iters ...enode.Iterator) *crawler
 }
 
 func (c *crawler) run(timeout time.Duration) nodeSet {
+	return c.loop(timeout, nil)
+}
+
+// runDaemon crawls until quit is closed. Unlike run, it keeps revalidating the
+// output set every revalidateInterval and hands it to checkpoint every
+// checkpointInterval.
+func (c *crawler) runDaemon(quit <-chan struct{}) nodeSet {
+	return c.loop(0, quit)
+}
+
+func (c *crawler) loop(timeout time.Duration, quit <-chan struct{}) nodeSet {
 	var (
 		timeoutTimer = time.NewTimer(timeout)
 		timeoutCh    <-chan time.Time
-		doneCh       = make(chan enode.Iterator, len(c.iters))
+		doneCh       = make(chan enode.Iterator, len(c.iters)+1)
 		liveIters    = len(c.iters)
+		daemon       = quit != nil
+
+		revalidateCh <-chan time.Time
+		checkpointCh <-chan time.Time
+		revalidator  enode.Iterator // iterator over the output set, daemon mode only
 	)
 	defer timeoutTimer.Stop()
 	for _, it := range c.iters {
 		go c.runIterator(doneCh, it)
 	}
+	var probed <-chan rlpxResult
+	if c.prober != nil {
+		c.prober.start(c.closed)
+		probed = c.prober.results
+	}
+	if daemon {
+		revalidateTicker := time.NewTicker(c.revalidateInterval)
+		defer revalidateTicker.Stop()
+		revalidateCh = revalidateTicker.C
+		if c.checkpoint != nil {
+			checkpointTicker := time.NewTicker(c.checkpointInterval)
+			defer checkpointTicker.Stop()
+			checkpointCh = checkpointTicker.C
+		}
+	}
 
 loop:
 	for {
+		var (
+			probeCh   chan<- *enode.Node
+			nextProbe *enode.Node
+		)
+		if len(c.probeQueue) > 0 {
+			probeCh, nextProbe = c.prober.queue, c.probeQueue[0]
+		}
 		select {
 		case n := <-c.ch:
 			c.updateNode(n)
+		case probeCh <- nextProbe:
+			c.probeQueue = c.probeQueue[1:]
+		case res := <-probed:
+			c.storeProbe(res)
 		case it := <-doneCh:
 			if it == c.inputIter {
 				// Enable timeout when we're done revalidating the input nodes.
@@ -84,11 +150,28 @@ loop:
 					timeoutCh = timeoutTimer.C
 				}
 			}
-			if liveIters--; liveIters == 0 {
-				break loop
+			if it == revalidator {
+				log.Info("Revalidation of node set is done", "len", len(c.output))
+				revalidator = nil
+			}
+			liveIters--
+		case <-revalidateCh:
+			// Recheck the known nodes, unless the previous round is still going.
+			if revalidator == nil {
+				revalidator = enode.IterNodes(c.output.nodes())
+				liveIters++
+				go c.runIterator(doneCh, revalidator)
 			}
+		case <-checkpointCh:
+			c.checkpoint(c.output)
 		case <-timeoutCh:
 			break loop
+		case <-quit:
+			break loop
+		}
+		// Stop once all iterators are done and every node was dialed.
+		if !daemon && liveIters == 0 && len(c.probeSet) == 0 {
+			break loop
 		}
 	}
 
@@ -96,9 +179,15 @@ loop:
 	for _, it := range c.iters {
 		it.Close()
 	}
+	if revalidator != nil {
+		revalidator.Close()
+	}
 	for ; liveIters > 0; liveIters-- {
 		<-doneCh
 	}
+	if c.prober != nil {
+		c.prober.wait()
+	}
 	return c.output
 }
 
@@ -121,34 +210,170 @@ func (c *crawler) updateNode(n *enode.Node) {
 		return
 	}
 
-	// Request the node record.
-	nn, err := c.disc.RequestENR(n)
+	// Request the node record on all protocols, keeping the newest one.
+	var nn *enode.Node
+	records := c.requestENR(n)
 	node.LastCheck = truncNow()
-	if err != nil {
+	for i, r := range records {
+		if r == nil {
+			continue
+		}
+		if node.Protocols == nil {
+			node.Protocols = make(map[string]time.Time)
+		}
+		node.Protocols[c.protocols[i].name] = node.LastCheck
+		if nn == nil || r.Seq() > nn.Seq() {
+			nn = r
+		}
+	}
+	if nn == nil {
 		if node.Score == 0 {
 			// Node doesn't implement EIP-868.
 			log.Debug("Skipping node", "id", n.ID())
 			return
 		}
 		node.Score /= 2
+		node.addCheck(node.LastCheck, false)
 	} else {
-		node.N = nn
-		node.Seq = nn.Seq()
+		if node.N == nil || nn.Seq() >= node.Seq {
+			node.N = nn
+			node.Seq = nn.Seq()
+		}
 		node.Score++
 		if node.FirstResponse.IsZero() {
 			node.FirstResponse = node.LastCheck
 		}
 		node.LastResponse = node.LastCheck
+		node.addCheck(node.LastCheck, true)
 	}
 
 	// Store/update node in output set.
 	if node.Score <= 0 {
 		log.Info("Removing node", "id", n.ID())
 		delete(c.output, n.ID())
+		if ok {
+			publishNodeEvent(record.NodeRemoved, node)
+		}
 	} else {
 		log.Info("Updating node", "id", n.ID(), "seq", n.Seq(), "score", node.Score)
 		c.output[n.ID()] = node
+		if !ok {
+			publishNodeEvent(record.NodeAdded, node)
+		}
+		if nn != nil {
+			c.queueProbe(node.N)
+		}
+	}
+}
+
+// requestENR requests the record of a node on every protocol at once. The
+// result holds the record returned on each protocol, nil where the node
+// didn't answer.
+func (c *crawler) requestENR(n *enode.Node) []*enode.Node {
+	records := make([]*enode.Node, len(c.protocols))
+	if len(c.protocols) == 1 {
+		records[0], _ = c.protocols[0].disc.RequestENR(n)
+		return records
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(c.protocols))
+	for i, p := range c.protocols {
+		go func(i int, p discProtocol) {
+			defer wg.Done()
+			records[i], _ = p.disc.RequestENR(n)
+		}(i, p)
+	}
+	wg.Wait()
+	return records
+}
+
+// publishNodeEvent publishes the addition or removal of a node to the record
+// sinks.
+func publishNodeEvent(event string, node nodeJSON) {
+	n := node.N
+	rec := &record.NodeEventRecordInfo{
+		PeerId:   n.ID().String(),
+		Enode:    n.URLv4(),
+		PeerAddr: (&net.UDPAddr{IP: n.IP(), Port: n.UDP()}).String(),
+		Seq:      n.Seq(),
+		Event:    event,
+		Score:    uint64(node.Score),
+		Time:     uint64(time.Now().UnixNano()),
+	}
+	if !node.FirstResponse.IsZero() {
+		rec.FirstResponse = uint64(node.FirstResponse.UnixNano())
+	}
+	if !node.LastResponse.IsZero() {
+		rec.LastResponse = uint64(node.LastResponse.UnixNano())
+	}
+	if err := record.Publish(rec); err != nil {
+		log.Debug("Failed to publish node event", "id", n.ID(), "err", err)
+	}
+}
+
+// queueProbe schedules an RLPx dial of a node that answered discovery.
+func (c *crawler) queueProbe(n *enode.Node) {
+	if c.prober == nil || n.TCP() == 0 || c.probeSet[n.ID()] {
+		return
+	}
+	c.probeSet[n.ID()] = true
+	c.probeQueue = append(c.probeQueue, n)
+}
+
+// storeProbe records the outcome of an RLPx dial in the output set.
+func (c *crawler) storeProbe(res rlpxResult) {
+	delete(c.probeSet, res.id)
+	node, ok := c.output[res.id]
+	if !ok {
+		return
+	}
+	node.RLPx = res.info
+	c.output[res.id] = node
+}
+
+// runCrawler runs a crawler configured on the command line and writes the
+// resulting set to nodesFile. In daemon mode, it crawls until interrupted,
+// checkpointing the set to nodesFile on the way.
+func runCrawler(ctx *cli.Context, c *crawler, nodesFile string) error {
+	if err := openRecordSinks(ctx); err != nil {
+		return err
+	}
+	defer record.Close()
+
+	if !ctx.Bool(crawlDaemonFlag.Name) {
+		writeNodesJSON(nodesFile, c.run(ctx.Duration(crawlTimeoutFlag.Name)))
+		return nil
+	}
+	c.checkpointInterval = ctx.Duration(crawlCheckpointFlag.Name)
+	c.checkpoint = func(nodes nodeSet) {
+		if err := writeNodesFile(nodesFile, nodes); err != nil {
+			log.Error("Failed to checkpoint node set", "file", nodesFile, "err", err)
+			return
+		}
+		log.Info("Checkpointed node set", "file", nodesFile, "len", len(nodes))
+	}
+	quit := make(chan struct{})
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+	go func() {
+		<-sigc
+		log.Info("Got interrupt, shutting down...")
+		close(quit)
+	}()
+	writeNodesJSON(nodesFile, c.runDaemon(quit))
+	return nil
+}
+
+// openRecordSinks installs the record sinks given on the command line.
+func openRecordSinks(ctx *cli.Context) error {
+	cfg := &record.Config{Encoding: ctx.String(recordEncodingFlag.Name)}
+	for _, url := range strings.Split(ctx.String(recordSinksFlag.Name), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			cfg.Sinks = append(cfg.Sinks, url)
+		}
 	}
+	return record.Open(cfg)
 }
 
 func truncNow() time.Time {
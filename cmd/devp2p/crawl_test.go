@@ -0,0 +1,83 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"net"
+	"testing"
+
+	"peerInfoCollect/crypto"
+	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/p2p/enr"
+)
+
+// testResolver answers ENR requests from a fixed set of records.
+type testResolver map[enode.ID]*enode.Node
+
+func (r testResolver) RequestENR(n *enode.Node) (*enode.Node, error) {
+	if rec, ok := r[n.ID()]; ok {
+		return rec, nil
+	}
+	return nil, errors.New("timeout")
+}
+
+func testNode(t *testing.T, key *ecdsa.PrivateKey, seq uint64) *enode.Node {
+	var r enr.Record
+	r.SetSeq(seq)
+	r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+	r.Set(enr.UDP(30303))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCrawlerProtocols(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyB, _ := crypto.GenerateKey()
+	keyC, _ := crypto.GenerateKey()
+	var (
+		a1, a2 = testNode(t, keyA, 1), testNode(t, keyA, 2)
+		b      = testNode(t, keyB, 1)
+		c      = testNode(t, keyC, 1)
+		v4     = testResolver{a1.ID(): a1, b.ID(): b}
+		v5     = testResolver{a2.ID(): a2}
+	)
+	protocols := []discProtocol{{name: "discv4", disc: v4}, {name: "discv5", disc: v5}}
+	cr := newCrawler(nil, protocols, enode.IterNodes([]*enode.Node{a1, b, c}))
+	output := cr.run(0)
+
+	if len(output) != 2 {
+		t.Fatalf("wrong output size %d", len(output))
+	}
+	if n := output[a1.ID()]; n.Seq != 2 || n.N.Seq() != 2 || len(n.Protocols) != 2 {
+		t.Errorf("wrong entry for node answering on both protocols: seq %d, protocols %v", n.Seq, n.Protocols)
+	}
+	if n := output[b.ID()]; len(n.Protocols) != 1 || n.Protocols["discv4"].IsZero() {
+		t.Errorf("wrong protocols for discv4 node: %v", n.Protocols)
+	}
+	counts := countProtocols(output)
+	if counts[""] != 1 || counts["discv4"] != 1 || counts["discv5"] != 0 {
+		t.Errorf("wrong protocol counts %v", counts)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/log"
+	"peerInfoCollect/p2p/discover"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	crawlCommand = cli.Command{
+		Name:      "crawl",
+		Usage:     "Updates a nodes.json file with random nodes found in the discv4 and discv5 DHTs",
+		ArgsUsage: "<nodes.json file>",
+		Action:    crawlNodes,
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			nodekeyFlag,
+			listenAddrFlag,
+			crawlTimeoutFlag,
+			crawlDaemonFlag,
+			crawlCheckpointFlag,
+			recordSinksFlag,
+			recordEncodingFlag,
+		},
+	}
+)
+
+// crawlProtocols are the discovery protocols run by the crawl command.
+var crawlProtocols = []string{"discv4", "discv5"}
+
+func crawlNodes(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need nodes file as argument")
+	}
+	nodesFile := ctx.Args().First()
+	var inputSet nodeSet
+	if common.FileExist(nodesFile) {
+		inputSet = loadNodesJSON(nodesFile)
+	}
+
+	disc4, disc5 := startDiscovery(ctx)
+	defer disc5.Close()
+	defer disc4.Close()
+	protocols := []discProtocol{
+		{name: crawlProtocols[0], disc: disc4},
+		{name: crawlProtocols[1], disc: disc5},
+	}
+	c := newCrawler(inputSet, protocols, disc4.RandomNodes(), disc5.RandomNodes())
+	c.revalidateInterval = 10 * time.Minute
+	if err := runCrawler(ctx, c, nodesFile); err != nil {
+		return err
+	}
+	reportProtocols(c.output)
+	return nil
+}
+
+// reportProtocols logs how many nodes answered on only one of the crawled
+// discovery protocols.
+func reportProtocols(ns nodeSet) {
+	counts := countProtocols(ns)
+	ctx := []interface{}{"nodes", len(ns), "both", counts[""]}
+	for _, name := range crawlProtocols {
+		ctx = append(ctx, name+"-only", counts[name])
+	}
+	log.Info("Discovery protocol coverage", ctx...)
+}
+
+// countProtocols counts the nodes answering on a single discovery protocol by
+// the name of that protocol. Nodes answering on several protocols are counted
+// under the empty name, nodes that never answered aren't counted.
+func countProtocols(ns nodeSet) map[string]int {
+	counts := make(map[string]int)
+	for _, n := range ns {
+		switch len(n.Protocols) {
+		case 0:
+		case 1:
+			for name := range n.Protocols {
+				counts[name]++
+			}
+		default:
+			counts[""]++
+		}
+	}
+	return counts
+}
+
+// startDiscovery starts ephemeral discovery v4 and v5 nodes sharing a socket
+// and node key. Packets not understood by v4 are handed to v5, the same way
+// p2p.Server runs both protocols.
+func startDiscovery(ctx *cli.Context) (*discover.UDPv4, *discover.UDPv5) {
+	ln, config := makeDiscoveryConfig(ctx)
+	socket := listen(ln, ctx.String(listenAddrFlag.Name))
+	unhandled := make(chan discover.ReadPacket, 100)
+
+	config.Unhandled = unhandled
+	disc4, err := discover.ListenV4(socket, ln, config)
+	if err != nil {
+		exit(err)
+	}
+	config.Unhandled = nil
+	disc5, err := discover.ListenV5(&sharedUDPConn{socket, unhandled}, ln, config)
+	if err != nil {
+		disc4.Close()
+		exit(err)
+	}
+	return disc4, disc5
+}
+
+// sharedUDPConn reads the packets v4 discovery couldn't handle. Writes go to
+// the underlying socket, which is owned and closed by v4 discovery.
+type sharedUDPConn struct {
+	*net.UDPConn
+	unhandled <-chan discover.ReadPacket
+}
+
+func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
+	packet, ok := <-s.unhandled
+	if !ok {
+		return 0, nil, errors.New("connection was closed")
+	}
+	n = copy(b, packet.Data)
+	return n, packet.Addr, nil
+}
+
+func (s *sharedUDPConn) Close() error {
+	return nil
+}
@@ -28,6 +28,7 @@ import (
 	"peerInfoCollect/p2p/discover"
 	"peerInfoCollect/p2p/enode"
 	"peerInfoCollect/params"
+	"peerInfoCollect/record"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -74,7 +75,14 @@ var (
 		Name:   "crawl",
 		Usage:  "Updates a nodes.json file with random nodes found in the DHT",
 		Action: discv4Crawl,
-		Flags:  []cli.Flag{bootnodesFlag, crawlTimeoutFlag},
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			crawlTimeoutFlag,
+			crawlDaemonFlag,
+			crawlCheckpointFlag,
+			recordSinksFlag,
+			recordEncodingFlag,
+		},
 	}
 	discv4TestCommand = cli.Command{
 		Name:   "test",
@@ -112,6 +120,24 @@ var (
 		Usage: "Time limit for the crawl.",
 		Value: 30 * time.Minute,
 	}
+	crawlDaemonFlag = cli.BoolFlag{
+		Name:  "daemon",
+		Usage: "Crawl until interrupted, revalidating known nodes and checkpointing the node set (ignores -timeout)",
+	}
+	crawlCheckpointFlag = cli.DurationFlag{
+		Name:  "checkpoint",
+		Usage: "Interval between node set checkpoints in daemon mode",
+		Value: 5 * time.Minute,
+	}
+	recordSinksFlag = cli.StringFlag{
+		Name:  "record.sinks",
+		Usage: "Comma separated URLs of record sinks crawl results are published to (e.g. file:///tmp/crawl.jsonl)",
+	}
+	recordEncodingFlag = cli.StringFlag{
+		Name:  "record.encoding",
+		Usage: "Wire format of published records (legacy, json or rlp)",
+		Value: string(record.EncodingJSON),
+	}
 	remoteEnodeFlag = cli.StringFlag{
 		Name:   "remote",
 		Usage:  "Enode of the remote node under test",
@@ -177,7 +203,7 @@ func discv4ResolveJSON(ctx *cli.Context) error {
 	// Run the crawler.
 	disc := startV4(ctx)
 	defer disc.Close()
-	c := newCrawler(inputSet, disc, enode.IterNodes(nodeargs))
+	c := newCrawler(inputSet, []discProtocol{{name: "discv4", disc: disc}}, enode.IterNodes(nodeargs))
 	c.revalidateInterval = 0
 	output := c.run(0)
 	writeNodesJSON(nodesFile, output)
@@ -196,11 +222,9 @@ func discv4Crawl(ctx *cli.Context) error {
 
 	disc := startV4(ctx)
 	defer disc.Close()
-	c := newCrawler(inputSet, disc, disc.RandomNodes())
+	c := newCrawler(inputSet, []discProtocol{{name: "discv4", disc: disc}}, disc.RandomNodes())
 	c.revalidateInterval = 10 * time.Minute
-	output := c.run(ctx.Duration(crawlTimeoutFlag.Name))
-	writeNodesJSON(nodesFile, output)
-	return nil
+	return runCrawler(ctx, c, nodesFile)
 }
 
 // discv4Test runs the protocol test suite.
@@ -53,7 +53,14 @@ var (
 		Name:   "crawl",
 		Usage:  "Updates a nodes.json file with random nodes found in the DHT",
 		Action: discv5Crawl,
-		Flags:  []cli.Flag{bootnodesFlag, crawlTimeoutFlag},
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			crawlTimeoutFlag,
+			crawlDaemonFlag,
+			crawlCheckpointFlag,
+			recordSinksFlag,
+			recordEncodingFlag,
+		},
 	}
 	discv5TestCommand = cli.Command{
 		Name:   "test",
@@ -109,11 +116,9 @@ func discv5Crawl(ctx *cli.Context) error {
 
 	disc := startV5(ctx)
 	defer disc.Close()
-	c := newCrawler(inputSet, disc, disc.RandomNodes())
+	c := newCrawler(inputSet, []discProtocol{{name: "discv5", disc: disc}}, disc.RandomNodes())
 	c.revalidateInterval = 10 * time.Minute
-	output := c.run(ctx.Duration(crawlTimeoutFlag.Name))
-	writeNodesJSON(nodesFile, output)
-	return nil
+	return runCrawler(ctx, c, nodesFile)
 }
 
 // discv5Test runs the protocol test suite.
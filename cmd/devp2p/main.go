@@ -61,6 +61,8 @@ func init() {
 		keyCommand,
 		discv4Command,
 		discv5Command,
+		crawlCommand,
+		crawlRLPxCommand,
 		dnsCommand,
 		nodesetCommand,
 		rlpxCommand,
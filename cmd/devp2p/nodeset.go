@@ -22,15 +22,20 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"time"
 
 	"peerInfoCollect/common"
+	"peerInfoCollect/common/hexutil"
 	"peerInfoCollect/p2p/enode"
 )
 
 const jsonIndent = "    "
 
+// livenessHistory is the number of hours of liveness checks kept per node.
+const livenessHistory = 7 * 24
+
 // nodeSet is the nodes.json file format. It holds a set of node records
 // as a JSON object.
 type nodeSet map[enode.ID]nodeJSON
@@ -47,6 +52,61 @@ type nodeJSON struct {
 	LastResponse  time.Time `json:"lastResponse,omitempty"`
 	// This one tracks the time of our last attempt to contact the node.
 	LastCheck time.Time `json:"lastCheck,omitempty"`
+	// Protocols tracks the last response on each discovery protocol the node
+	// answered on.
+	Protocols map[string]time.Time `json:"protocols,omitempty"`
+
+	// Liveness is the hourly history of liveness checks, oldest first.
+	Liveness []livenessJSON `json:"liveness,omitempty"`
+
+	// RLPx holds the outcome of the last RLPx dial, if the node was dialed.
+	RLPx *rlpxJSON `json:"rlpx,omitempty"`
+}
+
+// livenessJSON counts the liveness checks of a node within one hour.
+type livenessJSON struct {
+	Hour      time.Time `json:"hour"`
+	Checks    int       `json:"checks"`
+	Responses int       `json:"responses"`
+}
+
+// addCheck records the outcome of a liveness check in the node's history,
+// dropping hours that fell out of the window.
+func (n *nodeJSON) addCheck(t time.Time, responded bool) {
+	hour := t.Truncate(time.Hour)
+	if len(n.Liveness) == 0 || !n.Liveness[len(n.Liveness)-1].Hour.Equal(hour) {
+		n.Liveness = append(n.Liveness, livenessJSON{Hour: hour})
+	}
+	last := &n.Liveness[len(n.Liveness)-1]
+	last.Checks++
+	if responded {
+		last.Responses++
+	}
+	cutoff := hour.Add(-livenessHistory * time.Hour)
+	for len(n.Liveness) > 0 && !n.Liveness[0].Hour.After(cutoff) {
+		n.Liveness = n.Liveness[1:]
+	}
+}
+
+// rlpxJSON is what a node revealed when it was dialed by crawl-rlpx.
+type rlpxJSON struct {
+	Time       time.Time      `json:"time"` // when the node was dialed
+	Name       string         `json:"name,omitempty"`
+	Caps       []string       `json:"caps,omitempty"`
+	ListenPort uint64         `json:"listenPort,omitempty"`
+	Status     *ethStatusJSON `json:"status,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// ethStatusJSON is the eth protocol status of a node.
+type ethStatusJSON struct {
+	ProtocolVersion uint32        `json:"protocolVersion"`
+	NetworkID       uint64        `json:"networkID"`
+	TD              *hexutil.Big  `json:"td"`
+	Head            common.Hash   `json:"head"`
+	Genesis         common.Hash   `json:"genesis"`
+	ForkHash        hexutil.Bytes `json:"forkHash"`
+	ForkNext        uint64        `json:"forkNext"`
 }
 
 func loadNodesJSON(file string) nodeSet {
@@ -58,17 +118,40 @@ func loadNodesJSON(file string) nodeSet {
 }
 
 func writeNodesJSON(file string, nodes nodeSet) {
+	if err := writeNodesFile(file, nodes); err != nil {
+		exit(err)
+	}
+}
+
+// writeNodesFile writes the set to file. The file is replaced atomically, so
+// readers never see a partially written set.
+func writeNodesFile(file string, nodes nodeSet) error {
 	nodesJSON, err := json.MarshalIndent(nodes, "", jsonIndent)
 	if err != nil {
-		exit(err)
+		return err
 	}
 	if file == "-" {
-		os.Stdout.Write(nodesJSON)
-		return
+		_, err := os.Stdout.Write(nodesJSON)
+		return err
 	}
-	if err := ioutil.WriteFile(file, nodesJSON, 0644); err != nil {
-		exit(err)
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(nodesJSON)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
 	}
+	return err
 }
 
 // nodes returns the node records contained in the set.
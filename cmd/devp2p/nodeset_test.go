@@ -0,0 +1,153 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"peerInfoCollect/crypto"
+	"peerInfoCollect/p2p/enode"
+)
+
+func TestLiveness(t *testing.T) {
+	var (
+		n     nodeJSON
+		start = time.Date(2022, 6, 1, 10, 15, 0, 0, time.UTC)
+	)
+	n.addCheck(start, true)
+	n.addCheck(start.Add(30*time.Minute), false)
+	n.addCheck(start.Add(50*time.Minute), true)
+	want := []livenessJSON{
+		{Hour: start.Truncate(time.Hour), Checks: 2, Responses: 1},
+		{Hour: start.Add(time.Hour).Truncate(time.Hour), Checks: 1, Responses: 1},
+	}
+	if !reflect.DeepEqual(n.Liveness, want) {
+		t.Fatalf("wrong history %+v", n.Liveness)
+	}
+
+	// Hours falling out of the window are dropped.
+	end := start.Add(livenessHistory * time.Hour)
+	n.addCheck(end, false)
+	want = []livenessJSON{
+		{Hour: start.Add(time.Hour).Truncate(time.Hour), Checks: 1, Responses: 1},
+		{Hour: end.Truncate(time.Hour), Checks: 1},
+	}
+	if !reflect.DeepEqual(n.Liveness, want) {
+		t.Fatalf("wrong history after window moved %+v", n.Liveness)
+	}
+}
+
+func TestWriteNodesFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "nodes.json")
+	for i := 0; i < 2; i++ {
+		if err := writeNodesFile(file, make(nodeSet)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "nodes.json" {
+		t.Fatalf("temporary files left behind: %v", files)
+	}
+	if ns := loadNodesJSON(file); len(ns) != 0 {
+		t.Fatalf("wrong set %v", ns)
+	}
+}
+
+func TestDiffNodes(t *testing.T) {
+	var (
+		keyA, _ = crypto.GenerateKey()
+		keyB, _ = crypto.GenerateKey()
+		keyC, _ = crypto.GenerateKey()
+		keyD, _ = crypto.GenerateKey()
+		a       = make(nodeSet)
+		b       = make(nodeSet)
+	)
+	a.add(testNode(t, keyA, 1), testNode(t, keyB, 1), testNode(t, keyC, 1))
+	b.add(testNode(t, keyB, 1), testNode(t, keyC, 2), testNode(t, keyD, 1))
+
+	d := diffNodes(a, b)
+	if !reflect.DeepEqual(d.removed, []enode.ID{enode.PubkeyToIDV4(&keyA.PublicKey)}) {
+		t.Errorf("wrong removed nodes %v", d.removed)
+	}
+	if !reflect.DeepEqual(d.added, []enode.ID{enode.PubkeyToIDV4(&keyD.PublicKey)}) {
+		t.Errorf("wrong added nodes %v", d.added)
+	}
+	if !reflect.DeepEqual(d.updated, []enode.ID{enode.PubkeyToIDV4(&keyC.PublicKey)}) {
+		t.Errorf("wrong updated nodes %v", d.updated)
+	}
+	if d.unchanged != 1 {
+		t.Errorf("wrong unchanged count %d", d.unchanged)
+	}
+}
+
+func TestExport(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	n := testNode(t, key, 3)
+	ns := make(nodeSet)
+	ns.add(n)
+	entry := ns[n.ID()]
+	entry.Score = 2
+	entry.RLPx = &rlpxJSON{Name: "Geth/v1.10.17-stable/linux-amd64/go1.18", Caps: []string{"eth/66", "snap/1"}}
+	ns[n.ID()] = entry
+	rows := exportRows(ns, nil)
+
+	var buf bytes.Buffer
+	if err := exportCSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || len(records[1]) != len(exportColumns) {
+		t.Fatalf("wrong csv output %v", records)
+	}
+	if records[1][0] != n.ID().String() || records[1][1] != "3" || records[1][15] != "eth/66,snap/1" {
+		t.Errorf("wrong csv row %v", records[1])
+	}
+
+	buf.Reset()
+	if err := exportNDJSON(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	var row exportRow
+	if err := json.Unmarshal(buf.Bytes(), &row); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(row, rows[0]) {
+		t.Errorf("wrong ndjson row %+v", row)
+	}
+
+	buf.Reset()
+	if err := exportParquet(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.Bytes(); !bytes.HasPrefix(out, []byte("PAR1")) || !bytes.HasSuffix(out, []byte("PAR1")) {
+		t.Errorf("output is not a parquet file")
+	}
+}
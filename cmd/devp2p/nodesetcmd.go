@@ -39,6 +39,9 @@ var (
 		Subcommands: []cli.Command{
 			nodesetInfoCommand,
 			nodesetFilterCommand,
+			nodesetStatsCommand,
+			nodesetDiffCommand,
+			nodesetExportCommand,
 		},
 	}
 	nodesetInfoCommand = cli.Command{
@@ -65,18 +68,39 @@ func nodesetInfo(ctx *cli.Context) error {
 	ns := loadNodesJSON(ctx.Args().First())
 	fmt.Printf("Set contains %d nodes.\n", len(ns))
 	showAttributeCounts(ns)
+	showProtocolCounts(ns)
 	return nil
 }
 
+// showProtocolCounts prints how many nodes answered on each discovery protocol,
+// and how many of them answered on that protocol only.
+func showProtocolCounts(ns nodeSet) {
+	total := make(map[string]int)
+	for _, n := range ns {
+		for name := range n.Protocols {
+			total[name]++
+		}
+	}
+	if len(total) == 0 {
+		return
+	}
+	only := countProtocols(ns)
+	names := make([]string, 0, len(total))
+	for name := range total {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("Discovery protocol counts:")
+	for _, name := range names {
+		fmt.Printf(" %s: %d (%d only)\n", name, total[name], only[name])
+	}
+}
+
 // showAttributeCounts prints the distribution of ENR attributes in a node set.
 func showAttributeCounts(ns nodeSet) {
 	attrcount := make(map[string]int)
-	var attrlist []interface{}
 	for _, n := range ns {
-		r := n.N.Record()
-		attrlist = r.AppendElements(attrlist[:0])[1:]
-		for i := 0; i < len(attrlist); i += 2 {
-			key := attrlist[i].(string)
+		for _, key := range enrKeys(n) {
 			attrcount[key]++
 		}
 	}
@@ -140,6 +164,7 @@ var filterFlags = map[string]nodeFilterC{
 	"-eth-network": {1, ethFilter},
 	"-les-server":  {0, lesFilter},
 	"-snap":        {0, snapFilter},
+	"-disc-only":   {1, discOnlyFilter},
 }
 
 // parseFilters parses nodeFilters from args.
@@ -254,6 +279,14 @@ func ethFilter(args []string) (nodeFilter, error) {
 	return f, nil
 }
 
+func discOnlyFilter(args []string) (nodeFilter, error) {
+	f := func(n nodeJSON) bool {
+		_, ok := n.Protocols[args[0]]
+		return ok && len(n.Protocols) == 1
+	}
+	return f, nil
+}
+
 func lesFilter(args []string) (nodeFilter, error) {
 	f := func(n nodeJSON) bool {
 		var les struct {
@@ -0,0 +1,237 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"peerInfoCollect/p2p/clientinfo"
+	"peerInfoCollect/p2p/geoip"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	nodesetExportCommand = cli.Command{
+		Name:      "export",
+		Usage:     "Writes a node set as a flat table",
+		Action:    nodesetExport,
+		ArgsUsage: "<nodes.json> [<output file>]",
+		Flags:     []cli.Flag{exportFormatFlag, geoCityFlag, geoASNFlag},
+	}
+	exportFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: "Output format (csv, parquet, ndjson)",
+		Value: "csv",
+	}
+)
+
+// exportRow is a node set entry flattened into a table row. Times are unix
+// seconds, zero if unknown. Lists are comma separated.
+type exportRow struct {
+	ID              string `json:"id" parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Seq             int64  `json:"seq" parquet:"name=seq, type=INT64"`
+	IP              string `json:"ip" parquet:"name=ip, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TCP             int32  `json:"tcp" parquet:"name=tcp, type=INT32"`
+	UDP             int32  `json:"udp" parquet:"name=udp, type=INT32"`
+	Score           int64  `json:"score" parquet:"name=score, type=INT64"`
+	FirstResponse   int64  `json:"firstResponse" parquet:"name=first_response, type=INT64"`
+	LastResponse    int64  `json:"lastResponse" parquet:"name=last_response, type=INT64"`
+	LastCheck       int64  `json:"lastCheck" parquet:"name=last_check, type=INT64"`
+	Protocols       string `json:"protocols" parquet:"name=protocols, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ENRKeys         string `json:"enrKeys" parquet:"name=enr_keys, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ForkID          string `json:"forkID" parquet:"name=fork_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Name            string `json:"name" parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Client          string `json:"client" parquet:"name=client, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClientVersion   string `json:"clientVersion" parquet:"name=client_version, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Caps            string `json:"caps" parquet:"name=caps, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ListenPort      int64  `json:"listenPort" parquet:"name=listen_port, type=INT64"`
+	ProtocolVersion int32  `json:"protocolVersion" parquet:"name=protocol_version, type=INT32"`
+	NetworkID       int64  `json:"networkID" parquet:"name=network_id, type=INT64"`
+	Head            string `json:"head" parquet:"name=head, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TD              string `json:"td" parquet:"name=td, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DialError       string `json:"dialError" parquet:"name=dial_error, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Country         string `json:"country" parquet:"name=country, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ASN             int64  `json:"asn" parquet:"name=asn, type=INT64"`
+	Org             string `json:"org" parquet:"name=org, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Record          string `json:"record" parquet:"name=record, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// exportColumns are the CSV column names, in field order.
+var exportColumns = []string{
+	"id", "seq", "ip", "tcp", "udp", "score", "firstResponse", "lastResponse", "lastCheck",
+	"protocols", "enrKeys", "forkID", "name", "client", "clientVersion", "caps", "listenPort",
+	"protocolVersion", "networkID", "head", "td", "dialError", "country", "asn", "org", "record",
+}
+
+func nodesetExport(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need nodes file as argument")
+	}
+	ns := loadNodesJSON(ctx.Args().First())
+	geo, err := openGeoResolver(ctx)
+	if err != nil {
+		return err
+	}
+	if geo != nil {
+		defer geo.Close()
+	}
+	rows := exportRows(ns, geo)
+
+	out := io.Writer(os.Stdout)
+	if file := ctx.Args().Get(1); file != "" && file != "-" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	switch format := ctx.String(exportFormatFlag.Name); format {
+	case "csv":
+		return exportCSV(out, rows)
+	case "ndjson":
+		return exportNDJSON(out, rows)
+	case "parquet":
+		return exportParquet(out, rows)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// exportRows flattens a node set into rows sorted by node ID. The location
+// columns are filled in if geo isn't nil.
+func exportRows(ns nodeSet, geo *geoip.Resolver) []exportRow {
+	rows := make([]exportRow, 0, len(ns))
+	for _, n := range ns.nodes() {
+		entry := ns[n.ID()]
+		row := exportRow{
+			ID:            n.ID().String(),
+			Seq:           int64(n.Seq()),
+			TCP:           int32(n.TCP()),
+			UDP:           int32(n.UDP()),
+			Score:         int64(entry.Score),
+			FirstResponse: unixTime(entry.FirstResponse),
+			LastResponse:  unixTime(entry.LastResponse),
+			LastCheck:     unixTime(entry.LastCheck),
+			ENRKeys:       strings.Join(enrKeys(entry), ","),
+			ForkID:        nodeForkID(entry),
+			Record:        n.String(),
+		}
+		if ip := n.IP(); ip != nil {
+			row.IP = ip.String()
+			if geo != nil {
+				if info := geo.Lookup(row.IP); info != nil {
+					row.Country, row.ASN, row.Org = info.Country, int64(info.ASN), info.Org
+				}
+			}
+		}
+		protocols := make([]string, 0, len(entry.Protocols))
+		for name := range entry.Protocols {
+			protocols = append(protocols, name)
+		}
+		sort.Strings(protocols)
+		row.Protocols = strings.Join(protocols, ",")
+
+		if dial := entry.RLPx; dial != nil {
+			row.Name = dial.Name
+			if info := clientinfo.Parse(dial.Name); info.Parsed() {
+				row.Client, row.ClientVersion = info.Client, info.Version
+			}
+			row.Caps = strings.Join(dial.Caps, ",")
+			row.ListenPort = int64(dial.ListenPort)
+			row.DialError = dial.Error
+			if status := dial.Status; status != nil {
+				row.ProtocolVersion = int32(status.ProtocolVersion)
+				row.NetworkID = int64(status.NetworkID)
+				row.Head = status.Head.Hex()
+				if status.TD != nil {
+					row.TD = status.TD.ToInt().String()
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// enrKeys returns the keys of a node's record.
+func enrKeys(n nodeJSON) []string {
+	var keys []string
+	elems := n.N.Record().AppendElements(nil)[1:]
+	for i := 0; i < len(elems); i += 2 {
+		keys = append(keys, elems[i].(string))
+	}
+	return keys
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func exportCSV(w io.Writer, rows []exportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exportColumns)
+	for _, r := range rows {
+		cw.Write([]string{
+			r.ID, strconv.FormatInt(r.Seq, 10), r.IP,
+			strconv.Itoa(int(r.TCP)), strconv.Itoa(int(r.UDP)), strconv.FormatInt(r.Score, 10),
+			strconv.FormatInt(r.FirstResponse, 10), strconv.FormatInt(r.LastResponse, 10), strconv.FormatInt(r.LastCheck, 10),
+			r.Protocols, r.ENRKeys, r.ForkID, r.Name, r.Client, r.ClientVersion, r.Caps,
+			strconv.FormatInt(r.ListenPort, 10), strconv.Itoa(int(r.ProtocolVersion)), strconv.FormatInt(r.NetworkID, 10),
+			r.Head, r.TD, r.DialError, r.Country, strconv.FormatInt(r.ASN, 10), r.Org, r.Record,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportNDJSON(w io.Writer, rows []exportRow) error {
+	enc := json.NewEncoder(w)
+	for i := range rows {
+		if err := enc.Encode(&rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportParquet(w io.Writer, rows []exportRow) error {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(exportRow), 1)
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, r := range rows {
+		if err := pw.Write(r); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
@@ -0,0 +1,273 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+
+	"peerInfoCollect/common/hexutil"
+	"peerInfoCollect/core/forkid"
+	"peerInfoCollect/p2p/clientinfo"
+	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/p2p/enr"
+	"peerInfoCollect/p2p/geoip"
+	"peerInfoCollect/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	nodesetStatsCommand = cli.Command{
+		Name:      "stats",
+		Usage:     "Shows histograms of clients, fork IDs, subnets, ASNs, ports and ENR keys",
+		Action:    nodesetStats,
+		ArgsUsage: "<nodes.json>",
+		Flags:     []cli.Flag{statsTopFlag, geoASNFlag},
+	}
+	nodesetDiffCommand = cli.Command{
+		Name:      "diff",
+		Usage:     "Shows the nodes added, removed and updated between two node sets",
+		Action:    nodesetDiff,
+		ArgsUsage: "<a.json> <b.json>",
+	}
+)
+
+var (
+	statsTopFlag = cli.IntFlag{
+		Name:  "top",
+		Usage: "Number of entries shown per histogram (0 = all)",
+		Value: 20,
+	}
+	geoCityFlag = cli.StringFlag{
+		Name:  "geo.city",
+		Usage: "MaxMind GeoLite2 City database used to locate nodes",
+	}
+	geoASNFlag = cli.StringFlag{
+		Name:  "geo.asn",
+		Usage: "MaxMind GeoLite2 ASN database used to resolve the autonomous system of nodes",
+	}
+)
+
+func nodesetStats(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need nodes file as argument")
+	}
+	ns := loadNodesJSON(ctx.Args().First())
+	geo, err := openGeoResolver(ctx)
+	if err != nil {
+		return err
+	}
+	if geo != nil {
+		defer geo.Close()
+	}
+
+	var (
+		top      = ctx.Int(statsTopFlag.Name)
+		clients  = make(map[string]int)
+		forks    = make(map[string]int)
+		subnets  = make(map[string]int)
+		asns     = make(map[string]int)
+		ports    = make(map[string]int)
+		rlpxSeen int
+	)
+	for _, n := range ns {
+		if n.RLPx != nil && n.RLPx.Name != "" {
+			rlpxSeen++
+		}
+		clients[nodeClient(n)]++
+		forks[nodeForkID(n)]++
+		subnets[nodeSubnet(n.N.IP())]++
+		switch {
+		case n.N.TCP() == 0:
+			ports["no tcp"]++
+		case n.N.TCP() == n.N.UDP():
+			ports["tcp == udp"]++
+		default:
+			ports["tcp != udp"]++
+		}
+		if geo != nil {
+			asns[nodeASN(geo, n.N.IP())]++
+		}
+	}
+
+	fmt.Printf("Set contains %d nodes, %d with RLPx hello.\n", len(ns), rlpxSeen)
+	showHistogram("Clients", clients, top)
+	showHistogram("Fork IDs", forks, top)
+	showHistogram("IP subnets", subnets, top)
+	if geo != nil {
+		showHistogram("ASNs", asns, top)
+	}
+	showHistogram("Ports", ports, top)
+	showAttributeCounts(ns)
+	return nil
+}
+
+// openGeoResolver opens the location databases given on the command line,
+// returning nil if there are none.
+func openGeoResolver(ctx *cli.Context) (*geoip.Resolver, error) {
+	city, asn := ctx.String(geoCityFlag.Name), ctx.String(geoASNFlag.Name)
+	if city == "" && asn == "" {
+		return nil, nil
+	}
+	return geoip.NewResolver(city, asn)
+}
+
+// showHistogram prints counts by descending frequency, limited to the top
+// entries unless top is zero.
+func showHistogram(title string, counts map[string]int, top int) {
+	var total int
+	keys := make([]string, 0, len(counts))
+	for key, count := range counts {
+		keys = append(keys, key)
+		total += count
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	fmt.Printf("%s (%d distinct):\n", title, len(keys))
+	for i, key := range keys {
+		if top > 0 && i == top {
+			var rest int
+			for _, key := range keys[i:] {
+				rest += counts[key]
+			}
+			fmt.Printf(" %8d %5.1f%%  (%d others)\n", rest, percent(rest, total), len(keys)-i)
+			break
+		}
+		fmt.Printf(" %8d %5.1f%%  %s\n", counts[key], percent(counts[key], total), key)
+	}
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// nodeClient returns the client name and version a node reported in its
+// RLPx hello.
+func nodeClient(n nodeJSON) string {
+	if n.RLPx == nil || n.RLPx.Name == "" {
+		return "unknown"
+	}
+	info := clientinfo.Parse(n.RLPx.Name)
+	if !info.Parsed() {
+		return "unparseable"
+	}
+	return info.Client + "/" + info.Version
+}
+
+// nodeForkID returns the fork ID of a node as hash/next. It is taken from the
+// "eth" ENR entry, or from the eth status if the node was dialed.
+func nodeForkID(n nodeJSON) string {
+	var eth struct {
+		ForkID forkid.ID
+		Tail   []rlp.RawValue `rlp:"tail"`
+	}
+	if n.N.Load(enr.WithEntry("eth", &eth)) == nil {
+		return fmt.Sprintf("%s/%d", hexutil.Bytes(eth.ForkID.Hash[:]), eth.ForkID.Next)
+	}
+	if n.RLPx != nil && n.RLPx.Status != nil {
+		return fmt.Sprintf("%s/%d", n.RLPx.Status.ForkHash, n.RLPx.Status.ForkNext)
+	}
+	return "none"
+}
+
+// nodeSubnet returns the /16 subnet of an IPv4 address, or the /32 subnet of
+// an IPv6 address.
+func nodeSubnet(ip net.IP) string {
+	if ip == nil {
+		return "none"
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(16, 32)), Mask: net.CIDRMask(16, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(32, 128)), Mask: net.CIDRMask(32, 128)}).String()
+}
+
+// nodeASN returns the autonomous system of an address as "AS<number> <org>".
+func nodeASN(geo *geoip.Resolver, ip net.IP) string {
+	if ip == nil {
+		return "unknown"
+	}
+	info := geo.Lookup(ip.String())
+	if info == nil || info.ASN == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("AS%d %s", info.ASN, info.Org)
+}
+
+func nodesetDiff(ctx *cli.Context) error {
+	if ctx.NArg() < 2 {
+		return fmt.Errorf("need two nodes files as arguments")
+	}
+	a := loadNodesJSON(ctx.Args().Get(0))
+	b := loadNodesJSON(ctx.Args().Get(1))
+	d := diffNodes(a, b)
+
+	for _, id := range d.removed {
+		fmt.Printf("- %v seq=%d\n", id, a[id].Seq)
+	}
+	for _, id := range d.added {
+		fmt.Printf("+ %v seq=%d\n", id, b[id].Seq)
+	}
+	for _, id := range d.updated {
+		fmt.Printf("~ %v seq=%d->%d\n", id, a[id].Seq, b[id].Seq)
+	}
+	fmt.Printf("%d nodes removed, %d added, %d updated, %d unchanged.\n",
+		len(d.removed), len(d.added), len(d.updated), d.unchanged)
+	return nil
+}
+
+// nodeSetDiff is the difference between two node sets.
+type nodeSetDiff struct {
+	removed   []enode.ID // only in the first set
+	added     []enode.ID // only in the second set
+	updated   []enode.ID // in both sets, with different records
+	unchanged int
+}
+
+// diffNodes compares two node sets. All ID lists are sorted.
+func diffNodes(a, b nodeSet) nodeSetDiff {
+	var d nodeSetDiff
+	for id, na := range a {
+		nb, ok := b[id]
+		switch {
+		case !ok:
+			d.removed = append(d.removed, id)
+		case na.Seq != nb.Seq || na.N.String() != nb.N.String():
+			d.updated = append(d.updated, id)
+		default:
+			d.unchanged++
+		}
+	}
+	for id := range b {
+		if _, ok := a[id]; !ok {
+			d.added = append(d.added, id)
+		}
+	}
+	for _, ids := range [][]enode.ID{d.removed, d.added, d.updated} {
+		sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i][:], ids[j][:]) < 0 })
+	}
+	return d
+}
@@ -0,0 +1,272 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"peerInfoCollect/cmd/devp2p/internal/ethtest"
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/hexutil"
+	"peerInfoCollect/crypto"
+	"peerInfoCollect/log"
+	"peerInfoCollect/p2p"
+	"peerInfoCollect/p2p/clientinfo"
+	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/p2p/rlpx"
+	"peerInfoCollect/record"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	crawlRLPxCommand = cli.Command{
+		Name:      "crawl-rlpx",
+		Usage:     "Updates a nodes.json file with random nodes found in the DHT and their RLPx hello and eth status",
+		ArgsUsage: "<nodes.json file>",
+		Action:    crawlRLPx,
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			crawlTimeoutFlag,
+			crawlDaemonFlag,
+			crawlCheckpointFlag,
+			rlpxWorkersFlag,
+			rlpxDialTimeoutFlag,
+			recordSinksFlag,
+			recordEncodingFlag,
+		},
+	}
+)
+
+var (
+	rlpxWorkersFlag = cli.IntFlag{
+		Name:  "rlpx.workers",
+		Usage: "Number of nodes dialed concurrently",
+		Value: 16,
+	}
+	rlpxDialTimeoutFlag = cli.DurationFlag{
+		Name:  "rlpx.timeout",
+		Usage: "Time limit for dialing a node and receiving its hello and status",
+		Value: 10 * time.Second,
+	}
+)
+
+// rlpxCrawlCaps are the capabilities announced when dialing nodes. Only eth
+// is offered, so its messages start right after the base protocol.
+var rlpxCrawlCaps = []p2p.Cap{
+	{Name: "eth", Version: 64},
+	{Name: "eth", Version: 65},
+	{Name: "eth", Version: 66},
+}
+
+func crawlRLPx(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need nodes file as argument")
+	}
+	nodesFile := ctx.Args().First()
+	var inputSet nodeSet
+	if common.FileExist(nodesFile) {
+		inputSet = loadNodesJSON(nodesFile)
+	}
+
+	disc := startV4(ctx)
+	defer disc.Close()
+	c := newCrawler(inputSet, []discProtocol{{name: "discv4", disc: disc}}, disc.RandomNodes())
+	c.revalidateInterval = 10 * time.Minute
+	c.prober = newRLPxProber(ctx.Int(rlpxWorkersFlag.Name), ctx.Duration(rlpxDialTimeoutFlag.Name))
+	return runCrawler(ctx, c, nodesFile)
+}
+
+// rlpxResult is the outcome of dialing a node.
+type rlpxResult struct {
+	id   enode.ID
+	info *rlpxJSON
+}
+
+// rlpxProber dials nodes, reads their RLPx hello and eth status and
+// disconnects again.
+type rlpxProber struct {
+	key     *ecdsa.PrivateKey
+	workers int
+	timeout time.Duration
+	queue   chan *enode.Node
+	results chan rlpxResult
+	wg      sync.WaitGroup
+}
+
+func newRLPxProber(workers int, timeout time.Duration) *rlpxProber {
+	if workers < 1 {
+		workers = 1
+	}
+	key, _ := crypto.GenerateKey()
+	return &rlpxProber{
+		key:     key,
+		workers: workers,
+		timeout: timeout,
+		queue:   make(chan *enode.Node),
+		results: make(chan rlpxResult),
+	}
+}
+
+// start launches the dialing workers. They exit once closed is closed.
+func (p *rlpxProber) start(closed <-chan struct{}) {
+	p.wg.Add(p.workers)
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for {
+				select {
+				case n := <-p.queue:
+					res := rlpxResult{id: n.ID(), info: p.probe(n)}
+					select {
+					case p.results <- res:
+					case <-closed:
+						return
+					}
+				case <-closed:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// wait blocks until all workers have exited.
+func (p *rlpxProber) wait() {
+	p.wg.Wait()
+}
+
+// probe dials a node and publishes what it revealed.
+func (p *rlpxProber) probe(n *enode.Node) *rlpxJSON {
+	start := time.Now()
+	info := &rlpxJSON{Time: truncNow()}
+	if err := p.handshake(n, info); err != nil {
+		info.Error = err.Error()
+	}
+	log.Debug("Dialed node", "id", n.ID(), "name", info.Name, "err", info.Error)
+
+	rec := &record.NodeCrawlRecordInfo{
+		PeerId:     n.ID().String(),
+		Enode:      n.URLv4(),
+		PeerAddr:   (&net.TCPAddr{IP: n.IP(), Port: n.TCP()}).String(),
+		Seq:        n.Seq(),
+		Name:       info.Name,
+		Caps:       info.Caps,
+		ListenPort: info.ListenPort,
+		Error:      info.Error,
+		Duration:   uint64(time.Since(start)),
+		Time:       uint64(start.UnixNano()),
+	}
+	if client := clientinfo.Parse(info.Name); client.Parsed() {
+		rec.Client, rec.ClientVersion = client.Client, client.Version
+	}
+	if status := info.Status; status != nil {
+		rec.ProtocolVersion = status.ProtocolVersion
+		rec.NetworkID = status.NetworkID
+		rec.TD = status.TD.ToInt().String()
+		rec.Head = status.Head.Hex()
+		rec.Genesis = status.Genesis.Hex()
+		rec.ForkHash = status.ForkHash.String()
+		rec.ForkNext = status.ForkNext
+	}
+	if err := record.Publish(rec); err != nil {
+		log.Debug("Failed to publish crawl result", "id", n.ID(), "err", err)
+	}
+	return info
+}
+
+// handshake performs the RLPx handshake with a node and fills in its hello
+// and status. Nodes not running eth only reveal their hello.
+func (p *rlpxProber) handshake(n *enode.Node, info *rlpxJSON) error {
+	addr := &net.TCPAddr{IP: n.IP(), Port: n.TCP()}
+	fd, err := net.DialTimeout("tcp", addr.String(), p.timeout)
+	if err != nil {
+		return err
+	}
+	conn := &ethtest.Conn{Conn: rlpx.NewConn(fd, n.Pubkey())}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(p.timeout))
+
+	if _, err := conn.Handshake(p.key); err != nil {
+		return fmt.Errorf("encryption handshake failed: %v", err)
+	}
+	ourHello := &ethtest.Hello{
+		Version: 5,
+		Caps:    rlpxCrawlCaps,
+		ID:      crypto.FromECDSAPub(&p.key.PublicKey)[1:],
+	}
+	if err := conn.Write(ourHello); err != nil {
+		return fmt.Errorf("write to connection failed: %v", err)
+	}
+	var hello bool
+	for {
+		switch msg := conn.Read().(type) {
+		case *ethtest.Hello:
+			hello = true
+			info.Name = msg.Name
+			info.ListenPort = msg.ListenPort
+			info.Caps = make([]string, len(msg.Caps))
+			for i, capability := range msg.Caps {
+				info.Caps[i] = capability.String()
+			}
+			if msg.Version >= 5 {
+				conn.SetSnappy(true)
+			}
+			if !hasEthCap(msg.Caps) {
+				conn.Write(&ethtest.Disconnect{Reason: p2p.DiscUselessPeer})
+				return nil
+			}
+		case *ethtest.Status:
+			if !hello {
+				return fmt.Errorf("status received before hello")
+			}
+			info.Status = &ethStatusJSON{
+				ProtocolVersion: msg.ProtocolVersion,
+				NetworkID:       msg.NetworkID,
+				TD:              (*hexutil.Big)(msg.TD),
+				Head:            msg.Head,
+				Genesis:         msg.Genesis,
+				ForkHash:        msg.ForkID.Hash[:],
+				ForkNext:        msg.ForkID.Next,
+			}
+			conn.Write(&ethtest.Disconnect{Reason: p2p.DiscQuitting})
+			return nil
+		case *ethtest.Ping:
+			conn.Write(&ethtest.Pong{})
+		case *ethtest.Disconnect:
+			return fmt.Errorf("disconnected: %v", msg.Reason)
+		case *ethtest.Error:
+			return msg
+		}
+	}
+}
+
+// hasEthCap reports whether the capabilities include a version of eth the
+// prober can read the status of.
+func hasEthCap(caps []p2p.Cap) bool {
+	for _, capability := range caps {
+		for _, our := range rlpxCrawlCaps {
+			if capability == our {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"peerInfoCollect/cmd/devp2p/internal/ethtest"
+	"peerInfoCollect/common"
+	"peerInfoCollect/core/forkid"
+	"peerInfoCollect/crypto"
+	"peerInfoCollect/p2p"
+	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/p2p/enr"
+	"peerInfoCollect/p2p/rlpx"
+)
+
+// testInvalidMsg is a message with a code the prober doesn't know.
+type testInvalidMsg struct{}
+
+func (testInvalidMsg) Code() int { return 0x7f }
+
+// testRLPxNode creates a node record listening for RLPx on the given port.
+func testRLPxNode(t *testing.T, key *ecdsa.PrivateKey, port int) *enode.Node {
+	var r enr.Record
+	r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+	r.Set(enr.UDP(30303))
+	r.Set(enr.TCP(port))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// serveRLPx accepts a single RLPx connection on a local listener. The hello of
+// the dialer is read first, then the replies are sent in order. Everything the
+// dialer sends afterwards is delivered on the returned channel, which is closed
+// when the connection ends.
+func serveRLPx(t *testing.T, replies ...ethtest.Message) (*enode.Node, <-chan ethtest.Message) {
+	key, _ := crypto.GenerateKey()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan ethtest.Message, 16)
+	go func() {
+		defer close(received)
+
+		fd, err := ln.Accept()
+		ln.Close()
+		if err != nil {
+			return
+		}
+		conn := &ethtest.Conn{Conn: rlpx.NewConn(fd, nil)}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		if _, err := conn.Handshake(key); err != nil {
+			return
+		}
+		if _, ok := conn.Read().(*ethtest.Hello); !ok {
+			return
+		}
+		for _, msg := range replies {
+			if err := conn.Write(msg); err != nil {
+				return
+			}
+			if _, ok := msg.(*ethtest.Hello); ok {
+				conn.SetSnappy(true)
+			}
+		}
+		for {
+			msg := conn.Read()
+			if _, ok := msg.(*ethtest.Error); ok {
+				return
+			}
+			received <- msg
+		}
+	}()
+	return testRLPxNode(t, key, ln.Addr().(*net.TCPAddr).Port), received
+}
+
+func TestRLPxHandshake(t *testing.T) {
+	var (
+		ethHello = &ethtest.Hello{
+			Version:    5,
+			Name:       "Geth/v1.10.17-stable/linux-amd64/go1.18",
+			Caps:       []p2p.Cap{{Name: "eth", Version: 66}, {Name: "snap", Version: 1}},
+			ListenPort: 30303,
+		}
+		snapHello = &ethtest.Hello{
+			Version: 5,
+			Name:    "Snapper/v1.0.0",
+			Caps:    []p2p.Cap{{Name: "snap", Version: 1}},
+		}
+		status = &ethtest.Status{
+			ProtocolVersion: 66,
+			NetworkID:       1,
+			TD:              big.NewInt(1000),
+			Head:            common.Hash{0x01},
+			Genesis:         common.Hash{0x02},
+			ForkID:          forkid.ID{Hash: [4]byte{0x20, 0xc3, 0x27, 0xfc}, Next: 14000000},
+		}
+	)
+	tests := []struct {
+		name    string
+		replies []ethtest.Message
+		err     string            // substring of the expected error, empty if none
+		caps    []string          // capabilities revealed in the hello
+		status  bool              // whether the status must be revealed
+		sent    []ethtest.Message // messages the prober must answer with
+	}{
+		{
+			name:    "status",
+			replies: []ethtest.Message{ethHello, &ethtest.Ping{}, status},
+			caps:    []string{"eth/66", "snap/1"},
+			status:  true,
+			sent:    []ethtest.Message{&ethtest.Pong{}, &ethtest.Disconnect{Reason: p2p.DiscQuitting}},
+		},
+		{
+			name:    "no eth",
+			replies: []ethtest.Message{snapHello},
+			caps:    []string{"snap/1"},
+			sent:    []ethtest.Message{&ethtest.Disconnect{Reason: p2p.DiscUselessPeer}},
+		},
+		{
+			name:    "disconnect",
+			replies: []ethtest.Message{ethHello, &ethtest.Disconnect{Reason: p2p.DiscTooManyPeers}},
+			err:     "disconnected: too many peers",
+			caps:    []string{"eth/66", "snap/1"},
+		},
+		{
+			name:    "invalid message",
+			replies: []ethtest.Message{ethHello, testInvalidMsg{}},
+			err:     "invalid message code",
+			caps:    []string{"eth/66", "snap/1"},
+		},
+		{
+			name:    "status before hello",
+			replies: []ethtest.Message{status},
+			err:     "status received before hello",
+		},
+	}
+	prober := newRLPxProber(1, 5*time.Second)
+	for _, tt := range tests {
+		node, received := serveRLPx(t, tt.replies...)
+
+		info := new(rlpxJSON)
+		err := prober.handshake(node, info)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: wrong error %v, want %q", tt.name, err, tt.err)
+		}
+		if strings.Join(info.Caps, ",") != strings.Join(tt.caps, ",") {
+			t.Errorf("%s: wrong caps %v, want %v", tt.name, info.Caps, tt.caps)
+		}
+		if tt.caps != nil && (info.Name == "" || info.Name != tt.replies[0].(*ethtest.Hello).Name) {
+			t.Errorf("%s: wrong name %q", tt.name, info.Name)
+		}
+		if have := info.Status != nil; have != tt.status {
+			t.Errorf("%s: status revealed: have %v, want %v", tt.name, have, tt.status)
+		}
+		if tt.status {
+			if info.ListenPort != 30303 || info.Status.ProtocolVersion != 66 || info.Status.NetworkID != 1 ||
+				info.Status.TD.ToInt().Cmp(status.TD) != 0 || info.Status.Head != status.Head || info.Status.Genesis != status.Genesis ||
+				info.Status.ForkHash.String() != "0x20c327fc" || info.Status.ForkNext != 14000000 {
+				t.Errorf("%s: wrong info %+v, status %+v", tt.name, info, info.Status)
+			}
+		}
+		var sent []ethtest.Message
+		for msg := range received {
+			sent = append(sent, msg)
+		}
+		if len(sent) != len(tt.sent) {
+			t.Errorf("%s: prober sent %d messages, want %d", tt.name, len(sent), len(tt.sent))
+			continue
+		}
+		for i := range sent {
+			if sent[i].Code() != tt.sent[i].Code() {
+				t.Errorf("%s: message %d has code %d, want %d", tt.name, i, sent[i].Code(), tt.sent[i].Code())
+			}
+			if want, ok := tt.sent[i].(*ethtest.Disconnect); ok && sent[i].(*ethtest.Disconnect).Reason != want.Reason {
+				t.Errorf("%s: wrong disconnect reason %v, want %v", tt.name, sent[i].(*ethtest.Disconnect).Reason, want.Reason)
+			}
+		}
+	}
+}
+
+func TestCrawlerProbes(t *testing.T) {
+	var (
+		hello = &ethtest.Hello{
+			Version: 5,
+			Name:    "Geth/v1.10.17-stable/linux-amd64/go1.18",
+			Caps:    []p2p.Cap{{Name: "eth", Version: 66}},
+		}
+		status = &ethtest.Status{ProtocolVersion: 66, NetworkID: 1, TD: big.NewInt(1000)}
+	)
+	served, _ := serveRLPx(t, hello, status)
+
+	// A node without TCP port is never dialed, one refusing connections is
+	// stored with the dial error.
+	udpKey, _ := crypto.GenerateKey()
+	udpOnly := testNode(t, udpKey, 1)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadKey, _ := crypto.GenerateKey()
+	dead := testRLPxNode(t, deadKey, ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	nodes := []*enode.Node{served, udpOnly, dead}
+	resolver := make(testResolver)
+	for _, n := range nodes {
+		resolver[n.ID()] = n
+	}
+	c := newCrawler(nil, []discProtocol{{name: "discv4", disc: resolver}}, enode.IterNodes(nodes))
+	c.prober = newRLPxProber(2, 5*time.Second)
+	output := c.run(0)
+
+	if len(output) != 3 {
+		t.Fatalf("wrong output size %d", len(output))
+	}
+	if info := output[served.ID()].RLPx; info == nil || info.Name != hello.Name || info.Status == nil || info.Status.NetworkID != 1 || info.Error != "" {
+		t.Errorf("wrong probe result of served node: %+v", info)
+	}
+	if info := output[udpOnly.ID()].RLPx; info != nil {
+		t.Errorf("node without TCP port was dialed: %+v", info)
+	}
+	if info := output[dead.ID()].RLPx; info == nil || info.Error == "" || info.Status != nil {
+		t.Errorf("wrong probe result of unreachable node: %+v", info)
+	}
+	if len(c.probeSet) != 0 || len(c.probeQueue) != 0 {
+		t.Errorf("probes left over: %d queued, %d pending", len(c.probeQueue), len(c.probeSet))
+	}
+}
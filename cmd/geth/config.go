@@ -36,6 +36,7 @@ import (
 	"peerInfoCollect/metrics"
 	"peerInfoCollect/node"
 	"peerInfoCollect/params"
+	"peerInfoCollect/record"
 	"github.com/naoina/toml"
 )
 
@@ -87,6 +88,7 @@ type gethConfig struct {
 	Node     node.Config
 	Ethstats ethstatsConfig
 	Metrics  metrics.Config
+	Record   record.Config
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -120,6 +122,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 		Eth:     ethconfig.Defaults,
 		Node:    defaultNodeConfig(),
 		Metrics: metrics.DefaultConfig,
+		Record:  record.DefaultConfig,
 	}
 
 	// Load config file.
@@ -131,6 +134,9 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 
 	// Apply flags.
 	utils.SetNodeConfig(ctx, &cfg.Node)
+	utils.SetRecordConfig(ctx, &cfg.Record)
+	cfg.Node.Record = cfg.Record
+
 	stack, err := node.New(&cfg.Node)
 	if err != nil {
 		utils.Fatalf("Failed to create the protocol stack: %v", err)
@@ -82,6 +82,7 @@ var (
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
 		utils.SyncModeFlag,
+		utils.ObserverFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
@@ -111,8 +112,13 @@ var (
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DNSDiscoveryFlag,
+		utils.DiscoveryTapFlag,
+		utils.DiscoveryTapRateFlag,
 		utils.MainnetFlag,
 		utils.NetworkIdFlag,
+		utils.ObserveNetworksFlag,
+		utils.ObserveForkToleranceFlag,
+		utils.ObserveForkAheadFlag,
 		utils.EthStatsURLFlag,
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
@@ -158,6 +164,24 @@ var (
 		utils.MetricsInfluxDBBucketFlag,
 		utils.MetricsInfluxDBOrganizationFlag,
 	}
+
+	recordFlags = []cli.Flag{
+		utils.RecordRedisFlag,
+		utils.RecordMongoFlag,
+		utils.RecordChannelsFlag,
+		utils.RecordEncodingFlag,
+		utils.RecordCollectorFlag,
+		utils.RecordDisableFlag,
+		utils.RecordQueueSizeFlag,
+		utils.RecordQueueWorkersFlag,
+		utils.RecordQueueBatchFlag,
+		utils.RecordQueueDropFlag,
+		utils.RecordSpoolDirFlag,
+		utils.RecordSpoolMaxSizeFlag,
+		utils.RecordSpoolMaxAgeFlag,
+		utils.RecordGeoCityFlag,
+		utils.RecordGeoASNFlag,
+	}
 )
 
 func init() {
@@ -193,6 +217,7 @@ func init() {
 	app.Flags = append(app.Flags, rpcFlags...)
 	app.Flags = append(app.Flags, debug.Flags...)
 	app.Flags = append(app.Flags, metricsFlags...)
+	app.Flags = append(app.Flags, recordFlags...)
 
 	app.Before = func(ctx *cli.Context) error {
 		return debug.Setup(ctx)
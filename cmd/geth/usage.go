@@ -36,8 +36,12 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.DataDirFlag,
 			utils.KeyStoreDirFlag,
 			utils.NetworkIdFlag,
+			utils.ObserveNetworksFlag,
+			utils.ObserveForkToleranceFlag,
+			utils.ObserveForkAheadFlag,
 			utils.MainnetFlag,
 			utils.SyncModeFlag,
+			utils.ObserverFlag,
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
 			utils.TxLookupLimitFlag,
@@ -129,6 +133,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Flags: []cli.Flag{
 			utils.BootnodesFlag,
 			utils.DNSDiscoveryFlag,
+			utils.DiscoveryTapFlag,
+			utils.DiscoveryTapRateFlag,
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
@@ -153,6 +159,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name:  "METRICS AND STATS",
 		Flags: metricsFlags,
 	},
+	{
+		Name:  "OBSERVATION RECORDS",
+		Flags: recordFlags,
+	},
 	{
 		Name: "MISC",
 		Flags: []cli.Flag{
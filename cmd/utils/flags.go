@@ -59,10 +59,12 @@ import (
 	"peerInfoCollect/metrics/influxdb"
 	"peerInfoCollect/node"
 	"peerInfoCollect/p2p"
+	"peerInfoCollect/p2p/disctap"
 	"peerInfoCollect/p2p/enode"
 	"peerInfoCollect/p2p/nat"
 	"peerInfoCollect/p2p/netutil"
 	"peerInfoCollect/params"
+	"peerInfoCollect/record"
 )
 
 func init() {
@@ -116,6 +118,20 @@ var (
 		Usage: "Explicitly set network id (integer)(For testnets: use --ropsten, --rinkeby, --goerli instead)",
 		Value: ethconfig.Defaults.NetworkId,
 	}
+	ObserveNetworksFlag = cli.StringFlag{
+		Name:  "observe.networks",
+		Usage: "Comma separated networks whose peers are accepted: mainnet, ropsten, rinkeby, goerli, sepolia or name:networkid:genesis",
+		Value: strings.Join(ethconfig.Defaults.ObserveNetworks, ","),
+	}
+	ObserveForkToleranceFlag = cli.IntFlag{
+		Name:  "observe.forktolerance",
+		Usage: "Number of forks peers may lag behind",
+		Value: ethconfig.Defaults.ObserveForkTolerance,
+	}
+	ObserveForkAheadFlag = cli.BoolFlag{
+		Name:  "observe.forkahead",
+		Usage: "Accept peers past forks announced by other peers but unknown locally",
+	}
 	MainnetFlag = cli.BoolFlag{
 		Name:  "mainnet",
 		Usage: "Ethereum mainnet",
@@ -158,9 +174,13 @@ var (
 	defaultSyncMode = ethconfig.Defaults.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("snap", "full" or "light")`,
+		Usage: `Blockchain sync mode ("snap", "full", "light" or "observer")`,
 		Value: &defaultSyncMode,
 	}
+	ObserverFlag = cli.BoolFlag{
+		Name:  "observer",
+		Usage: "Record the blocks announced by the network without importing them (same as --syncmode observer)",
+	}
 	GCModeFlag = cli.StringFlag{
 		Name:  "gcmode",
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
@@ -405,6 +425,77 @@ var (
 		Name:  "ethstats",
 		Usage: "Reporting URL of a ethstats service (nodename:secret@host:port)",
 	}
+	// Observation record settings
+	RecordRedisFlag = cli.StringFlag{
+		Name:  "record.redis",
+		Usage: "Redis server to publish observations to (redis://host:port/db or host:port, empty disables)",
+		Value: record.DefaultConfig.Redis,
+	}
+	RecordMongoFlag = cli.StringFlag{
+		Name:  "record.mongo",
+		Usage: "MongoDB server to store observations in (mongodb://host:port/db or host:port, empty disables)",
+		Value: record.DefaultConfig.Mongo,
+	}
+	RecordChannelsFlag = cli.StringFlag{
+		Name:  "record.channels",
+		Usage: "Comma separated list of channel renames (e.g. BlockInfo=staging.blocks,TxInfo=staging.txs)",
+	}
+	RecordEncodingFlag = cli.StringFlag{
+		Name:  "record.encoding",
+		Usage: `Wire format of published observations ("legacy", "json" or "rlp" envelopes)`,
+		Value: record.DefaultConfig.Encoding,
+	}
+	RecordCollectorFlag = cli.StringFlag{
+		Name:  "record.collector",
+		Usage: "Collector identifier stored in observation envelopes (default: host name)",
+	}
+	RecordDisableFlag = cli.BoolFlag{
+		Name:  "record.disable",
+		Usage: "Disable publishing of collected observations",
+	}
+	RecordQueueSizeFlag = cli.IntFlag{
+		Name:  "record.queue",
+		Usage: "Number of observations buffered for asynchronous publishing (0 = synchronous)",
+		Value: record.DefaultConfig.QueueSize,
+	}
+	RecordQueueWorkersFlag = cli.IntFlag{
+		Name:  "record.queue.workers",
+		Usage: "Number of goroutines publishing buffered observations",
+		Value: record.DefaultConfig.QueueWorkers,
+	}
+	RecordQueueBatchFlag = cli.IntFlag{
+		Name:  "record.queue.batch",
+		Usage: "Maximum number of observations published in one batch",
+		Value: record.DefaultConfig.QueueBatch,
+	}
+	RecordQueueDropFlag = cli.StringFlag{
+		Name:  "record.queue.drop",
+		Usage: `Policy when the observation queue is full ("newest", "oldest" or "block")`,
+		Value: record.DefaultConfig.QueueDrop,
+	}
+	RecordSpoolDirFlag = DirectoryFlag{
+		Name:  "record.spool",
+		Usage: "Directory for spooling observations while a sink is unreachable (relative to datadir, empty disables)",
+		Value: DirectoryString(record.DefaultConfig.SpoolDir),
+	}
+	RecordSpoolMaxSizeFlag = cli.IntFlag{
+		Name:  "record.spool.maxsize",
+		Usage: "Megabytes of spooled observations kept per sink before dropping the oldest",
+		Value: record.DefaultConfig.SpoolMaxSize,
+	}
+	RecordSpoolMaxAgeFlag = cli.DurationFlag{
+		Name:  "record.spool.maxage",
+		Usage: "Maximum age of spooled observations before they are dropped (0 = unlimited)",
+		Value: record.DefaultConfig.SpoolMaxAge,
+	}
+	RecordGeoCityFlag = cli.StringFlag{
+		Name:  "record.geo.city",
+		Usage: "MaxMind GeoLite2 City database for locating peers (relative to datadir, empty disables)",
+	}
+	RecordGeoASNFlag = cli.StringFlag{
+		Name:  "record.geo.asn",
+		Usage: "MaxMind GeoLite2 ASN database for resolving the network of peers (relative to datadir, empty disables)",
+	}
 	// RPC settings
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
@@ -511,6 +602,16 @@ var (
 		Name:  "discovery.dns",
 		Usage: "Sets DNS discovery entry points (use \"\" to disable DNS)",
 	}
+	DiscoveryTapFlag = cli.Float64Flag{
+		Name:  "discovery.tap",
+		Usage: "Fraction of discovery senders whose inbound packets are recorded (0 = disabled)",
+		Value: disctap.DefaultConfig.Sample,
+	}
+	DiscoveryTapRateFlag = cli.Float64Flag{
+		Name:  "discovery.tap.rate",
+		Usage: "Maximum number of discovery packets recorded per second",
+		Value: disctap.DefaultConfig.RateLimit,
+	}
 
 	// Gas price oracle settings
 	GpoBlocksFlag = cli.IntFlag{
@@ -893,6 +994,67 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	}
 }
 
+// SetRecordConfig applies record related command line flags to the config.
+func SetRecordConfig(ctx *cli.Context, cfg *record.Config) {
+	if ctx.GlobalIsSet(RecordRedisFlag.Name) {
+		cfg.Redis = ctx.GlobalString(RecordRedisFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordMongoFlag.Name) {
+		cfg.Mongo = ctx.GlobalString(RecordMongoFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordChannelsFlag.Name) {
+		channels, err := record.ParseChannels(ctx.GlobalString(RecordChannelsFlag.Name))
+		if err != nil {
+			Fatalf("Option %q: %v", RecordChannelsFlag.Name, err)
+		}
+		cfg.Channels = channels
+	}
+	if ctx.GlobalIsSet(RecordEncodingFlag.Name) {
+		encoding := ctx.GlobalString(RecordEncodingFlag.Name)
+		if _, err := record.ParseEncoding(encoding); err != nil {
+			Fatalf("Option %q: %v", RecordEncodingFlag.Name, err)
+		}
+		cfg.Encoding = encoding
+	}
+	if ctx.GlobalIsSet(RecordCollectorFlag.Name) {
+		cfg.Collector = ctx.GlobalString(RecordCollectorFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordDisableFlag.Name) {
+		cfg.Disable = ctx.GlobalBool(RecordDisableFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordQueueSizeFlag.Name) {
+		cfg.QueueSize = ctx.GlobalInt(RecordQueueSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordQueueWorkersFlag.Name) {
+		cfg.QueueWorkers = ctx.GlobalInt(RecordQueueWorkersFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordQueueBatchFlag.Name) {
+		cfg.QueueBatch = ctx.GlobalInt(RecordQueueBatchFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordQueueDropFlag.Name) {
+		drop := ctx.GlobalString(RecordQueueDropFlag.Name)
+		if _, err := record.ParseDropPolicy(drop); err != nil {
+			Fatalf("Option %q: %v", RecordQueueDropFlag.Name, err)
+		}
+		cfg.QueueDrop = drop
+	}
+	if ctx.GlobalIsSet(RecordSpoolDirFlag.Name) {
+		cfg.SpoolDir = ctx.GlobalString(RecordSpoolDirFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordSpoolMaxSizeFlag.Name) {
+		cfg.SpoolMaxSize = ctx.GlobalInt(RecordSpoolMaxSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordSpoolMaxAgeFlag.Name) {
+		cfg.SpoolMaxAge = ctx.GlobalDuration(RecordSpoolMaxAgeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordGeoCityFlag.Name) {
+		cfg.GeoCity = ctx.GlobalString(RecordGeoCityFlag.Name)
+	}
+	if ctx.GlobalIsSet(RecordGeoASNFlag.Name) {
+		cfg.GeoASN = ctx.GlobalString(RecordGeoASNFlag.Name)
+	}
+}
+
 // SetNodeConfig applies node-related command line flags to the config.
 func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	SetP2PConfig(ctx, &cfg.P2P)
@@ -900,6 +1062,7 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
+	setDiscoveryTap(ctx, cfg)
 
 	if ctx.GlobalIsSet(JWTSecretFlag.Name) {
 		cfg.JWTSecret = ctx.GlobalString(JWTSecretFlag.Name)
@@ -929,6 +1092,16 @@ func setDataDir(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setDiscoveryTap applies the discovery tap flags to the node config.
+func setDiscoveryTap(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(DiscoveryTapFlag.Name) {
+		cfg.DiscoveryTap.Sample = ctx.GlobalFloat64(DiscoveryTapFlag.Name)
+	}
+	if ctx.GlobalIsSet(DiscoveryTapRateFlag.Name) {
+		cfg.DiscoveryTap.RateLimit = ctx.GlobalFloat64(DiscoveryTapRateFlag.Name)
+	}
+}
+
 func setGPO(ctx *cli.Context, cfg *gasprice.Config, light bool) {
 	// If we are running the light client, apply another group
 	// settings for gas oracle.
@@ -1102,6 +1275,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setTxPool(ctx, &cfg.TxPool)
 	setEthash(ctx, cfg)
 	setPeerRequiredBlocks(ctx, cfg)
+	if ctx.GlobalIsSet(ObserveNetworksFlag.Name) {
+		cfg.ObserveNetworks = SplitAndTrim(ctx.GlobalString(ObserveNetworksFlag.Name))
+	}
+	if ctx.GlobalIsSet(ObserveForkToleranceFlag.Name) {
+		cfg.ObserveForkTolerance = ctx.GlobalInt(ObserveForkToleranceFlag.Name)
+	}
+	if ctx.GlobalIsSet(ObserveForkAheadFlag.Name) {
+		cfg.ObserveForkAhead = ctx.GlobalBool(ObserveForkAheadFlag.Name)
+	}
 
 	// Cap the cache allowance and tune the garbage collector
 	mem, err := gopsutil.VirtualMemory()
@@ -1126,6 +1308,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.GlobalBool(ObserverFlag.Name) {
+		cfg.SyncMode = downloader.ObserverSync
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
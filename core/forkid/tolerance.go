@@ -0,0 +1,150 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"errors"
+	"hash/crc32"
+	"math"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"peerInfoCollect/common"
+	"peerInfoCollect/params"
+)
+
+// ErrRemoteFuture is returned by a tolerant filter if the remote node applies
+// or expects a fork that is not part of the local fork rules.
+var ErrRemoteFuture = errors.New("remote ahead of local fork rules")
+
+const (
+	// maxAnnouncedForks is the number of fork checksums learned from remote
+	// announcements of forks unknown locally, the least recently announced
+	// ones are forgotten first.
+	maxAnnouncedForks = 64
+
+	// announceQuorum is the number of distinct remotes which must announce a
+	// fork unknown locally before remotes that passed it are told apart from
+	// incompatible chains.
+	announceQuorum = 3
+)
+
+// PeerFilter is a fork ID filter which is told the remote the ID belongs to.
+type PeerFilter func(peer string, id ID) error
+
+// Tolerance relaxes the fork ID validation for nodes that don't follow the
+// chain themselves but only estimate its head.
+type Tolerance struct {
+	Behind int  // Forks a remote may lag behind without announcing the next local fork
+	Ahead  bool // Accept remotes that passed a fork announced by other remotes but unknown locally
+}
+
+// NewTolerantFilter creates a filter validating fork IDs against the chain
+// configuration at the head reported by headfn. On top of the EIP-2124 rules,
+// it accepts remotes lagging up to the tolerated number of forks behind, and
+// learns the forks announced by remotes in the current fork state, so that
+// remotes which passed such a fork are told apart from incompatible chains.
+// A fork counts as announced once a quorum of distinct remotes announced it,
+// the announcements are forgotten when the local fork state changes.
+//
+// Rejections are reported as ErrRemoteStale, ErrRemoteFuture or, for chains
+// that diverged, ErrLocalIncompatibleOrStale.
+func NewTolerantFilter(config *params.ChainConfig, genesis common.Hash, headfn func() uint64, tolerance Tolerance) PeerFilter {
+	var (
+		forks = gatherForks(config)
+		crcs  = make([]uint32, len(forks)+1) // 0th is the genesis
+		sums  = make([][4]byte, len(forks)+1)
+	)
+	crcs[0] = crc32.ChecksumIEEE(genesis[:])
+	sums[0] = checksumToBytes(crcs[0])
+	for i, fork := range forks {
+		crcs[i+1] = checksumUpdate(crcs[i], fork)
+		sums[i+1] = checksumToBytes(crcs[i+1])
+	}
+	forks = append(forks, math.MaxUint64) // Last fork will never be passed
+
+	var (
+		lock         sync.Mutex
+		state        = -1                         // Local fork state the announcements were made in
+		announced, _ = lru.New(maxAnnouncedForks) // Checksums after forks only known remotely -> announcing remotes
+	)
+	return func(peer string, id ID) error {
+		head := headfn()
+
+		// Find the local fork state, the first unpassed fork block
+		i := 0
+		for head >= forks[i] {
+			i++
+		}
+		lock.Lock()
+		if state != i {
+			announced.Purge()
+			state = i
+		}
+		lock.Unlock()
+
+		// Same fork state, reject remotes expecting a fork we passed without
+		// applying it, and remember the forks remotes announce beyond ours.
+		if sums[i] == id.Hash {
+			if id.Next > 0 && id.Next != forks[i] {
+				if head >= id.Next {
+					return ErrRemoteFuture
+				}
+				sum := checksumToBytes(checksumUpdate(crcs[i], id.Next))
+				lock.Lock()
+				remotes, ok := announced.Get(sum)
+				if !ok {
+					remotes = make(map[string]struct{})
+					announced.Add(sum, remotes)
+				}
+				if set := remotes.(map[string]struct{}); len(set) < announceQuorum {
+					set[peer] = struct{}{}
+				}
+				lock.Unlock()
+			}
+			return nil
+		}
+		// Remote in a past fork state, accept it if it knows about the next fork
+		// or lags within the tolerance.
+		for j := 0; j < i; j++ {
+			if sums[j] == id.Hash {
+				if forks[j] == id.Next || i-j <= tolerance.Behind {
+					return nil
+				}
+				return ErrRemoteStale
+			}
+		}
+		// Remote in a future fork state known locally, our head estimate lags.
+		for j := i + 1; j < len(sums); j++ {
+			if sums[j] == id.Hash {
+				return nil
+			}
+		}
+		// Remote passed a fork only other remotes told us about.
+		lock.Lock()
+		remotes, ok := announced.Peek(id.Hash)
+		ok = ok && len(remotes.(map[string]struct{})) >= announceQuorum
+		lock.Unlock()
+		if ok {
+			if tolerance.Ahead {
+				return nil
+			}
+			return ErrRemoteFuture
+		}
+		return ErrLocalIncompatibleOrStale
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/params"
+)
+
+func TestTolerantFilter(t *testing.T) {
+	var (
+		config  = params.MainnetChainConfig
+		genesis = params.MainnetGenesisHash
+		head    = uint64(14_000_000)
+
+		london = NewID(config, genesis, 13_000_000) // One fork behind Arrow Glacier
+		berlin = NewID(config, genesis, 12_500_000) // Two forks behind Arrow Glacier
+		arrow  = NewID(config, genesis, head)
+
+		// Gray Glacier is not part of the local config, compute its checksum
+		// as a remote which applied it would announce it.
+		grayGlacier = ID{Hash: checksumToBytes(checksumUpdate(crcAt(config, genesis, head), 15_050_000))}
+	)
+
+	strict := NewTolerantFilter(config, genesis, func() uint64 { return head }, Tolerance{})
+	tolerant := NewTolerantFilter(config, genesis, func() uint64 { return head }, Tolerance{Behind: 1, Ahead: true})
+
+	tests := []struct {
+		filter PeerFilter
+		peer   string
+		id     ID
+		err    error
+	}{
+		// Same fork state, no future fork announced
+		{strict, "a", arrow, nil},
+		// Remote passed an unknown fork, not announced by anyone yet
+		{strict, "a", grayGlacier, ErrLocalIncompatibleOrStale},
+		{tolerant, "a", grayGlacier, ErrLocalIncompatibleOrStale},
+		// A single remote repeating an announcement doesn't teach the fork
+		{strict, "a", ID{Hash: arrow.Hash, Next: 15_050_000}, nil},
+		{strict, "a", ID{Hash: arrow.Hash, Next: 15_050_000}, nil},
+		{tolerant, "a", ID{Hash: arrow.Hash, Next: 15_050_000}, nil},
+		{tolerant, "b", ID{Hash: arrow.Hash, Next: 15_050_000}, nil},
+		{strict, "a", grayGlacier, ErrLocalIncompatibleOrStale},
+		{tolerant, "a", grayGlacier, ErrLocalIncompatibleOrStale},
+		// A quorum of remotes announces the unknown fork, both filters learn it
+		{strict, "b", ID{Hash: arrow.Hash, Next: 15_050_000}, nil},
+		{strict, "c", ID{Hash: arrow.Hash, Next: 15_050_000}, nil},
+		{tolerant, "c", ID{Hash: arrow.Hash, Next: 15_050_000}, nil},
+		{strict, "d", grayGlacier, ErrRemoteFuture},
+		{tolerant, "d", grayGlacier, nil},
+		// Remote one fork behind, aware or unaware of the next fork
+		{strict, "a", london, nil},
+		{strict, "a", ID{Hash: london.Hash}, ErrRemoteStale},
+		{tolerant, "a", ID{Hash: london.Hash}, nil},
+		// Remote two forks behind is stale even with tolerance
+		{tolerant, "a", ID{Hash: berlin.Hash}, ErrRemoteStale},
+		// Different chain altogether
+		{tolerant, "a", ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		if err := tt.filter(tt.peer, tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Once the local head passed the announced fork, remotes still expecting
+	// it are ahead of the local rules.
+	head = 15_100_000
+	if err := strict("a", ID{Hash: arrow.Hash, Next: 15_050_000}); err != ErrRemoteFuture {
+		t.Errorf("passed announced fork: have %v, want %v", err, ErrRemoteFuture)
+	}
+	// A lagging head estimate must not reject remotes in known future forks.
+	head = 0
+	if err := strict("a", arrow); err != nil {
+		t.Errorf("lagging head: have %v, want nil", err)
+	}
+	// Announcements are forgotten when the local fork state changes.
+	head = 14_000_000
+	if err := strict("d", grayGlacier); err != ErrLocalIncompatibleOrStale {
+		t.Errorf("announcement survived fork state change: have %v, want %v", err, ErrLocalIncompatibleOrStale)
+	}
+}
+
+func TestTolerantFilterAnnounceLimit(t *testing.T) {
+	var (
+		config  = params.MainnetChainConfig
+		genesis = params.MainnetGenesisHash
+		head    = uint64(14_000_000)
+		arrow   = NewID(config, genesis, head)
+		filter  = NewTolerantFilter(config, genesis, func() uint64 { return head }, Tolerance{Ahead: true})
+	)
+	announce := func(next uint64) {
+		for _, peer := range []string{"a", "b", "c"} {
+			filter(peer, ID{Hash: arrow.Hash, Next: next})
+		}
+	}
+	// Bogus announcements must not lock out a later real one.
+	for next := uint64(20_000_000); next < 20_000_000+2*maxAnnouncedForks; next++ {
+		announce(next)
+	}
+	announce(15_050_000)
+	grayGlacier := ID{Hash: checksumToBytes(checksumUpdate(crcAt(config, genesis, head), 15_050_000))}
+	if err := filter("d", grayGlacier); err != nil {
+		t.Fatalf("announced fork not learned: %v", err)
+	}
+}
+
+// crcAt returns the raw fork checksum of a chain at the given head.
+func crcAt(config *params.ChainConfig, genesis common.Hash, head uint64) uint32 {
+	hash := crc32.ChecksumIEEE(genesis[:])
+	for _, fork := range gatherForks(config) {
+		if fork <= head {
+			hash = checksumUpdate(hash, fork)
+		}
+	}
+	return hash
+}
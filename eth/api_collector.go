@@ -0,0 +1,238 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/hexutil"
+	"peerInfoCollect/eth/propagation"
+	"peerInfoCollect/node"
+	"peerInfoCollect/peerdb"
+	"peerInfoCollect/record"
+	"peerInfoCollect/rpc"
+)
+
+// observationBuffer is the number of observations buffered per subscription,
+// observations arriving while the buffer is full are dropped.
+const observationBuffer = 256
+
+var errUnknownPeer = errors.New("unknown peer")
+
+// CollectorAPI provides an API to query the peers and propagation data
+// gathered by the collector.
+type CollectorAPI struct {
+	e *Ethereum
+}
+
+// NewCollectorAPI creates a new collector API.
+func NewCollectorAPI(e *Ethereum) *CollectorAPI {
+	return &CollectorAPI{e}
+}
+
+// Peers returns the peers of the registry passing the filter, most recently
+// seen first.
+func (api *CollectorAPI) Peers(filter *peerdb.Filter) ([]*peerdb.Entry, error) {
+	return node.PeerRegistry.Query(filter)
+}
+
+// Peer returns the registry entry of a single peer.
+func (api *CollectorAPI) Peer(id string) (*peerdb.Entry, error) {
+	if e := node.PeerRegistry.Get(id); e != nil {
+		return e, nil
+	}
+	return nil, errUnknownPeer
+}
+
+// BlockSightings returns the propagation history of a block, or nil if the
+// block isn't tracked (anymore).
+func (api *CollectorAPI) BlockSightings(hash common.Hash) *propagation.Object {
+	return api.e.handler.propagation.BlockSightings(hash)
+}
+
+// TxSightings returns the propagation history of a transaction, or nil if
+// the transaction isn't tracked (anymore).
+func (api *CollectorAPI) TxSightings(hash common.Hash) *propagation.Object {
+	return api.e.handler.propagation.TxSightings(hash)
+}
+
+// NetworkStats is the state of an observed network.
+type NetworkStats struct {
+	Name  string       `json:"name"`
+	ID    uint64       `json:"id"`
+	Head  uint64       `json:"head"`  // highest block number announced by the network's peers
+	TD    *hexutil.Big `json:"td"`    // total difficulty of the mirrored status
+	Hash  common.Hash  `json:"hash"`  // head hash of the mirrored status
+	Peers int          `json:"peers"` // connected peers matched to the network
+}
+
+// CollectorStats is a summary of what the collector currently sees.
+type CollectorStats struct {
+	Peers         int            `json:"peers"`         // connected eth peers
+	SnapPeers     int            `json:"snapPeers"`     // connected peers also running snap
+	KnownPeers    int            `json:"knownPeers"`    // peers in the registry
+	TrackedBlocks int            `json:"trackedBlocks"` // blocks whose propagation is tracked
+	TrackedTxs    int            `json:"trackedTxs"`    // transactions whose propagation is tracked
+	Networks      []NetworkStats `json:"networks"`
+}
+
+// Stats returns a summary of what the collector currently sees.
+func (api *CollectorAPI) Stats() *CollectorStats {
+	h := api.e.handler
+	stats := &CollectorStats{
+		Peers:      h.peers.len(),
+		SnapPeers:  h.peers.snapLen(),
+		KnownPeers: node.PeerRegistry.Len(),
+		Networks:   make([]NetworkStats, 0, len(h.networks)),
+	}
+	stats.TrackedBlocks, stats.TrackedTxs = h.propagation.Stats()
+	for _, network := range h.networks {
+		ns := NetworkStats{
+			Name:  network.Name,
+			ID:    network.ID,
+			Head:  network.Head(),
+			TD:    (*hexutil.Big)(network.TD()),
+			Peers: h.peers.networkLen(network),
+		}
+		if status := network.Status(); status != nil {
+			ns.Hash = status.Head
+		}
+		stats.Networks = append(stats.Networks, ns)
+	}
+	return stats
+}
+
+// ObservationFilter selects the observations streamed to a subscription by
+// the peer they were observed from. Empty fields match every peer.
+type ObservationFilter struct {
+	PeerID string `json:"peerId"` // prefix of the node ID
+	Addr   string `json:"addr"`   // IP prefix, either a CIDR or a plain string prefix
+	Client string `json:"client"` // parsed client name, case-insensitive
+
+	addr *peerdb.AddrFilter
+}
+
+// init validates the filter and parses its address filter, if any.
+func (f *ObservationFilter) init() error {
+	if f.Addr != "" {
+		addr, err := peerdb.ParseAddrFilter(f.Addr)
+		if err != nil {
+			return err
+		}
+		f.addr = addr
+	}
+	return nil
+}
+
+// match reports whether an observation passes the filter.
+func (f *ObservationFilter) match(rec record.Record) bool {
+	if f == nil {
+		return true
+	}
+	id, addr := record.Origin(rec)
+	if f.PeerID != "" && !strings.HasPrefix(id, f.PeerID) {
+		return false
+	}
+	if f.addr != nil && !f.addr.Match(addr) {
+		return false
+	}
+	if f.Client != "" {
+		e := node.PeerRegistry.Get(id)
+		if e == nil || !strings.EqualFold(e.Client.Client, f.Client) {
+			return false
+		}
+	}
+	return true
+}
+
+// CollectorFeedAPI provides subscriptions streaming the observations of the
+// collector as they are published, each wrapped in its JSON envelope.
+type CollectorFeedAPI struct{}
+
+// NewCollectorFeedAPI creates a new collector subscription API.
+func NewCollectorFeedAPI() *CollectorFeedAPI {
+	return &CollectorFeedAPI{}
+}
+
+// Observations streams every observation of the collector.
+func (api *CollectorFeedAPI) Observations(ctx context.Context, filter *ObservationFilter) (*rpc.Subscription, error) {
+	return api.subscribe(ctx, filter)
+}
+
+// Blocks streams the blocks received from the collector's peers.
+func (api *CollectorFeedAPI) Blocks(ctx context.Context, filter *ObservationFilter) (*rpc.Subscription, error) {
+	return api.subscribe(ctx, filter, record.KindBlock)
+}
+
+// Txs streams the transactions received from the collector's peers.
+func (api *CollectorFeedAPI) Txs(ctx context.Context, filter *ObservationFilter) (*rpc.Subscription, error) {
+	return api.subscribe(ctx, filter, record.KindTx)
+}
+
+// Peers streams the connections, status handshakes and session ends of the
+// collector's peers.
+func (api *CollectorFeedAPI) Peers(ctx context.Context, filter *ObservationFilter) (*rpc.Subscription, error) {
+	return api.subscribe(ctx, filter, record.KindPeer, record.KindPeerStatus, record.KindPeerSession)
+}
+
+// subscribe creates a subscription streaming the observations of the given
+// kinds passing the filter. If no kinds are given, every kind is streamed.
+func (api *CollectorFeedAPI) subscribe(ctx context.Context, filter *ObservationFilter, kinds ...record.Kind) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if filter != nil {
+		if err := filter.init(); err != nil {
+			return nil, err
+		}
+	}
+	wanted := make(map[record.Kind]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	// Subscribe right away, observations published after the call returns
+	// must not be missed.
+	envelopes := make(chan *record.Envelope, observationBuffer)
+	sub := record.SubscribeEnvelopes(envelopes)
+
+	go func() {
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case env := <-envelopes:
+				if len(wanted) > 0 && !wanted[env.Kind] {
+					continue
+				}
+				if filter.match(env) {
+					notifier.Notify(rpcSub.ID, env)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"peerInfoCollect/node"
+	"peerInfoCollect/record"
+	"peerInfoCollect/rpc"
+)
+
+func TestObservationFilterMatch(t *testing.T) {
+	node.PeerRegistry.Connected("aa01", "1.2.3.4:30303", "Geth/v1.10.17-stable/linux-amd64/go1.18", nil, nil)
+
+	var (
+		v4      = &record.TxRecordInfo{PeerId: "aa01", PeerAddr: "1.2.3.4:30303"}
+		v6      = &record.TxRecordInfo{PeerId: "bb01", PeerAddr: "[2001:db8::1]:30303"}
+		noPort  = &record.TxRecordInfo{PeerId: "bb02", PeerAddr: "[2001:db8::2]"}
+		unowned = &record.PropagationRecordInfo{FirstPeer: "aa01"}
+	)
+	tests := []struct {
+		filter *ObservationFilter
+		rec    record.Record
+		want   bool
+	}{
+		{nil, unowned, true},
+		{&ObservationFilter{}, v4, true},
+		{&ObservationFilter{PeerID: "aa"}, v4, true},
+		{&ObservationFilter{PeerID: "aa"}, v6, false},
+		{&ObservationFilter{PeerID: "aa"}, unowned, false},
+
+		// Plain prefixes are compared against the IP, without port or brackets.
+		{&ObservationFilter{Addr: "1.2."}, v4, true},
+		{&ObservationFilter{Addr: "1.3."}, v4, false},
+		{&ObservationFilter{Addr: "2001:"}, v6, true},
+		{&ObservationFilter{Addr: "2001:"}, noPort, true},
+		{&ObservationFilter{Addr: "2001:"}, v4, false},
+		{&ObservationFilter{Addr: "1."}, unowned, false},
+
+		// CIDRs
+		{&ObservationFilter{Addr: "1.2.0.0/16"}, v4, true},
+		{&ObservationFilter{Addr: "1.3.0.0/16"}, v4, false},
+		{&ObservationFilter{Addr: "2001:db8::/32"}, v6, true},
+		{&ObservationFilter{Addr: "2001:db8::/32"}, noPort, true},
+		{&ObservationFilter{Addr: "2001:db8::/32"}, v4, false},
+		{&ObservationFilter{Addr: "0.0.0.0/0"}, unowned, false},
+
+		// Clients are looked up in the peer registry.
+		{&ObservationFilter{Client: "GETH"}, v4, true},
+		{&ObservationFilter{Client: "besu"}, v4, false},
+		{&ObservationFilter{Client: "geth"}, v6, false},
+	}
+	for i, tt := range tests {
+		if tt.filter != nil {
+			if err := tt.filter.init(); err != nil {
+				t.Fatalf("test %d: %v", i, err)
+			}
+		}
+		if have := tt.filter.match(tt.rec); have != tt.want {
+			t.Errorf("test %d: filter %+v matched %T: have %v, want %v", i, tt.filter, tt.rec, have, tt.want)
+		}
+	}
+	if err := (&ObservationFilter{Addr: "1.2.3.4/99"}).init(); err == nil {
+		t.Error("invalid CIDR accepted")
+	}
+}
+
+func TestCollectorSubscribe(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("collector", NewCollectorFeedAPI()); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	ch := make(chan json.RawMessage, 10)
+	filter := &ObservationFilter{Addr: "1.2.0.0/16"}
+	sub, err := client.Subscribe(context.Background(), "collector", ch, "blocks", filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// Other kinds and records not tied to a peer must be dropped.
+	record.Publish(&record.TxRecordInfo{PeerId: "aa01", PeerAddr: "1.2.3.4:30303"})
+	record.Publish(&record.PropagationRecordInfo{FirstPeer: "aa01"})
+	record.Publish(&record.BlockRecordInfo{BlockNum: 1, PeerId: "bb01", PeerAddress: "5.6.7.8:30303"})
+	record.Publish(&record.BlockRecordInfo{BlockNum: 2, PeerId: "aa01", PeerAddress: "1.2.3.4:30303"})
+
+	select {
+	case blob := <-ch:
+		env, err := record.DecodeEnvelope(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block, ok := env.Payload.(*record.BlockRecordInfo); !ok || block.BlockNum != 2 {
+			t.Fatalf("wrong observation streamed: %s", blob)
+		}
+	case err := <-sub.Err():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("observation not streamed")
+	}
+	select {
+	case blob := <-ch:
+		t.Fatalf("unexpected observation streamed: %s", blob)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
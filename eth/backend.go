@@ -33,6 +33,7 @@ import (
 	"peerInfoCollect/consensus/clique"
 	"peerInfoCollect/core"
 	"peerInfoCollect/core/bloombits"
+	"peerInfoCollect/core/forkid"
 	"peerInfoCollect/core/rawdb"
 	"peerInfoCollect/core/state/pruner"
 	"peerInfoCollect/core/types"
@@ -109,6 +110,14 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
+	tolerance := forkid.Tolerance{Behind: config.ObserveForkTolerance, Ahead: config.ObserveForkAhead}
+	networks, err := eth.ParseNetworks(config.ObserveNetworks, tolerance)
+	if err != nil {
+		return nil, err
+	}
+	if len(networks) == 0 {
+		return nil, errors.New("no networks to observe")
+	}
 
 	if config.NoPruning && config.TrieDirtyCache > 0 {
 		if config.SnapshotCache > 0 {
@@ -216,6 +225,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		TxPool:             eth.txPool,
 		Merger:             merger,
 		Network:            config.NetworkId,
+		Networks:           networks,
 		Sync:               config.SyncMode,
 		BloomCache:         uint64(cacheLimit),
 		EventMux:           eth.eventMux,
@@ -300,7 +310,17 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   filters.NewPublicFilterAPI(s.APIBackend, false, 5*time.Minute),
 			Public:    true,
-		},  {
+		}, {
+			Namespace: "collector",
+			Version:   "1.0",
+			Service:   NewCollectorAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "collector",
+			Version:   "1.0",
+			Service:   NewCollectorFeedAPI(),
+			Public:    true,
+		}, {
 			Namespace: "net",
 			Version:   "1.0",
 			Service:   s.netRPCService,
@@ -17,6 +17,7 @@
 package downloader
 
 import (
+	"net"
 	"peerInfoCollect/log"
 	"peerInfoCollect/node"
 	"peerInfoCollect/record"
@@ -27,6 +28,17 @@ import (
 	"peerInfoCollect/eth/protocols/eth"
 )
 
+// headerObserver is implemented by peers keeping the headers they deliver around,
+// to be served to other peers.
+type headerObserver interface {
+	ObserveHeaders(headers []*types.Header)
+}
+
+// remoteAddresser is implemented by peers backed by a network connection.
+type remoteAddresser interface {
+	RemoteAddr() net.Addr
+}
+
 // fetchHeadersByHash is a blocking version of Peer.RequestHeadersByHash which
 // handles all the cancellation, interruption and timeout mechanisms of a data
 // retrieval to allow blocking API calls.
@@ -66,29 +78,14 @@ func (d *Downloader) fetchHeadersByHash(p *peerConnection, hash common.Hash, amo
 		for _, v := range *res.Res.(*eth.BlockHeadersPacket) {
 			p.log.Info("blockHeaderPacket info", "num", v.Number.Uint64(), "hash", v.Hash().String())
 			//modify by echo
-			//this place do record to mongo and redis
-			var ipinfo string
-			data, ok := node.PeerInfoCache.Get(p.id)
+			//this place do record to the configured sinks (redis, mongo, ...)
+			ipinfo, ok := node.PeerRegistry.Addr(p.id)
 			if !ok {
-				ipinfo = "127.0.0.1"
-			} else {
-				ipinfo = data.(string)
+				if addr, ok := p.peer.(remoteAddresser); ok {
+					ipinfo = addr.RemoteAddr().String()
+				}
 			}
-			////to mongo db record
-			//rec := &record.RecordInfo{
-			//	BlockNum:    v.Number.Uint64(),
-			//	BlockHash:   v.Hash().String(),
-			//	PeerId:      p.id,
-			//	PeerAddress: ipinfo,
-			//}
-			//record.InsertInfo(record.MgoCnn, rec)
-
-			//mongo db record
-			//if record.MgoCnn == nil {
-			//	record.NewConnectionWithDBName("blockRecord", "blockinfo")
-			//}
-
-			//to redis
+			//to the record sinks
 			headData, _ := v.MarshalJSON()
 
 			recb := &record.BlockRecordInfo{
@@ -100,15 +97,16 @@ func (d *Downloader) fetchHeadersByHash(p *peerConnection, hash common.Hash, amo
 				PeerAddress: ipinfo,
 			}
 
-			rd, _ := recb.Encode()
 			p.log.Info("发送信息区块--","num",v.Number.Uint64(),"hash",v.Hash().String(),"peer id",p.id,"peer address",ipinfo)
-			//redis record
-			err := record.PubMessage(record.RdbClient,record.ChanBlockID,string(rd))
+			err := record.Publish(recb)
 			if err != nil {
 				log.Error("pub message err", "err", err.Error())
 			}
 		}
 
+		if observer, ok := p.peer.(headerObserver); ok {
+			observer.ObserveHeaders(*res.Res.(*eth.BlockHeadersPacket))
+		}
 		// Don't reject the packet even if it turns out to be bad, downloader will
 		// disconnect the peer on its own terms. Simply delivery the headers to
 		// be processed by the caller
@@ -154,6 +152,9 @@ func (d *Downloader) fetchHeadersByNumber(p *peerConnection, number uint64, amou
 		headerReqTimer.Update(time.Since(start))
 		headerInMeter.Mark(int64(len(*res.Res.(*eth.BlockHeadersPacket))))
 
+		if observer, ok := p.peer.(headerObserver); ok {
+			observer.ObserveHeaders(*res.Res.(*eth.BlockHeadersPacket))
+		}
 		// Don't reject the packet even if it turns out to be bad, downloader will
 		// disconnect the peer on its own terms. Simply delivery the headers to
 		// be processed by the caller
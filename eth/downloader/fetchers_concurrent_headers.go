@@ -85,6 +85,9 @@ func (q *headerQueue) deliver(peer *peerConnection, packet *eth.Response) (int,
 	hashes := packet.Meta.([]common.Hash)
 
 	accepted, err := q.queue.DeliverHeaders(peer.id, headers, hashes, q.headerProcCh)
+	if observer, ok := peer.peer.(headerObserver); ok && err == nil {
+		observer.ObserveHeaders(headers)
+	}
 	switch {
 	case err == nil && len(headers) == 0:
 		peer.log.Trace("Requested headers delivered")
@@ -23,13 +23,14 @@ import "fmt"
 type SyncMode uint32
 
 const (
-	FullSync  SyncMode = iota // Synchronise the entire blockchain history from full blocks
-	SnapSync                  // Download the chain and the state via compact snapshots
-	LightSync                 // Download only the headers and terminate afterwards
+	FullSync     SyncMode = iota // Synchronise the entire blockchain history from full blocks
+	SnapSync                     // Download the chain and the state via compact snapshots
+	LightSync                    // Download only the headers and terminate afterwards
+	ObserverSync                 // Never synchronise, only record what the network announces
 )
 
 func (mode SyncMode) IsValid() bool {
-	return mode >= FullSync && mode <= LightSync
+	return mode >= FullSync && mode <= ObserverSync
 }
 
 // String implements the stringer interface.
@@ -41,6 +42,8 @@ func (mode SyncMode) String() string {
 		return "snap"
 	case LightSync:
 		return "light"
+	case ObserverSync:
+		return "observer"
 	default:
 		return "unknown"
 	}
@@ -54,6 +57,8 @@ func (mode SyncMode) MarshalText() ([]byte, error) {
 		return []byte("snap"), nil
 	case LightSync:
 		return []byte("light"), nil
+	case ObserverSync:
+		return []byte("observer"), nil
 	default:
 		return nil, fmt.Errorf("unknown sync mode %d", mode)
 	}
@@ -67,8 +72,10 @@ func (mode *SyncMode) UnmarshalText(text []byte) error {
 		*mode = SnapSync
 	case "light":
 		*mode = LightSync
+	case "observer":
+		*mode = ObserverSync
 	default:
-		return fmt.Errorf(`unknown sync mode %q, want "full", "snap" or "light"`, text)
+		return fmt.Errorf(`unknown sync mode %q, want "full", "snap", "light" or "observer"`, text)
 	}
 	return nil
 }
@@ -72,6 +72,7 @@ var Defaults = Config{
 		DatasetsLockMmap: false,
 	},
 	NetworkId:               1,
+	ObserveNetworks:         []string{"mainnet"},
 	TxLookupLimit:           2350000,
 	DatabaseCache:           512,
 	TrieCleanCache:          154,
@@ -121,6 +122,18 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 
+	// ObserveNetworks lists the networks whose peers are accepted and mirrored,
+	// either by name (mainnet, sepolia, ...) or as name:networkid:genesis.
+	ObserveNetworks []string `toml:",omitempty"`
+
+	// ObserveForkTolerance is the number of forks a peer may lag behind the
+	// observed networks.
+	ObserveForkTolerance int `toml:",omitempty"`
+
+	// ObserveForkAhead accepts peers which passed a fork announced by other
+	// peers but unknown locally.
+	ObserveForkAhead bool `toml:",omitempty"`
+
 	// This can be set to list of enrtree:// URLs which will be queried for
 	// for nodes to connect to.
 	EthDiscoveryURLs  []string
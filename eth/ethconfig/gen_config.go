@@ -19,6 +19,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
 		Genesis                         *core.Genesis `toml:",omitempty"`
 		NetworkId                       uint64
+		ObserveNetworks                 []string `toml:",omitempty"`
+		ObserveForkTolerance            int      `toml:",omitempty"`
+		ObserveForkAhead                bool     `toml:",omitempty"`
 		SyncMode                        downloader.SyncMode
 		EthDiscoveryURLs                []string
 		SnapDiscoveryURLs               []string
@@ -54,6 +57,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	var enc Config
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
+	enc.ObserveNetworks = c.ObserveNetworks
+	enc.ObserveForkTolerance = c.ObserveForkTolerance
+	enc.ObserveForkAhead = c.ObserveForkAhead
 	enc.SyncMode = c.SyncMode
 	enc.EthDiscoveryURLs = c.EthDiscoveryURLs
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
@@ -93,6 +99,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
 		Genesis                         *core.Genesis `toml:",omitempty"`
 		NetworkId                       *uint64
+		ObserveNetworks                 []string `toml:",omitempty"`
+		ObserveForkTolerance            *int     `toml:",omitempty"`
+		ObserveForkAhead                *bool    `toml:",omitempty"`
 		SyncMode                        *downloader.SyncMode
 		EthDiscoveryURLs                []string
 		SnapDiscoveryURLs               []string
@@ -135,6 +144,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NetworkId != nil {
 		c.NetworkId = *dec.NetworkId
 	}
+	if dec.ObserveNetworks != nil {
+		c.ObserveNetworks = dec.ObserveNetworks
+	}
+	if dec.ObserveForkTolerance != nil {
+		c.ObserveForkTolerance = *dec.ObserveForkTolerance
+	}
+	if dec.ObserveForkAhead != nil {
+		c.ObserveForkAhead = *dec.ObserveForkAhead
+	}
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
@@ -32,6 +32,7 @@ import (
 	"peerInfoCollect/core/types"
 	"peerInfoCollect/eth/downloader"
 	"peerInfoCollect/eth/fetcher"
+	"peerInfoCollect/eth/propagation"
 	"peerInfoCollect/eth/protocols/eth"
 	"peerInfoCollect/eth/protocols/snap"
 	"peerInfoCollect/ethdb"
@@ -82,6 +83,7 @@ type handlerConfig struct {
 	TxPool     txPool                    // Transaction pool to propagate from
 	Merger     *consensus.Merger         // The manager for eth1/2 transition
 	Network    uint64                    // Network identifier to adfvertise
+	Networks   eth.Networks              // Networks whose peers are accepted and mirrored
 	Sync       downloader.SyncMode       // Whether to snap or full sync
 	BloomCache uint64                    // Megabytes to alloc for snap sync bloom
 	EventMux   *event.TypeMux            // Legacy event mux, deprecate for `feed`
@@ -93,6 +95,7 @@ type handlerConfig struct {
 type handler struct {
 	networkID  uint64
 	forkFilter forkid.Filter // Fork ID filter, constant across the lifetime of the node
+	networks   eth.Networks  // Networks whose peers are accepted and mirrored
 
 	snapSync  uint32 // Flag whether snap sync is enabled (gets disabled if we already have blocks)
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
@@ -110,6 +113,9 @@ type handler struct {
 	txFetcher    *fetcher.TxFetcher
 	peers        *peerSet
 	merger       *consensus.Merger
+	propagation  *propagation.Tracker
+	announces    *propagation.Announces
+	observer     *blockObserver // Records announced blocks in observer mode, nil otherwise
 
 	eventMux      *event.TypeMux
 	txsCh         chan core.NewTxsEvent
@@ -134,6 +140,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	h := &handler{
 		networkID:          config.Network,
 		forkFilter:         forkid.NewFilter(config.Chain),
+		networks:           config.Networks,
 		eventMux:           config.EventMux,
 		database:           config.Database,
 		txpool:             config.TxPool,
@@ -141,9 +148,18 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		peers:              newPeerSet(),
 		merger:             config.Merger,
 		peerRequiredBlocks: config.PeerRequiredBlocks,
+		propagation:        propagation.New(propagation.DefaultConfig, nil, nil),
+		announces:          propagation.NewAnnounces(propagation.DefaultAnnounceConfig, nil, nil),
 		quitSync:           make(chan struct{}),
 	}
-	if config.Sync == downloader.FullSync {
+	switch config.Sync {
+	case downloader.ObserverSync:
+		// Nothing is ever synchronised nor imported, so there's no sync to wait
+		// for before recording the transactions of the network.
+		h.observer = newBlockObserver(h)
+		h.acceptTxs = uint32(1)
+
+	case downloader.FullSync:
 		// The database seems empty as the current block is the genesis. Yet the snap
 		// block is ahead, so snap sync was enabled for this node at a certain point.
 		// The scenarios where this can happen is
@@ -157,7 +173,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 			h.snapSync = uint32(1)
 			log.Warn("Switch sync mode from full sync to snap sync")
 		}
-	} else {
+	default:
 		if h.chain.CurrentBlock().NumberU64() > 0 {
 			// Print warning log if database is not empty to run snap sync.
 			log.Warn("Switch sync mode from snap sync to full sync")
@@ -316,19 +332,13 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 	h.peerWG.Add(1)
 	defer h.peerWG.Done()
 
-	// Execute the Ethereum handshake
-	var (
-		genesis = h.chain.Genesis()
-		head    = h.chain.CurrentHeader()
-		hash    = head.Hash()
-		number  = head.Number.Uint64()
-		td      = h.chain.GetTd(hash, number)
-	)
-	forkID := forkid.NewID(h.chain.Config(), h.chain.Genesis().Hash(), h.chain.CurrentHeader().Number.Uint64())
-	if err := peer.Handshake(h.networkID, td, hash, genesis.Hash(), forkID, h.forkFilter); err != nil {
+	// Execute the Ethereum handshake, mirroring the status of the peer's network
+	network, err := peer.MirrorHandshake(h.networks)
+	if err != nil {
 		peer.Log().Debug("Ethereum handshake failed", "err", err)
 		return err
 	}
+	peer.Log().Debug("Ethereum peer joined network", "network", network)
 	reject := false // reserved peer slots
 	if atomic.LoadUint32(&h.snapSync) == 1 {
 		if snap == nil {
@@ -370,6 +380,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 			return err
 		}
 	}
+	peer.TrackSession()
 	h.chainSync.handlePeerEvent(peer)
 
 	// Propagate existing transactions. new transactions appearing
@@ -453,6 +464,10 @@ func (h *handler) Start(maxPeers int) {
 	// start sync handlers
 	h.wg.Add(1)
 	go h.chainSync.loop()
+
+	// start tracking block and transaction propagation
+	h.propagation.Start()
+	h.announces.Start()
 }
 
 func (h *handler) Stop() {
@@ -469,7 +484,10 @@ func (h *handler) Stop() {
 	h.peers.close()
 	h.peerWG.Wait()
 
-	//record.MgoCli.Disconnect(context.TODO())
+	// Emit the propagation summaries of everything still tracked
+	h.propagation.Stop()
+	h.announces.Stop()
+
 	log.Info("Ethereum protocol stopped")
 }
 
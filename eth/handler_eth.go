@@ -21,6 +21,7 @@ import (
 	"peerInfoCollect/common"
 	"peerInfoCollect/core"
 	"peerInfoCollect/core/types"
+	"peerInfoCollect/eth/propagation"
 	"peerInfoCollect/eth/protocols/eth"
 	"peerInfoCollect/log"
 	"peerInfoCollect/node"
@@ -65,18 +66,36 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 	switch packet := packet.(type) {
 	case *eth.NewBlockHashesPacket:
 		hashes, numbers := packet.Unpack()
-
-		_,ok := node.PeerInfoCache.Get(peer.ID())
-		if !ok {
-			node.PeerInfoCache.Add(peer.ID(),peer.RemoteAddr().String())
+		for i, hash := range hashes {
+			h.propagation.Block(hash, numbers[i], peer.ID(), propagation.NewBlockHashes)
+			h.announces.BlockAnnounce(peer.ID(), peer.RemoteAddr().String(), hash, numbers[i])
+		}
+		if network := peer.Network(); network != nil && len(numbers) > 0 {
+			highest := numbers[0]
+			for _, number := range numbers[1:] {
+				if number > highest {
+					highest = number
+				}
+			}
+			network.SetHead(peer.ID(), highest)
 		}
+
+		node.PeerRegistry.Seen(peer.ID(), peer.RemoteAddr().String())
 		return h.handleBlockAnnounces(peer, hashes, numbers)
 
 	case *eth.NewBlockPacket:
 		log.Info("收到新的区块信息---","区块num",packet.Block.NumberU64(),"区块hash",packet.Block.Hash().String(),
 			"peer id",peer.ID(),"peer ip",peer.RemoteAddr().String(),
 		)
-		node.BlockHashCache.Add(packet.Block.Hash(), struct {}{})
+		h.propagation.Block(packet.Block.Hash(), packet.Block.NumberU64(), peer.ID(), propagation.NewBlock)
+		h.announces.BlockPush(peer.ID(), peer.RemoteAddr().String(), packet.Block.Hash(), packet.Block.NumberU64())
+		peer.ObserveHeaders([]*types.Header{packet.Block.Header()})
+		if network := peer.Network(); network != nil {
+			network.SetHead(peer.ID(), packet.Block.NumberU64())
+			if err := network.MirrorHead(peer.ID(), packet.Block.Hash(), packet.TD); err != nil {
+				return err
+			}
+		}
 
 		//to redis
 		headData,_ := packet.Block.Header().MarshalJSON()
@@ -90,8 +109,7 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 			PeerAddress: peer.RemoteAddr().String(),
 		}
 
-		rd,_ := recb.Encode()
-		err := record.PubMessage(record.RdbClient,record.ChanBlockID,string(rd))
+		err := record.Publish(recb)
 		if err != nil {
 			log.Error("pub message","err",err.Error())
 		}
@@ -99,37 +117,48 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 		return h.handleBlockBroadcast(peer, packet.Block, packet.TD)
 
 	case *eth.NewPooledTransactionHashesPacket:
+		for _, hash := range *packet {
+			h.propagation.Tx(hash, peer.ID(), propagation.NewPooledTransactionHashes)
+		}
+		h.announces.TxAnnounce(peer.ID(), peer.RemoteAddr().String(), *packet)
 		return h.txFetcher.Notify(peer.ID(), *packet)
 
 	case *eth.TransactionsPacket:
+		// Broadcast bodies resolve pending announcements just like fetched ones
+		received := make([]common.Hash, 0, len(*packet))
 		for _,v := range *packet {
+			received = append(received, v.Hash())
 			log.Info("新的交易信息---","tx hash",v.Hash().String())
+			h.propagation.Tx(v.Hash(), peer.ID(), propagation.Transactions)
 			txData,_ := v.MarshalJSON()
-			td := record.TxRecordInfo{
+			td := &record.TxRecordInfo{
 				TxHash: v.Hash().String(),
 				Payload: string(txData),
 				PeerId: peer.ID(),
 				PeerAddr: peer.RemoteAddr().String(),
 			}
 
-			data,_  := td.Encode()
-			record.PubMessage(record.RdbClient,record.ChanTxID,string(data))
+			record.Publish(td)
 		}
+		h.announces.TxFetched(peer.ID(), received)
 		return h.txFetcher.Enqueue(peer.ID(), *packet, false)
 
 	case *eth.PooledTransactionsPacket:
+		fetched := make([]common.Hash, 0, len(*packet))
 		for _,v := range *packet{
+			fetched = append(fetched, v.Hash())
 			log.Info("收到了通过交易哈希获取的交易--","tx hash",v.Hash())
+			h.propagation.Tx(v.Hash(), peer.ID(), propagation.PooledTransactions)
 			txData,_ := v.MarshalJSON()
-			td := record.TxRecordInfo{
+			td := &record.TxRecordInfo{
 				TxHash: v.Hash().String(),
 				Payload: string(txData),
 				PeerId: peer.ID(),
 				PeerAddr: peer.RemoteAddr().String(),
 			}
-			data,_  := td.Encode()
-			record.PubMessage(record.RdbClient,record.ChanTxID,string(data))
+			record.Publish(td)
 		}
+		h.announces.TxFetched(peer.ID(), fetched)
 		return h.txFetcher.Enqueue(peer.ID(), *packet, true)
 
 	default:
@@ -148,6 +177,13 @@ func (h *ethHandler) handleBlockAnnounces(peer *eth.Peer, hashes []common.Hash,
 		return nil
 		// return errors.New("unexpected block announces")
 	}
+	// Observers record the announced blocks instead of importing them
+	if h.observer != nil {
+		for i := range hashes {
+			h.observer.announced(peer, hashes[i], numbers[i])
+		}
+		return nil
+	}
 	// Schedule all the unknown hashes for retrieval
 	var (
 		unknownHashes  = make([]common.Hash, 0, len(hashes))
@@ -181,6 +217,9 @@ func (h *ethHandler) handleBlockBroadcast(peer *eth.Peer, block *types.Block, td
 		// return errors.New("unexpected block announces")
 	}
 
+	if h.observer != nil {
+		h.observer.propagated(block)
+	}
 	//
 	//// Schedule the block for import
 	//h.blockFetcher.Enqueue(peer.ID(), block)
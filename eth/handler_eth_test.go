@@ -41,6 +41,44 @@ import (
 	"peerInfoCollect/rlp"
 )
 
+// handshakeHandler runs the remote side of the eth handshake against a handler
+// over a message pipe: the status of the chain at the given head is sent first,
+// then the status mirrored back by the handler is consumed.
+func handshakeHandler(rw p2p.MsgReadWriter, version uint, chain *core.BlockChain, head *types.Block) error {
+	status := &eth.StatusPacket{
+		ProtocolVersion: uint32(version),
+		NetworkID:       1,
+		TD:              chain.GetTd(head.Hash(), head.NumberU64()),
+		Head:            head.Hash(),
+		Genesis:         chain.Genesis().Hash(),
+		ForkID:          forkid.NewIDWithChain(chain),
+	}
+	errc := make(chan error, 1)
+	go func() {
+		if err := p2p.Send(rw, eth.StatusMsg, status); err != nil {
+			errc <- err
+			return
+		}
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer msg.Discard()
+
+		if msg.Code != eth.StatusMsg {
+			err = fmt.Errorf("first message has code %x, want status", msg.Code)
+		}
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(5 * time.Second):
+		return p2p.DiscReadTimeout
+	}
+}
+
 // testEthHandler is a mock event handler to listen for inbound network requests
 // on the `eth` protocol and convert them into a more easily testable form.
 type testEthHandler struct {
@@ -266,12 +304,8 @@ func testRecvTransactions(t *testing.T, protocol uint) {
 		return eth.Handle((*ethHandler)(handler.handler), peer)
 	})
 	// Run the handshake locally to avoid spinning up a source handler
-	var (
-		genesis = handler.chain.Genesis()
-		head    = handler.chain.CurrentBlock()
-		td      = handler.chain.GetTd(head.Hash(), head.NumberU64())
-	)
-	if err := src.Handshake(1, td, head.Hash(), genesis.Hash(), forkid.NewIDWithChain(handler.chain), forkid.NewFilter(handler.chain)); err != nil {
+	head := handler.chain.CurrentBlock()
+	if err := handshakeHandler(p2pSrc, protocol, handler.chain, head); err != nil {
 		t.Fatalf("failed to run protocol handshake")
 	}
 	// Send the transaction to the sink and verify that it's added to the tx pool
@@ -327,12 +361,8 @@ func testSendTransactions(t *testing.T, protocol uint) {
 		return eth.Handle((*ethHandler)(handler.handler), peer)
 	})
 	// Run the handshake locally to avoid spinning up a source handler
-	var (
-		genesis = handler.chain.Genesis()
-		head    = handler.chain.CurrentBlock()
-		td      = handler.chain.GetTd(head.Hash(), head.NumberU64())
-	)
-	if err := sink.Handshake(1, td, head.Hash(), genesis.Hash(), forkid.NewIDWithChain(handler.chain), forkid.NewFilter(handler.chain)); err != nil {
+	head := handler.chain.CurrentBlock()
+	if err := handshakeHandler(p2pSink, protocol, handler.chain, head); err != nil {
 		t.Fatalf("failed to run protocol handshake")
 	}
 	// After the handshake completes, the source handler should stream the sink
@@ -534,12 +564,8 @@ func testCheckpointChallenge(t *testing.T, syncmode downloader.SyncMode, checkpo
 	}()
 
 	// Run the handshake locally to avoid spinning up a remote handler.
-	var (
-		genesis = handler.chain.Genesis()
-		head    = handler.chain.CurrentBlock()
-		td      = handler.chain.GetTd(head.Hash(), head.NumberU64())
-	)
-	if err := remote.Handshake(1, td, head.Hash(), genesis.Hash(), forkid.NewIDWithChain(handler.chain), forkid.NewFilter(handler.chain)); err != nil {
+	head := handler.chain.CurrentBlock()
+	if err := handshakeHandler(p2pRemote, eth.ETH66, handler.chain, head); err != nil {
 		t.Fatalf("failed to run protocol handshake")
 	}
 	// Connect a new peer and check that we receive the checkpoint challenge.
@@ -618,10 +644,7 @@ func testBroadcastBlock(t *testing.T, peers, bcasts int) {
 		sinks[i] = new(testEthHandler)
 	}
 	// Interconnect all the sink handlers with the source handler
-	var (
-		genesis = source.chain.Genesis()
-		td      = source.chain.GetTd(genesis.Hash(), genesis.NumberU64())
-	)
+	genesis := source.chain.Genesis()
 	for i, sink := range sinks {
 		sink := sink // Closure for gorotuine below
 
@@ -637,7 +660,7 @@ func testBroadcastBlock(t *testing.T, peers, bcasts int) {
 		go source.handler.runEthPeer(sourcePeer, func(peer *eth.Peer) error {
 			return eth.Handle((*ethHandler)(source.handler), peer)
 		})
-		if err := sinkPeer.Handshake(1, td, genesis.Hash(), genesis.Hash(), forkid.NewIDWithChain(source.chain), forkid.NewFilter(source.chain)); err != nil {
+		if err := handshakeHandler(sinkPipe, eth.ETH66, source.chain, genesis); err != nil {
 			t.Fatalf("failed to run protocol handshake")
 		}
 		go eth.Handle(sink, sinkPeer)
@@ -705,11 +728,8 @@ func testBroadcastMalformedBlock(t *testing.T, protocol uint) {
 		return eth.Handle((*ethHandler)(source.handler), peer)
 	})
 	// Run the handshake locally to avoid spinning up a sink handler
-	var (
-		genesis = source.chain.Genesis()
-		td      = source.chain.GetTd(genesis.Hash(), genesis.NumberU64())
-	)
-	if err := sink.Handshake(1, td, genesis.Hash(), genesis.Hash(), forkid.NewIDWithChain(source.chain), forkid.NewFilter(source.chain)); err != nil {
+	genesis := source.chain.Genesis()
+	if err := handshakeHandler(p2pSink, protocol, source.chain, genesis); err != nil {
 		t.Fatalf("failed to run protocol handshake")
 	}
 	// After the handshake completes, the source handler should stream the sink
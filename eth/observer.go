@@ -0,0 +1,137 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"peerInfoCollect/common"
+	"peerInfoCollect/core/types"
+	"peerInfoCollect/eth/protocols/eth"
+	"peerInfoCollect/log"
+	"peerInfoCollect/node"
+	"peerInfoCollect/record"
+)
+
+const (
+	// observedBlocks is the number of recent block hashes the observer remembers
+	// to request and relay every block only once.
+	observedBlocks = 4096
+
+	// observeTimeout is the maximum allotted time for a peer to return the
+	// header of a block it announced.
+	observeTimeout = 5 * time.Second
+)
+
+// blockObserver replaces the block fetcher and the downloader in observer
+// mode. It records the header of every block announced by the network and
+// relays the announcement, but never imports anything into the local chain.
+type blockObserver struct {
+	handler *handler
+	seen    *lru.Cache // Hashes of the blocks already requested or received
+}
+
+func newBlockObserver(h *handler) *blockObserver {
+	seen, _ := lru.New(observedBlocks)
+	return &blockObserver{handler: h, seen: seen}
+}
+
+// announced is invoked for a block hash announced by a peer. The header of a
+// new block is requested from the announcing peer, once across all peers.
+func (o *blockObserver) announced(peer *eth.Peer, hash common.Hash, number uint64) {
+	if ok, _ := o.seen.ContainsOrAdd(hash, struct{}{}); ok {
+		return
+	}
+	go o.fetchHeader(peer, hash, number)
+}
+
+// propagated is invoked for a block propagated by a peer, which has already
+// been recorded by the handler.
+func (o *blockObserver) propagated(block *types.Block) {
+	if ok, _ := o.seen.ContainsOrAdd(block.Hash(), struct{}{}); ok {
+		return
+	}
+	o.relay(block)
+}
+
+// fetchHeader retrieves the header of an announced block, records it and relays
+// the announcement to the peers which don't know about it yet.
+func (o *blockObserver) fetchHeader(peer *eth.Peer, hash common.Hash, number uint64) {
+	resCh := make(chan *eth.Response)
+
+	req, err := peer.RequestOneHeader(hash, resCh)
+	if err != nil {
+		o.seen.Remove(hash)
+		return
+	}
+	defer req.Close()
+
+	timeout := time.NewTimer(observeTimeout)
+	defer timeout.Stop()
+
+	select {
+	case res := <-resCh:
+		res.Done <- nil
+
+		headers := *res.Res.(*eth.BlockHeadersPacket)
+		if len(headers) != 1 || headers[0].Hash() != hash || headers[0].Number.Uint64() != number {
+			peer.Log().Debug("Announced block header not delivered", "number", number, "hash", hash)
+			o.seen.Remove(hash)
+			return
+		}
+		peer.ObserveHeaders(headers)
+		o.record(peer, headers[0])
+		o.relay(types.NewBlockWithHeader(headers[0]))
+
+	case <-timeout.C:
+		peer.Log().Debug("Announced block header timed out", "number", number, "hash", hash)
+		o.seen.Remove(hash)
+
+	case <-o.handler.quitSync:
+	}
+}
+
+// record publishes an observed block header to the record sinks.
+func (o *blockObserver) record(peer *eth.Peer, header *types.Header) {
+	addr, ok := node.PeerRegistry.Addr(peer.ID())
+	if !ok {
+		addr = peer.RemoteAddr().String()
+	}
+	data, _ := header.MarshalJSON()
+
+	rec := &record.BlockRecordInfo{
+		BlockNum:    header.Number.Uint64(),
+		BlockHash:   header.Hash().String(),
+		Data:        string(data),
+		Timestamp:   time.Now().String(),
+		PeerId:      peer.ID(),
+		PeerAddress: addr,
+	}
+	if err := record.Publish(rec); err != nil {
+		log.Error("Failed to publish observed header", "err", err)
+	}
+}
+
+// relay announces an observed block to all peers not yet knowing about it.
+func (o *blockObserver) relay(block *types.Block) {
+	peers := o.handler.peers.peersWithoutBlock(block.Hash())
+	for _, peer := range peers {
+		peer.AsyncSendNewBlockHash(block)
+	}
+	log.Trace("Relayed block announcement", "hash", block.Hash(), "recipients", len(peers))
+}
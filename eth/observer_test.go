@@ -0,0 +1,112 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"peerInfoCollect/consensus"
+	"peerInfoCollect/consensus/ethash"
+	"peerInfoCollect/core"
+	"peerInfoCollect/core/rawdb"
+	"peerInfoCollect/core/vm"
+	"peerInfoCollect/eth/downloader"
+	"peerInfoCollect/eth/protocols/eth"
+	"peerInfoCollect/p2p"
+	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/params"
+)
+
+// Tests that an observer records and relays the blocks of the network, but
+// never synchronises nor imports them.
+func TestObserverMode(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	(&core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddr: {Balance: big.NewInt(1000000)}},
+	}).MustCommit(db)
+
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	handler, err := newHandler(&handlerConfig{
+		Database:   db,
+		Chain:      chain,
+		TxPool:     newTestTxPool(),
+		Merger:     consensus.NewMerger(rawdb.NewMemoryDatabase()),
+		Network:    1,
+		Sync:       downloader.ObserverSync,
+		BloomCache: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.Start(1000)
+	defer (&testHandler{chain: chain, handler: handler}).close()
+
+	if handler.observer == nil || atomic.LoadUint32(&handler.snapSync) != 0 || atomic.LoadUint32(&handler.acceptTxs) != 1 {
+		t.Fatal("observer mode not enabled")
+	}
+	// Connect a remote peer to observe the blocks from
+	localPipe, remotePipe := p2p.MsgPipe()
+	defer localPipe.Close()
+	defer remotePipe.Close()
+
+	peer := eth.NewPeer(eth.ETH66, p2p.NewPeerPipe(enode.ID{1}, "", nil, localPipe), localPipe, nil)
+	defer peer.Close()
+	if err := handler.peers.registerPeer(peer, nil); err != nil {
+		t.Fatal(err)
+	}
+	handler.chainSync.forced = true
+	if op := handler.chainSync.nextSyncOp(); op != nil {
+		t.Fatalf("observer scheduled a sync with %s", op.peer.ID())
+	}
+	// Propagated blocks are relayed as announcements, but not imported
+	blocks, _ := core.GenerateChain(params.TestChainConfig, chain.Genesis(), ethash.NewFaker(), db, 2, nil)
+
+	handler.observer.propagated(blocks[0])
+	handler.observer.propagated(blocks[0])
+
+	msg, err := remotePipe.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var anns eth.NewBlockHashesPacket
+	if err := msg.Decode(&anns); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Code != eth.NewBlockHashesMsg || len(anns) != 1 || anns[0].Hash != blocks[0].Hash() {
+		t.Fatalf("wrong relay: code %d, %v", msg.Code, anns)
+	}
+	// Announced blocks have their header requested once from the announcer
+	handler.observer.announced(peer, blocks[1].Hash(), 2)
+	handler.observer.announced(peer, blocks[1].Hash(), 2)
+
+	if msg, err = remotePipe.ReadMsg(); err != nil {
+		t.Fatal(err)
+	}
+	var req eth.GetBlockHeadersPacket66
+	if err := msg.Decode(&req); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Code != eth.GetBlockHeadersMsg || req.Origin.Hash != blocks[1].Hash() || req.Amount != 1 {
+		t.Fatalf("wrong header request: code %d, %+v", msg.Code, req.GetBlockHeadersPacket)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 0 {
+		t.Fatalf("observer imported blocks: head %d", head)
+	}
+}
@@ -221,6 +221,20 @@ func (ps *peerSet) len() int {
 	return len(ps.peers)
 }
 
+// networkLen returns the number of `eth` peers matched to an observed network.
+func (ps *peerSet) networkLen(network *eth.Network) int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	n := 0
+	for _, p := range ps.peers {
+		if p.Network() == network {
+			n++
+		}
+	}
+	return n
+}
+
 // snapLen returns if the current number of `snap` peers in the set.
 func (ps *peerSet) snapLen() int {
 	ps.lock.RLock()
@@ -0,0 +1,439 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package propagation
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/mclock"
+	"peerInfoCollect/record"
+)
+
+// AnnounceConfig contains the limits of the announcement tracker.
+type AnnounceConfig struct {
+	Timeout      time.Duration // Time a transaction announcement waits for its hashes to be fetched
+	MaxAnnounces int           // Maximum number of transaction announcements tracked at once
+	MaxHashes    int           // Maximum number of transaction hashes tracked at once
+	BlockTimeout time.Duration // Time a block announcement waits for the same peer's NewBlock
+	MaxBlocks    int           // Maximum number of block announcements tracked at once
+}
+
+// DefaultAnnounceConfig contains the default announcement tracker limits.
+var DefaultAnnounceConfig = AnnounceConfig{
+	Timeout:      time.Minute,
+	MaxAnnounces: 16384,
+	MaxHashes:    65536,
+	BlockTimeout: 30 * time.Second,
+	MaxBlocks:    4096,
+}
+
+// txAnnounce is a single NewPooledTransactionHashes announcement waiting for
+// the bodies of its hashes to be fetched.
+type txAnnounce struct {
+	peer    string
+	addr    string
+	time    time.Time
+	arrived mclock.AbsTime
+
+	hashes  []common.Hash
+	fetched []string        // Peer each body was fetched from, empty while unknown
+	delays  []time.Duration // Time between the announcement and the fetch
+	pending int             // Number of hashes not fetched yet
+
+	elem *list.Element
+}
+
+// summary converts the announcement into its record representation.
+func (ann *txAnnounce) summary() *record.TxAnnounceRecordInfo {
+	rec := &record.TxAnnounceRecordInfo{
+		PeerId:   ann.peer,
+		PeerAddr: ann.addr,
+		Time:     uint64(ann.time.UnixNano()),
+		Hashes:   make([]record.TxAnnounceHash, len(ann.hashes)),
+	}
+	for i, hash := range ann.hashes {
+		outcome := record.TxAnnounceHash{
+			Hash:        hash.String(),
+			Fetched:     record.FetchedNone,
+			FetchedFrom: ann.fetched[i],
+			Delay:       uint64(ann.delays[i]),
+		}
+		switch ann.fetched[i] {
+		case "":
+		case ann.peer:
+			outcome.Fetched = record.FetchedSelf
+		default:
+			outcome.Fetched = record.FetchedOther
+		}
+		rec.Hashes[i] = outcome
+	}
+	return rec
+}
+
+// blockKey identifies the arrival of a block from a peer.
+type blockKey struct {
+	peer string
+	hash common.Hash
+}
+
+// blockAnnounce is the first arrival of a block from a peer. It is kept until
+// it times out, so that later messages of the peer about the same block are
+// deduplicated against it.
+type blockAnnounce struct {
+	blockKey
+	addr    string
+	number  uint64
+	time    time.Time
+	arrived mclock.AbsTime
+	mode    string        // How the block arrived first
+	pushed  bool          // Whether an announced block was pushed afterwards
+	delay   time.Duration // Time between the announcement and the push
+	emitted bool          // Whether the observation was handed out already
+
+	elem *list.Element
+}
+
+// summary converts the block arrival into its record representation.
+func (ann *blockAnnounce) summary() *record.BlockAnnounceRecordInfo {
+	return &record.BlockAnnounceRecordInfo{
+		PeerId:    ann.peer,
+		PeerAddr:  ann.addr,
+		Hash:      ann.hash.String(),
+		Number:    ann.number,
+		Time:      uint64(ann.time.UnixNano()),
+		Mode:      ann.mode,
+		Pushed:    ann.pushed,
+		PushDelay: uint64(ann.delay),
+	}
+}
+
+// announceRef points at a hash within an announcement.
+type announceRef struct {
+	ann   *txAnnounce
+	index int
+}
+
+// Announces tracks hash announcements until the announced bodies have been
+// fetched, recording for every transaction hash whether it was retrieved from
+// the announcing peer, from another one or not at all, and for every block
+// whether the peer announced it first or pushed it right away.
+type Announces struct {
+	cfg   AnnounceConfig
+	clock mclock.Clock
+	emit  func(record.Record)
+
+	lock    sync.Mutex
+	txs     map[common.Hash][]announceRef // Unfetched hashes and their announcements
+	txOrder *list.List                    // Announcements in arrival order
+	txCount int                           // Hashes held by the tracked announcements
+
+	blocks     map[blockKey]*blockAnnounce // Block arrivals per peer
+	blockOrder *list.List                  // Block arrivals in arrival order
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewAnnounces creates an announcement tracker. If emit is nil, finished
+// announcements are published to the record sinks.
+func NewAnnounces(cfg AnnounceConfig, clock mclock.Clock, emit func(record.Record)) *Announces {
+	if clock == nil {
+		clock = mclock.System{}
+	}
+	if emit == nil {
+		emit = func(rec record.Record) { record.Publish(rec) }
+	}
+	return &Announces{
+		cfg:        cfg,
+		clock:      clock,
+		emit:       emit,
+		txs:        make(map[common.Hash][]announceRef),
+		txOrder:    list.New(),
+		blocks:     make(map[blockKey]*blockAnnounce),
+		blockOrder: list.New(),
+		quit:       make(chan struct{}),
+	}
+}
+
+// Start launches the background loop emitting timed out announcements.
+func (a *Announces) Start() {
+	a.wg.Add(1)
+	go a.loop()
+}
+
+// Stop terminates the background loop and emits every announcement still
+// tracked.
+func (a *Announces) Stop() {
+	close(a.quit)
+	a.wg.Wait()
+
+	a.lock.Lock()
+	finished := a.evict(func(*txAnnounce) bool { return true })
+	blocks := a.evictBlocks(func(*blockAnnounce) bool { return true })
+	a.lock.Unlock()
+
+	a.emitAll(finished)
+	a.emitBlocks(blocks)
+}
+
+func (a *Announces) loop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.Expire()
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+// TxAnnounce records a transaction hash announcement of a peer. The oldest
+// announcements are evicted while too many announcements or hashes are
+// tracked, hashes beyond the limit of a single announcement are dropped.
+func (a *Announces) TxAnnounce(peer string, addr string, hashes []common.Hash) {
+	if len(hashes) == 0 {
+		return
+	}
+	if a.cfg.MaxHashes > 0 && len(hashes) > a.cfg.MaxHashes {
+		hashes = hashes[:a.cfg.MaxHashes]
+	}
+	ann := &txAnnounce{
+		peer:    peer,
+		addr:    addr,
+		time:    time.Now(),
+		arrived: a.clock.Now(),
+		hashes:  append([]common.Hash(nil), hashes...),
+		fetched: make([]string, len(hashes)),
+		delays:  make([]time.Duration, len(hashes)),
+		pending: len(hashes),
+	}
+	a.lock.Lock()
+	for i, hash := range ann.hashes {
+		a.txs[hash] = append(a.txs[hash], announceRef{ann: ann, index: i})
+	}
+	ann.elem = a.txOrder.PushBack(ann)
+	a.txCount += len(ann.hashes)
+
+	finished := a.evict(func(*txAnnounce) bool {
+		return (a.cfg.MaxAnnounces > 0 && a.txOrder.Len() > a.cfg.MaxAnnounces) ||
+			(a.cfg.MaxHashes > 0 && a.txCount > a.cfg.MaxHashes)
+	})
+	a.lock.Unlock()
+
+	a.emitAll(finished)
+}
+
+// TxFetched records that the bodies of the given hashes were retrieved from
+// a peer. Every announcement waiting for one of the hashes is resolved.
+func (a *Announces) TxFetched(peer string, hashes []common.Hash) {
+	now := a.clock.Now()
+
+	a.lock.Lock()
+	var finished []*txAnnounce
+	for _, hash := range hashes {
+		refs, ok := a.txs[hash]
+		if !ok {
+			continue
+		}
+		delete(a.txs, hash)
+		for _, ref := range refs {
+			ann := ref.ann
+			ann.fetched[ref.index] = peer
+			ann.delays[ref.index] = time.Duration(now - ann.arrived)
+			if ann.pending--; ann.pending == 0 {
+				a.txOrder.Remove(ann.elem)
+				a.txCount -= len(ann.hashes)
+				finished = append(finished, ann)
+			}
+		}
+	}
+	a.lock.Unlock()
+
+	a.emitAll(finished)
+}
+
+// BlockAnnounce records a NewBlockHashes announcement of a block. The
+// observation is held back until the same peer pushes the block or the
+// announcement times out. Repeated announcements are ignored.
+func (a *Announces) BlockAnnounce(peer string, addr string, hash common.Hash, number uint64) {
+	a.lock.Lock()
+	key := blockKey{peer: peer, hash: hash}
+	if _, ok := a.blocks[key]; ok {
+		a.lock.Unlock()
+		return
+	}
+	evicted := a.addBlock(&blockAnnounce{
+		blockKey: key,
+		addr:     addr,
+		number:   number,
+		time:     time.Now(),
+		arrived:  a.clock.Now(),
+		mode:     record.AnnounceHash,
+	})
+	a.lock.Unlock()
+
+	a.emitBlocks(evicted)
+}
+
+// BlockPush records a NewBlock message. If the peer announced the block
+// before, the push is folded into the announcement, otherwise the peer is
+// recorded as pushing the block right away.
+func (a *Announces) BlockPush(peer string, addr string, hash common.Hash, number uint64) {
+	var emit []*blockAnnounce
+
+	a.lock.Lock()
+	key := blockKey{peer: peer, hash: hash}
+	if ann, ok := a.blocks[key]; ok {
+		if !ann.emitted {
+			ann.pushed, ann.delay, ann.emitted = true, time.Duration(a.clock.Now()-ann.arrived), true
+			if ann.number == 0 {
+				ann.number = number
+			}
+			emit = append(emit, ann)
+		}
+	} else {
+		ann := &blockAnnounce{
+			blockKey: key,
+			addr:     addr,
+			number:   number,
+			time:     time.Now(),
+			arrived:  a.clock.Now(),
+			mode:     record.AnnounceBlock,
+			emitted:  true,
+		}
+		emit = append(a.addBlock(ann), ann)
+	}
+	a.lock.Unlock()
+
+	a.emitBlocks(emit)
+}
+
+// addBlock inserts a block arrival, evicting the oldest one if the limit is
+// exceeded. The lock must be held.
+func (a *Announces) addBlock(ann *blockAnnounce) []*blockAnnounce {
+	a.blocks[ann.blockKey] = ann
+	ann.elem = a.blockOrder.PushBack(ann)
+
+	if a.cfg.MaxBlocks > 0 && a.blockOrder.Len() > a.cfg.MaxBlocks {
+		return a.evictBlocks(func(*blockAnnounce) bool { return a.blockOrder.Len() > a.cfg.MaxBlocks })
+	}
+	return nil
+}
+
+// Expire emits every announcement older than the configured timeout.
+func (a *Announces) Expire() {
+	now := a.clock.Now()
+
+	a.lock.Lock()
+	finished := a.evict(func(ann *txAnnounce) bool {
+		return time.Duration(now-ann.arrived) >= a.cfg.Timeout
+	})
+	blocks := a.evictBlocks(func(ann *blockAnnounce) bool {
+		return time.Duration(now-ann.arrived) >= a.cfg.BlockTimeout
+	})
+	a.lock.Unlock()
+
+	a.emitAll(finished)
+	a.emitBlocks(blocks)
+}
+
+// evict removes announcements from the front of the queue while they match.
+// The lock must be held.
+func (a *Announces) evict(match func(*txAnnounce) bool) []*txAnnounce {
+	var finished []*txAnnounce
+	for elem := a.txOrder.Front(); elem != nil; elem = a.txOrder.Front() {
+		ann := elem.Value.(*txAnnounce)
+		if !match(ann) {
+			break
+		}
+		a.finish(ann)
+		finished = append(finished, ann)
+	}
+	return finished
+}
+
+// evictBlocks removes block arrivals from the front of the queue while they
+// match and returns those not emitted yet. The lock must be held.
+func (a *Announces) evictBlocks(match func(*blockAnnounce) bool) []*blockAnnounce {
+	var evicted []*blockAnnounce
+	for elem := a.blockOrder.Front(); elem != nil; elem = a.blockOrder.Front() {
+		ann := elem.Value.(*blockAnnounce)
+		if !match(ann) {
+			break
+		}
+		a.blockOrder.Remove(elem)
+		delete(a.blocks, ann.blockKey)
+		if !ann.emitted {
+			ann.emitted = true
+			evicted = append(evicted, ann)
+		}
+	}
+	return evicted
+}
+
+// finish drops an announcement and its unfetched hashes from the tracker.
+// The lock must be held.
+func (a *Announces) finish(ann *txAnnounce) {
+	a.txOrder.Remove(ann.elem)
+	a.txCount -= len(ann.hashes)
+	for i, hash := range ann.hashes {
+		if ann.fetched[i] != "" {
+			continue
+		}
+		refs := a.txs[hash]
+		for j := 0; j < len(refs); j++ {
+			if refs[j].ann == ann {
+				refs = append(refs[:j], refs[j+1:]...)
+				j--
+			}
+		}
+		if len(refs) == 0 {
+			delete(a.txs, hash)
+		} else {
+			a.txs[hash] = refs
+		}
+	}
+}
+
+func (a *Announces) emitAll(finished []*txAnnounce) {
+	for _, ann := range finished {
+		a.emit(ann.summary())
+	}
+}
+
+func (a *Announces) emitBlocks(anns []*blockAnnounce) {
+	for _, ann := range anns {
+		a.emit(ann.summary())
+	}
+}
+
+// Stats returns the number of block arrivals and transaction announcements
+// currently tracked.
+func (a *Announces) Stats() (blocks int, txs int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.blockOrder.Len(), a.txOrder.Len()
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package propagation
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/mclock"
+	"peerInfoCollect/record"
+)
+
+func newTestAnnounces(cfg AnnounceConfig) (*Announces, *mclock.Simulated, *[]record.Record) {
+	var (
+		clock   = new(mclock.Simulated)
+		emitted []record.Record
+	)
+	announces := NewAnnounces(cfg, clock, func(rec record.Record) {
+		emitted = append(emitted, rec)
+	})
+	return announces, clock, &emitted
+}
+
+func TestTxAnnounceFetch(t *testing.T) {
+	announces, clock, emitted := newTestAnnounces(DefaultAnnounceConfig)
+
+	var (
+		tx1 = common.HexToHash("0x01")
+		tx2 = common.HexToHash("0x02")
+		tx3 = common.HexToHash("0x03")
+	)
+	announces.TxAnnounce("peer-a", "1.2.3.4:30303", []common.Hash{tx1, tx2})
+	announces.TxAnnounce("peer-b", "5.6.7.8:30303", []common.Hash{tx2, tx3})
+
+	// Fetching tx2 from peer-a resolves it for both announcements.
+	clock.Run(100 * time.Millisecond)
+	announces.TxFetched("peer-a", []common.Hash{tx2})
+	if len(*emitted) != 0 {
+		t.Fatalf("announcements emitted early: %d", len(*emitted))
+	}
+	clock.Run(100 * time.Millisecond)
+	announces.TxFetched("peer-b", []common.Hash{tx1})
+	if len(*emitted) != 1 {
+		t.Fatalf("have %d emitted announcements, want 1", len(*emitted))
+	}
+	rec := (*emitted)[0].(*record.TxAnnounceRecordInfo)
+	want := []record.TxAnnounceHash{
+		{Hash: tx1.String(), Fetched: record.FetchedOther, FetchedFrom: "peer-b", Delay: uint64(200 * time.Millisecond)},
+		{Hash: tx2.String(), Fetched: record.FetchedSelf, FetchedFrom: "peer-a", Delay: uint64(100 * time.Millisecond)},
+	}
+	if rec.PeerId != "peer-a" || rec.PeerAddr != "1.2.3.4:30303" || len(rec.Hashes) != len(want) {
+		t.Fatalf("wrong announcement: %+v", rec)
+	}
+	for i := range want {
+		if rec.Hashes[i] != want[i] {
+			t.Errorf("hash %d: have %+v, want %+v", i, rec.Hashes[i], want[i])
+		}
+	}
+	// The announcement of peer-b times out with tx3 never fetched.
+	clock.Run(DefaultAnnounceConfig.Timeout)
+	announces.Expire()
+	if len(*emitted) != 2 {
+		t.Fatalf("have %d emitted announcements, want 2", len(*emitted))
+	}
+	rec = (*emitted)[1].(*record.TxAnnounceRecordInfo)
+	if rec.PeerId != "peer-b" || rec.Hashes[0].Fetched != record.FetchedOther || rec.Hashes[1].Fetched != record.FetchedNone {
+		t.Fatalf("wrong announcement: %+v", rec)
+	}
+	if _, n := announces.Stats(); n != 0 {
+		t.Fatalf("have %d tracked announcements, want 0", n)
+	}
+	if len(announces.txs) != 0 {
+		t.Fatalf("leaked %d unfetched hashes", len(announces.txs))
+	}
+}
+
+func TestTxAnnounceLimit(t *testing.T) {
+	announces, _, emitted := newTestAnnounces(AnnounceConfig{Timeout: time.Minute, MaxAnnounces: 2})
+
+	for i := 1; i <= 3; i++ {
+		announces.TxAnnounce("peer", "", []common.Hash{common.BigToHash(common.Big1), common.HexToHash(string(rune('0' + i)))})
+	}
+	if _, n := announces.Stats(); len(*emitted) != 1 || n != 2 {
+		t.Fatalf("wrong eviction: emitted %d, tracked %d", len(*emitted), n)
+	}
+	if refs := announces.txs[common.BigToHash(common.Big1)]; len(refs) != 2 {
+		t.Fatalf("evicted announcement still indexed: %d refs", len(refs))
+	}
+	announces.Stop()
+	if len(*emitted) != 3 || len(announces.txs) != 0 {
+		t.Fatalf("announcements not flushed on stop: emitted %d", len(*emitted))
+	}
+}
+
+func TestTxAnnounceHashLimit(t *testing.T) {
+	announces, _, emitted := newTestAnnounces(AnnounceConfig{Timeout: time.Minute, MaxHashes: 4})
+
+	hashes := func(from, n int) []common.Hash {
+		var hashes []common.Hash
+		for i := from; i < from+n; i++ {
+			hashes = append(hashes, common.BigToHash(big.NewInt(int64(i))))
+		}
+		return hashes
+	}
+	announces.TxAnnounce("peer-a", "", hashes(0, 2))
+	announces.TxAnnounce("peer-b", "", hashes(2, 2))
+	if len(*emitted) != 0 {
+		t.Fatalf("announcements evicted within the limit: %d", len(*emitted))
+	}
+	// Exceeding the hash limit evicts the oldest announcement.
+	announces.TxAnnounce("peer-c", "", hashes(4, 1))
+	if _, n := announces.Stats(); len(*emitted) != 1 || n != 2 || announces.txCount != 3 {
+		t.Fatalf("wrong eviction: emitted %d, tracked %d, hashes %d", len(*emitted), n, announces.txCount)
+	}
+	if rec := (*emitted)[0].(*record.TxAnnounceRecordInfo); rec.PeerId != "peer-a" {
+		t.Fatalf("evicted %s, want peer-a", rec.PeerId)
+	}
+	// Oversized announcements are truncated to the limit and evict the rest.
+	announces.TxAnnounce("peer-d", "", hashes(8, 16))
+	if _, n := announces.Stats(); len(*emitted) != 3 || n != 1 || announces.txCount != 4 || len(announces.txs) != 4 {
+		t.Fatalf("wrong eviction: emitted %d, tracked %d, hashes %d", len(*emitted), n, announces.txCount)
+	}
+	// Fetched announcements release their hashes.
+	announces.TxFetched("peer-d", hashes(8, 4))
+	if _, n := announces.Stats(); len(*emitted) != 4 || n != 0 || announces.txCount != 0 {
+		t.Fatalf("wrong release: emitted %d, tracked %d, hashes %d", len(*emitted), n, announces.txCount)
+	}
+}
+
+func TestBlockAnnounce(t *testing.T) {
+	announces, clock, emitted := newTestAnnounces(DefaultAnnounceConfig)
+
+	var (
+		block  = common.HexToHash("0x01")
+		other  = common.HexToHash("0x02")
+		blocks = func() []*record.BlockAnnounceRecordInfo {
+			var recs []*record.BlockAnnounceRecordInfo
+			for _, rec := range *emitted {
+				recs = append(recs, rec.(*record.BlockAnnounceRecordInfo))
+			}
+			return recs
+		}
+	)
+	// peer-a announces first and pushes later, peer-b pushes right away and
+	// announces afterwards, peer-c only announces.
+	announces.BlockAnnounce("peer-a", "1.2.3.4:30303", block, 100)
+	announces.BlockAnnounce("peer-a", "1.2.3.4:30303", block, 100)
+	announces.BlockAnnounce("peer-c", "9.9.9.9:30303", other, 101)
+	clock.Run(250 * time.Millisecond)
+	announces.BlockPush("peer-b", "5.6.7.8:30303", block, 100)
+	announces.BlockPush("peer-a", "1.2.3.4:30303", block, 100)
+	announces.BlockAnnounce("peer-b", "5.6.7.8:30303", block, 100)
+	announces.BlockPush("peer-a", "1.2.3.4:30303", block, 100)
+
+	recs := blocks()
+	if len(recs) != 2 {
+		t.Fatalf("have %d observations, want 2", len(recs))
+	}
+	if rec := recs[0]; rec.PeerId != "peer-b" || rec.Mode != record.AnnounceBlock || rec.Pushed || rec.Number != 100 {
+		t.Errorf("wrong push observation: %+v", rec)
+	}
+	if rec := recs[1]; rec.PeerId != "peer-a" || rec.Mode != record.AnnounceHash || !rec.Pushed || rec.PushDelay != uint64(250*time.Millisecond) {
+		t.Errorf("wrong announce observation: %+v", rec)
+	}
+	// The bare announcement is emitted once it times out, the others are
+	// forgotten silently.
+	clock.Run(DefaultAnnounceConfig.BlockTimeout)
+	announces.Expire()
+	recs = blocks()
+	if len(recs) != 3 {
+		t.Fatalf("have %d observations, want 3", len(recs))
+	}
+	if rec := recs[2]; rec.PeerId != "peer-c" || rec.Hash != other.String() || rec.Mode != record.AnnounceHash || rec.Pushed {
+		t.Errorf("wrong timed out observation: %+v", rec)
+	}
+	if n, _ := announces.Stats(); n != 0 {
+		t.Fatalf("have %d tracked blocks, want 0", n)
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package propagation tracks how blocks and transactions spread across the
+// peers of the collector.
+package propagation
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/mclock"
+	"peerInfoCollect/record"
+)
+
+// Message is the type of the eth message an object was seen in.
+type Message string
+
+const (
+	NewBlockHashes             Message = "NewBlockHashes"
+	NewBlock                   Message = "NewBlock"
+	Transactions               Message = "Transactions"
+	PooledTransactions         Message = "PooledTransactions"
+	NewPooledTransactionHashes Message = "NewPooledTransactionHashes"
+)
+
+// expireInterval is the time between two scans for aged out objects.
+const expireInterval = time.Second
+
+// Config contains the limits of the tracker.
+type Config struct {
+	BlockAge     time.Duration // Time after the first sighting a block summary is emitted
+	TxAge        time.Duration // Time after the first sighting a transaction summary is emitted
+	MaxBlocks    int           // Maximum number of blocks tracked at once
+	MaxTxs       int           // Maximum number of transactions tracked at once
+	MaxSightings int           // Maximum number of sightings kept per object
+}
+
+// DefaultConfig contains the default tracker limits.
+var DefaultConfig = Config{
+	BlockAge:     time.Minute,
+	TxAge:        time.Minute,
+	MaxBlocks:    1024,
+	MaxTxs:       65536,
+	MaxSightings: 256,
+}
+
+// Sighting is a single observation of an object from a peer.
+type Sighting struct {
+	Peer    string        `json:"peer"`
+	Message Message       `json:"message"`
+	Delay   time.Duration `json:"delay"` // Time elapsed since the first sighting
+}
+
+// Object is the propagation history of a single block or transaction.
+type Object struct {
+	Hash      common.Hash `json:"hash"`
+	Number    uint64      `json:"number,omitempty"` // Block number, zero for transactions
+	FirstSeen time.Time   `json:"firstSeen"`
+	Sightings []Sighting  `json:"sightings"` // Sightings in arrival order, the first has zero delay
+	Dropped   int         `json:"dropped"`   // Sightings beyond the tracking limit
+
+	first mclock.AbsTime
+}
+
+// copy returns a deep copy of the object, safe to hand out of the tracker.
+func (o *Object) copy() *Object {
+	cpy := *o
+	cpy.Sightings = append([]Sighting(nil), o.Sightings...)
+	return &cpy
+}
+
+// summary converts the object into its record representation.
+func (o *Object) summary(kind string) *record.PropagationRecordInfo {
+	rec := &record.PropagationRecordInfo{
+		Object:    kind,
+		Hash:      o.Hash.String(),
+		Number:    o.Number,
+		FirstSeen: uint64(o.FirstSeen.UnixNano()),
+		Sightings: make([]record.PropagationSighting, len(o.Sightings)),
+		Dropped:   uint64(o.Dropped),
+	}
+	peers := make(map[string]struct{})
+	for i, s := range o.Sightings {
+		rec.Sightings[i] = record.PropagationSighting{
+			PeerId:  s.Peer,
+			Message: string(s.Message),
+			Delay:   uint64(s.Delay),
+		}
+		peers[s.Peer] = struct{}{}
+	}
+	if len(o.Sightings) > 0 {
+		rec.FirstPeer = o.Sightings[0].Peer
+		rec.FirstMessage = string(o.Sightings[0].Message)
+	}
+	rec.Peers = uint64(len(peers))
+	return rec
+}
+
+// objectSet is a set of tracked objects ordered by their first sighting.
+type objectSet struct {
+	kind  string
+	age   time.Duration
+	limit int
+	index map[common.Hash]*list.Element
+	order *list.List
+}
+
+func newObjectSet(kind string, age time.Duration, limit int) *objectSet {
+	return &objectSet{
+		kind:  kind,
+		age:   age,
+		limit: limit,
+		index: make(map[common.Hash]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Tracker records the first sighting of every block and transaction hash and
+// all later sightings relative to it. Once an object ages out, a summary of
+// its propagation is handed to the emit callback.
+type Tracker struct {
+	cfg   Config
+	clock mclock.Clock
+	emit  func(*record.PropagationRecordInfo)
+
+	lock   sync.Mutex
+	blocks *objectSet
+	txs    *objectSet
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a propagation tracker. If emit is nil, summaries are published
+// to the record sinks.
+func New(cfg Config, clock mclock.Clock, emit func(*record.PropagationRecordInfo)) *Tracker {
+	if clock == nil {
+		clock = mclock.System{}
+	}
+	if emit == nil {
+		emit = func(rec *record.PropagationRecordInfo) { record.Publish(rec) }
+	}
+	return &Tracker{
+		cfg:    cfg,
+		clock:  clock,
+		emit:   emit,
+		blocks: newObjectSet("block", cfg.BlockAge, cfg.MaxBlocks),
+		txs:    newObjectSet("tx", cfg.TxAge, cfg.MaxTxs),
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start launches the background loop emitting aged out objects.
+func (t *Tracker) Start() {
+	t.wg.Add(1)
+	go t.loop()
+}
+
+// Stop terminates the background loop and emits every object still tracked.
+func (t *Tracker) Stop() {
+	close(t.quit)
+	t.wg.Wait()
+
+	t.lock.Lock()
+	var expired []*record.PropagationRecordInfo
+	for _, set := range []*objectSet{t.blocks, t.txs} {
+		expired = append(expired, t.evict(set, func(*Object) bool { return true })...)
+	}
+	t.lock.Unlock()
+
+	for _, rec := range expired {
+		t.emit(rec)
+	}
+}
+
+func (t *Tracker) loop() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.Expire()
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// Block records a sighting of a block hash. The number may be zero if the
+// message didn't carry it.
+func (t *Tracker) Block(hash common.Hash, number uint64, peer string, msg Message) {
+	t.lock.Lock()
+	obj, evicted := t.sight(t.blocks, hash, peer, msg)
+	if obj.Number == 0 {
+		obj.Number = number
+	}
+	t.lock.Unlock()
+
+	if evicted != nil {
+		t.emit(evicted)
+	}
+}
+
+// Tx records a sighting of a transaction hash.
+func (t *Tracker) Tx(hash common.Hash, peer string, msg Message) {
+	t.lock.Lock()
+	_, evicted := t.sight(t.txs, hash, peer, msg)
+	t.lock.Unlock()
+
+	if evicted != nil {
+		t.emit(evicted)
+	}
+}
+
+// sight adds a sighting to an object, creating it on first sight. If the set
+// is over its limit, the oldest object is evicted and returned for emission.
+// The lock must be held.
+func (t *Tracker) sight(set *objectSet, hash common.Hash, peer string, msg Message) (*Object, *record.PropagationRecordInfo) {
+	now := t.clock.Now()
+	if elem, ok := set.index[hash]; ok {
+		obj := elem.Value.(*Object)
+		if t.cfg.MaxSightings > 0 && len(obj.Sightings) >= t.cfg.MaxSightings {
+			obj.Dropped++
+		} else {
+			obj.Sightings = append(obj.Sightings, Sighting{
+				Peer:    peer,
+				Message: msg,
+				Delay:   time.Duration(now - obj.first),
+			})
+		}
+		return obj, nil
+	}
+	obj := &Object{
+		Hash:      hash,
+		FirstSeen: time.Now(),
+		Sightings: []Sighting{{Peer: peer, Message: msg}},
+		first:     now,
+	}
+	set.index[hash] = set.order.PushBack(obj)
+
+	var evicted *record.PropagationRecordInfo
+	if set.limit > 0 && set.order.Len() > set.limit {
+		oldest := set.order.Remove(set.order.Front()).(*Object)
+		delete(set.index, oldest.Hash)
+		evicted = oldest.summary(set.kind)
+	}
+	return obj, evicted
+}
+
+// Expire emits a summary for every object older than its configured age.
+func (t *Tracker) Expire() {
+	now := t.clock.Now()
+
+	t.lock.Lock()
+	var expired []*record.PropagationRecordInfo
+	for _, set := range []*objectSet{t.blocks, t.txs} {
+		age := set.age
+		expired = append(expired, t.evict(set, func(obj *Object) bool {
+			return time.Duration(now-obj.first) >= age
+		})...)
+	}
+	t.lock.Unlock()
+
+	for _, rec := range expired {
+		t.emit(rec)
+	}
+}
+
+// evict removes objects from the front of the set while they match and
+// returns their summaries. The lock must be held.
+func (t *Tracker) evict(set *objectSet, match func(*Object) bool) []*record.PropagationRecordInfo {
+	var evicted []*record.PropagationRecordInfo
+	for elem := set.order.Front(); elem != nil; elem = set.order.Front() {
+		obj := elem.Value.(*Object)
+		if !match(obj) {
+			break
+		}
+		set.order.Remove(elem)
+		delete(set.index, obj.Hash)
+		evicted = append(evicted, obj.summary(set.kind))
+	}
+	return evicted
+}
+
+// BlockSightings returns the propagation history of a tracked block, or nil
+// if it isn't tracked (anymore).
+func (t *Tracker) BlockSightings(hash common.Hash) *Object {
+	return t.lookup(t.blocks, hash)
+}
+
+// TxSightings returns the propagation history of a tracked transaction, or
+// nil if it isn't tracked (anymore).
+func (t *Tracker) TxSightings(hash common.Hash) *Object {
+	return t.lookup(t.txs, hash)
+}
+
+func (t *Tracker) lookup(set *objectSet, hash common.Hash) *Object {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if elem, ok := set.index[hash]; ok {
+		return elem.Value.(*Object).copy()
+	}
+	return nil
+}
+
+// Stats returns the number of blocks and transactions currently tracked.
+func (t *Tracker) Stats() (blocks int, txs int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.blocks.order.Len(), t.txs.order.Len()
+}
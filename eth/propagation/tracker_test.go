@@ -0,0 +1,116 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package propagation
+
+import (
+	"testing"
+	"time"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/mclock"
+	"peerInfoCollect/record"
+)
+
+func newTestTracker(cfg Config) (*Tracker, *mclock.Simulated, *[]*record.PropagationRecordInfo) {
+	var (
+		clock   = new(mclock.Simulated)
+		emitted []*record.PropagationRecordInfo
+	)
+	tracker := New(cfg, clock, func(rec *record.PropagationRecordInfo) {
+		emitted = append(emitted, rec)
+	})
+	return tracker, clock, &emitted
+}
+
+func TestTrackerSightings(t *testing.T) {
+	tracker, clock, emitted := newTestTracker(DefaultConfig)
+
+	block := common.HexToHash("0x01")
+	tracker.Block(block, 100, "peer-a", NewBlockHashes)
+	clock.Run(150 * time.Millisecond)
+	tracker.Block(block, 100, "peer-b", NewBlock)
+	clock.Run(50 * time.Millisecond)
+	tracker.Block(block, 0, "peer-a", NewBlock)
+
+	obj := tracker.BlockSightings(block)
+	if obj == nil {
+		t.Fatal("block not tracked")
+	}
+	want := []Sighting{
+		{Peer: "peer-a", Message: NewBlockHashes},
+		{Peer: "peer-b", Message: NewBlock, Delay: 150 * time.Millisecond},
+		{Peer: "peer-a", Message: NewBlock, Delay: 200 * time.Millisecond},
+	}
+	if obj.Number != 100 || len(obj.Sightings) != len(want) {
+		t.Fatalf("wrong object: %+v", obj)
+	}
+	for i := range want {
+		if obj.Sightings[i] != want[i] {
+			t.Errorf("sighting %d: have %+v, want %+v", i, obj.Sightings[i], want[i])
+		}
+	}
+	if tracker.TxSightings(block) != nil {
+		t.Error("block hash tracked as transaction")
+	}
+	// Nothing is emitted before the object ages out.
+	tracker.Expire()
+	if len(*emitted) != 0 {
+		t.Fatalf("emitted %d summaries early", len(*emitted))
+	}
+	clock.Run(DefaultConfig.BlockAge)
+	tracker.Expire()
+	if len(*emitted) != 1 {
+		t.Fatalf("emitted %d summaries, want 1", len(*emitted))
+	}
+	sum := (*emitted)[0]
+	if sum.Object != "block" || sum.Number != 100 || sum.Peers != 2 || sum.FirstPeer != "peer-a" ||
+		sum.FirstMessage != string(NewBlockHashes) || len(sum.Sightings) != 3 || sum.Sightings[2].Delay != uint64(200*time.Millisecond) {
+		t.Fatalf("wrong summary: %+v", sum)
+	}
+	if tracker.BlockSightings(block) != nil {
+		t.Fatal("expired block still tracked")
+	}
+}
+
+func TestTrackerLimits(t *testing.T) {
+	tracker, clock, emitted := newTestTracker(Config{BlockAge: time.Minute, TxAge: time.Minute, MaxTxs: 2, MaxSightings: 2})
+
+	for i := 0; i < 3; i++ {
+		tracker.Tx(common.Hash{byte(i)}, "peer-a", Transactions)
+		clock.Run(time.Millisecond)
+	}
+	// The oldest transaction was pushed out by the third one.
+	if len(*emitted) != 1 || (*emitted)[0].Hash != (common.Hash{0}).String() {
+		t.Fatalf("wrong evictions: %+v", *emitted)
+	}
+	for _, peer := range []string{"peer-b", "peer-c", "peer-d"} {
+		tracker.Tx(common.Hash{1}, peer, NewPooledTransactionHashes)
+	}
+	obj := tracker.TxSightings(common.Hash{1})
+	if len(obj.Sightings) != 2 || obj.Dropped != 2 {
+		t.Fatalf("wrong sighting limit: %d sightings, %d dropped", len(obj.Sightings), obj.Dropped)
+	}
+	// Stopping flushes everything still tracked.
+	tracker.Start()
+	tracker.Stop()
+	if len(*emitted) != 3 {
+		t.Fatalf("have %d summaries after stop, want 3", len(*emitted))
+	}
+	if blocks, txs := tracker.Stats(); blocks != 0 || txs != 0 {
+		t.Fatalf("tracker not empty after stop: %d blocks, %d txs", blocks, txs)
+	}
+}
@@ -193,6 +193,7 @@ func handleMessage(backend Backend, peer *Peer) error {
 		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
 	}
 	defer msg.Discard()
+	peer.countMessage(msg.Code)
 
 	var handlers = eth66
 	//if peer.Version() >= ETH67 { // Left in as a sample when new protocol is added
@@ -35,10 +35,45 @@ func handleGetBlockHeaders66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(&query); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
+	cached := *query.GetBlockHeadersPacket // The chain query may advance the origin
+
+	// Peers of another network than the local chain's are answered from the
+	// headers observed on their network only, so the peer keeps us around.
+	network := peer.Network()
+	if network != nil && network.Genesis != backend.Chain().Genesis().Hash() {
+		return peer.ReplyBlockHeadersRLP(query.RequestId, network.Headers.Query(&cached))
+	}
 	response := ServiceGetBlockHeadersQuery(backend.Chain(), query.GetBlockHeadersPacket, peer)
+	if network != nil {
+		response = completeBlockHeadersQuery(network.Headers, &cached, response)
+	}
 	return peer.ReplyBlockHeadersRLP(query.RequestId, response)
 }
 
+// completeBlockHeadersQuery answers the part of a header query the local chain
+// couldn't serve from the headers observed on the peer's network.
+func completeBlockHeadersQuery(cache *HeaderCache, query *GetBlockHeadersPacket, response []rlp.RawValue) []rlp.RawValue {
+	if len(response) == 0 {
+		return cache.Query(query)
+	}
+	var bytes common.StorageSize
+	for _, header := range response {
+		bytes += common.StorageSize(len(header))
+	}
+	if len(response) >= int(query.Amount) || len(response) >= maxHeadersServe || bytes >= softResponseLimit {
+		return response // Served in full, or the response limits were reached
+	}
+	last := new(types.Header)
+	if err := rlp.DecodeBytes(response[len(response)-1], last); err != nil {
+		return response
+	}
+	amount := int(query.Amount) - len(response)
+	if limit := maxHeadersServe - len(response); amount > limit {
+		amount = limit
+	}
+	return append(response, cache.QueryAfter(last, query, amount)...)
+}
+
 // ServiceGetBlockHeadersQuery assembles the response to a header query. It is
 // exposed to allow external packages to test protocol behavior.
 func ServiceGetBlockHeadersQuery(chain *core.BlockChain, query *GetBlockHeadersPacket, peer *Peer) []rlp.RawValue {
@@ -17,18 +17,16 @@
 package eth
 
 import (
-	"errors"
 	"fmt"
+	"time"
+
 	"peerInfoCollect/common/hexutil"
 	"peerInfoCollect/log"
-	"math/big"
 	"peerInfoCollect/node"
-	"time"
-
-	"peerInfoCollect/common"
-	"peerInfoCollect/core/forkid"
 	"peerInfoCollect/p2p"
-	"sync/atomic"
+	"peerInfoCollect/p2p/clientinfo"
+	"peerInfoCollect/peerdb"
+	"peerInfoCollect/record"
 )
 
 const (
@@ -37,26 +35,30 @@ const (
 	handshakeTimeout = 5 * time.Second
 )
 
-var (
-	GenesisHash     atomic.Value
-	ForkId          atomic.Value
-	NetWorkID       atomic.Value
-	HeadHash        atomic.Value
-	ProtocolVersion atomic.Value
-	TdRecord        atomic.Value
-)
-
-// Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *Peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
-	// Send out own handshake in a new thread
+// MirrorHandshake executes the eth protocol handshake of the collector. The
+// remote status is read first and checked against the negotiated protocol
+// version and the observed networks; a matching peer is answered with the
+// latest status mirrored from the peers of its network. The network the peer
+// belongs to is returned.
+func (p *Peer) MirrorHandshake(networks Networks) (matched *Network, err error) {
 	errc := make(chan error, 2)
 
-	var status StatusPacket // safe to read after two values have been received from errc
+	var status StatusPacket // safe to read after a value has been received from errc
+
+	// Record the remote status once it was received, whether or not the peer
+	// is accepted.
+	var (
+		start    = time.Now()
+		received time.Time
+	)
+	defer func() {
+		if !received.IsZero() {
+			p.publishStatus(&status, matched, received, time.Since(start), err)
+		}
+	}()
 
-	//TODO 先读取远端的信息，将远端读取到的信息在发给远端
 	go func() {
-		errc <- p.readStatus(network, &status, genesis, forkFilter)
+		errc <- p.readStatusPacket(&status)
 	}()
 
 	timeout1 := time.NewTimer(handshakeTimeout)
@@ -65,62 +67,69 @@ func (p *Peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 	select {
 	case err := <-errc:
 		if err != nil {
-			return err
+			return nil, err
 		}
 	case <-timeout1.C:
-		return p2p.DiscReadTimeout
+		return nil, p2p.DiscReadTimeout
 	}
+	received = time.Now()
+	p.registerStatus(&status)
 
-	//
-	if status.NetworkID == 1 &&
-		hexutil.Encode(status.Genesis.Bytes()) == "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3" &&
-		hexutil.Encode(status.ForkID.Hash[:]) == "0x20c327fc" {
-		forkID = ForkId.Load().(forkid.ID)
-		genesis = GenesisHash.Load().(common.Hash)
-		head = HeadHash.Load().(common.Hash)
-		td = TdRecord.Load().(*big.Int)
-		pv := ProtocolVersion.Load().(uint32)
-
-		//TODO 这里严格控制顺序
-		go func() {
-			errc <- p2p.Send(p.rw, StatusMsg, &StatusPacket{
-				ProtocolVersion: pv,
-				NetworkID:       network,
-				TD:              td,
-				Head:            head,
-				Genesis:         genesis,
-				ForkID:          forkID,
-			})
-		}()
-
-		timeout2 := time.NewTimer(handshakeTimeout)
-		defer timeout2.Stop()
-
-		select {
-		case err := <-errc:
-			if err != nil {
-				return err
-			}
-		case <-timeout2.C:
-			return p2p.DiscReadTimeout
-		}
+	if uint(status.ProtocolVersion) != p.version {
+		err := fmt.Errorf("%w: %d (!= %d)", errProtocolVersionMismatch, status.ProtocolVersion, p.version)
+		markRejection(err)
+		return nil, err
+	}
+	network, err := networks.Match(p.id, &status)
+	if err != nil {
+		markRejection(err)
+		return nil, err
+	}
+	if err := network.Mirror(p.id, &status); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	record.Publish(&record.PeerRecordInfo{
+		PeerId:      p.id,
+		PeerAddress: p.RemoteAddr().String(),
+		Timestamp:   now.String(),
+		Time:        uint64(now.UnixNano()),
+	})
+
+	// Only the chain is mirrored, the protocol version is the one negotiated
+	// with this peer.
+	mirror := network.Status()
+	go func() {
+		errc <- p2p.Send(p.rw, StatusMsg, &StatusPacket{
+			ProtocolVersion: uint32(p.version),
+			NetworkID:       network.ID,
+			TD:              mirror.TD,
+			Head:            mirror.Head,
+			Genesis:         network.Genesis,
+			ForkID:          mirror.ForkID,
+		})
+	}()
 
-		p.td, p.head = status.TD, status.Head
+	timeout2 := time.NewTimer(handshakeTimeout)
+	defer timeout2.Stop()
 
-	} else {
-		return errors.New("mismatch net work id")
+	select {
+	case err := <-errc:
+		if err != nil {
+			return nil, err
+		}
+	case <-timeout2.C:
+		return nil, p2p.DiscReadTimeout
 	}
-
-	// TD at mainnet block #7753254 is 76 bits. If it becomes 100 million times
-	// larger, it will still fit within 100 bits
-	//if tdlen := p.td.BitLen(); tdlen > 100 {
-	//	return fmt.Errorf("too large total difficulty: bitlen %d", tdlen)
-	//}
-	return nil
+	p.lock.Lock()
+	p.td, p.head, p.network = status.TD, status.Head, network
+	p.lock.Unlock()
+	return network, nil
 }
 
-// readStatus reads the remote handshake message.
-func (p *Peer) readStatus(network uint64, status *StatusPacket, genesis common.Hash, forkFilter forkid.Filter) error {
+// readStatusPacket reads and decodes the remote handshake message without
+// checking its contents.
+func (p *Peer) readStatusPacket(status *StatusPacket) error {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return err
@@ -131,7 +140,7 @@ func (p *Peer) readStatus(network uint64, status *StatusPacket, genesis common.H
 	if msg.Size > maxMessageSize {
 		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
 	}
-	// Decode the handshake and make sure everything matches
+	// Decode the handshake
 	if err := msg.Decode(&status); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
@@ -140,32 +149,60 @@ func (p *Peer) readStatus(network uint64, status *StatusPacket, genesis common.H
 		"version", status.ProtocolVersion, "fork id hash", hexutil.Encode(status.ForkID.Hash[:]),
 		"fork id next", status.ForkID.Next, "genesis hash", hexutil.Encode(status.Genesis.Bytes()),
 	)
+	return nil
+}
 
-	if status.NetworkID == 1 &&
-		hexutil.Encode(status.Genesis.Bytes()) == "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3" &&
-		hexutil.Encode(status.ForkID.Hash[:]) == "0x20c327fc"{
-		GenesisHash.Store(status.Genesis)
-		ProtocolVersion.Store(status.ProtocolVersion)
-		ForkId.Store(status.ForkID)
-		TdRecord.Store(status.TD)
-		HeadHash.Store(status.Head)
-		NetWorkID.Store(status.NetworkID)
-		node.PeerInfoCache.Add(p.id,p.RemoteAddr().String())
-	}else {
-		return errors.New("not match")
+// registerStatus stores the session and the handshake of the remote peer in
+// the peer registry, whether or not the peer matches our network.
+func (p *Peer) registerStatus(status *StatusPacket) {
+	caps := make([]string, 0, len(p.Caps()))
+	for _, cap := range p.Caps() {
+		caps = append(caps, cap.String())
 	}
+	node.PeerRegistry.Connected(p.id, p.RemoteAddr().String(), p.Name(), caps, &peerdb.Status{
+		ProtocolVersion: status.ProtocolVersion,
+		NetworkID:       status.NetworkID,
+		TD:              status.TD,
+		Head:            status.Head,
+		Genesis:         status.Genesis,
+		ForkHash:        status.ForkID.Hash[:],
+		ForkNext:        status.ForkID.Next,
+	})
+}
 
-	//if status.NetworkID != network {
-	//	return fmt.Errorf("%w: %d (!= %d)", errNetworkIDMismatch, status.NetworkID, network)
-	//}
-	//if uint(status.ProtocolVersion) != p.version {
-	//	return fmt.Errorf("%w: %d (!= %d)", errProtocolVersionMismatch, status.ProtocolVersion, p.version)
-	//}
-	//if status.Genesis != genesis {
-	//	return fmt.Errorf("%w: %x (!= %x)", errGenesisMismatch, status.Genesis, genesis)
-	//}
-	//if err := forkFilter(status.ForkID); err != nil {
-	//	return fmt.Errorf("%w: %v", errForkIDRejected, err)
-	//}
-	return nil
+// publishStatus records the status handshake of the remote peer.
+func (p *Peer) publishStatus(status *StatusPacket, network *Network, received time.Time, elapsed time.Duration, err error) {
+	info := p.Info()
+	rec := &record.PeerStatusRecordInfo{
+		PeerId:          p.id,
+		Enode:           info.Enode,
+		PeerAddr:        info.Network.RemoteAddress,
+		Name:            info.Name,
+		Caps:            info.Caps,
+		Inbound:         info.Network.Inbound,
+		ProtocolVersion: status.ProtocolVersion,
+		NetworkID:       status.NetworkID,
+		Head:            status.Head.String(),
+		Genesis:         status.Genesis.String(),
+		ForkHash:        hexutil.Encode(status.ForkID.Hash[:]),
+		ForkNext:        status.ForkID.Next,
+		Accepted:        err == nil,
+		Duration:        uint64(elapsed),
+		Time:            uint64(received.UnixNano()),
+	}
+	if status.TD != nil {
+		rec.TD = status.TD.String()
+	}
+	if network != nil {
+		rec.Network = network.Name
+	}
+	client := clientinfo.Parse(info.Name)
+	rec.Client, rec.ClientVersion, rec.ClientBuild = client.Client, client.Version, client.Build
+	rec.OS, rec.Arch, rec.Runtime = client.OS, client.Arch, client.Runtime
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if err := record.Publish(rec); err != nil {
+		log.Debug("Failed to publish peer status", "peer", p.id, "err", err)
+	}
 }
@@ -18,12 +18,14 @@ package eth
 
 import (
 	"errors"
+	"math/big"
 	"testing"
 
 	"peerInfoCollect/common"
 	"peerInfoCollect/core/forkid"
 	"peerInfoCollect/p2p"
 	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/params"
 )
 
 // Tests that handshake failures are detected and reported correctly.
@@ -32,15 +34,15 @@ func TestHandshake66(t *testing.T) { testHandshake(t, ETH66) }
 func testHandshake(t *testing.T, protocol uint) {
 	t.Parallel()
 
-	// Create a test backend only to have some valid genesis chain
-	backend := newTestBackend(3)
-	defer backend.close()
-
+	networks, err := ParseNetworks([]string{"mainnet"}, forkid.Tolerance{})
+	if err != nil {
+		t.Fatal(err)
+	}
 	var (
-		genesis = backend.chain.Genesis()
-		head    = backend.chain.CurrentBlock()
-		td      = backend.chain.GetTd(head.Hash(), head.NumberU64())
-		forkID  = forkid.NewID(backend.chain.Config(), backend.chain.Genesis().Hash(), backend.chain.CurrentHeader().Number.Uint64())
+		genesis = params.MainnetGenesisHash
+		head    = common.Hash{0xaa}
+		td      = big.NewInt(1000)
+		forkID  = forkid.NewID(params.MainnetChainConfig, genesis, 14000000)
 	)
 	tests := []struct {
 		code uint64
@@ -52,19 +54,23 @@ func testHandshake(t *testing.T, protocol uint) {
 			want: errNoStatusMsg,
 		},
 		{
-			code: StatusMsg, data: StatusPacket{10, 1, td, head.Hash(), genesis.Hash(), forkID},
+			code: StatusMsg, data: StatusPacket{10, 1, td, head, genesis, forkID},
+			want: errProtocolVersionMismatch,
+		},
+		{
+			code: StatusMsg, data: StatusPacket{uint32(protocol) + 1, 1, td, head, genesis, forkID},
 			want: errProtocolVersionMismatch,
 		},
 		{
-			code: StatusMsg, data: StatusPacket{uint32(protocol), 999, td, head.Hash(), genesis.Hash(), forkID},
+			code: StatusMsg, data: StatusPacket{uint32(protocol), 999, td, head, genesis, forkID},
 			want: errNetworkIDMismatch,
 		},
 		{
-			code: StatusMsg, data: StatusPacket{uint32(protocol), 1, td, head.Hash(), common.Hash{3}, forkID},
+			code: StatusMsg, data: StatusPacket{uint32(protocol), 1, td, head, common.Hash{3}, forkID},
 			want: errGenesisMismatch,
 		},
 		{
-			code: StatusMsg, data: StatusPacket{uint32(protocol), 1, td, head.Hash(), genesis.Hash(), forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
+			code: StatusMsg, data: StatusPacket{uint32(protocol), 1, td, head, genesis, forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
 			want: errForkIDRejected,
 		},
 	}
@@ -80,7 +86,7 @@ func testHandshake(t *testing.T, protocol uint) {
 		// Send the junk test with one peer, check the handshake failure
 		go p2p.Send(app, test.code, test.data)
 
-		err := peer.Handshake(1, td, head.Hash(), genesis.Hash(), forkID, forkid.NewFilter(backend.chain))
+		_, err := peer.MirrorHandshake(networks)
 		if err == nil {
 			t.Errorf("test %d: protocol returned nil error, want %q", i, test.want)
 		} else if !errors.Is(err, test.want) {
@@ -0,0 +1,226 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"container/list"
+	"sync"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/core/types"
+	"peerInfoCollect/rlp"
+)
+
+// defaultHeaderCacheSize is the number of recently observed headers a network
+// keeps around to serve header requests from.
+const defaultHeaderCacheSize = 8192
+
+// HeaderCache holds the headers recently observed on a network, so that header
+// requests for the head advertised by the collector can be answered without
+// a local chain.
+type HeaderCache struct {
+	limit int
+
+	lock    sync.RWMutex
+	headers map[common.Hash]*list.Element // Element values are *types.Header
+	numbers map[uint64]common.Hash        // Header linked into the chain at each number
+	order   *list.List                    // Headers by observation, most recent first
+}
+
+// NewHeaderCache creates a cache holding up to limit headers.
+func NewHeaderCache(limit int) *HeaderCache {
+	return &HeaderCache{
+		limit:   limit,
+		headers: make(map[common.Hash]*list.Element),
+		numbers: make(map[uint64]common.Hash),
+		order:   list.New(),
+	}
+}
+
+// Add stores observed headers, evicting the least recently observed ones
+// beyond the cache limit. Headers aren't validated, so a header only takes over
+// the number of a previously observed one if it links to its cached neighbours
+// and the previous one doesn't.
+func (c *HeaderCache) Add(headers ...*types.Header) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, header := range headers {
+		if header == nil || header.Number == nil {
+			continue
+		}
+		hash := header.Hash()
+		if elem, ok := c.headers[hash]; ok {
+			c.order.MoveToFront(elem)
+		} else {
+			c.headers[hash] = c.order.PushFront(header)
+		}
+		c.index(header, hash)
+	}
+	for c.order.Len() > c.limit {
+		header := c.order.Remove(c.order.Back()).(*types.Header)
+		hash := header.Hash()
+
+		delete(c.headers, hash)
+		if number := header.Number.Uint64(); c.numbers[number] == hash {
+			delete(c.numbers, number)
+		}
+	}
+}
+
+// index records the header as the one at its number, unless another header is
+// already indexed there and the new one doesn't link to the cached parent or
+// child while the indexed one does. Once indexed, the ancestors the header
+// links to take over their numbers too, following reorgs. The caller must hold
+// the lock.
+func (c *HeaderCache) index(header *types.Header, hash common.Hash) {
+	number := header.Number.Uint64()
+	if cur, ok := c.numbers[number]; ok && cur != hash {
+		if c.linked(c.headers[cur].Value.(*types.Header), cur) || !c.linked(header, hash) {
+			return
+		}
+	}
+	c.numbers[number] = hash
+
+	for number > 0 {
+		elem, ok := c.headers[header.ParentHash]
+		if !ok || c.numbers[number-1] == header.ParentHash {
+			break
+		}
+		number--
+		c.numbers[number] = header.ParentHash
+		header = elem.Value.(*types.Header)
+	}
+}
+
+// linked reports whether the header at hash links to the header indexed at
+// its parent's or child's number. The caller must hold the lock.
+func (c *HeaderCache) linked(header *types.Header, hash common.Hash) bool {
+	number := header.Number.Uint64()
+	if number > 0 {
+		if parent, ok := c.numbers[number-1]; ok && parent == header.ParentHash {
+			return true
+		}
+	}
+	if child, ok := c.numbers[number+1]; ok {
+		return c.headers[child].Value.(*types.Header).ParentHash == hash
+	}
+	return false
+}
+
+// Get retrieves an observed header by hash.
+func (c *HeaderCache) Get(hash common.Hash) *types.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if elem, ok := c.headers[hash]; ok {
+		return elem.Value.(*types.Header)
+	}
+	return nil
+}
+
+// GetByNumber retrieves the header indexed at a number.
+func (c *HeaderCache) GetByNumber(number uint64) *types.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if hash, ok := c.numbers[number]; ok {
+		return c.headers[hash].Value.(*types.Header)
+	}
+	return nil
+}
+
+// Len returns the number of cached headers.
+func (c *HeaderCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.order.Len()
+}
+
+// Query assembles the response to a header query from the cached headers. The
+// traversal stops at the first header missing from the cache.
+func (c *HeaderCache) Query(query *GetBlockHeadersPacket) []rlp.RawValue {
+	var origin *types.Header
+	if query.Origin.Hash != (common.Hash{}) {
+		origin = c.Get(query.Origin.Hash)
+	} else {
+		origin = c.GetByNumber(query.Origin.Number)
+	}
+	return c.collect(origin, query, int(query.Amount))
+}
+
+// QueryAfter continues a header query past a header served from elsewhere,
+// assembling up to amount further headers linked to it from the cache.
+func (c *HeaderCache) QueryAfter(last *types.Header, query *GetBlockHeadersPacket, amount int) []rlp.RawValue {
+	return c.collect(c.next(last, query), query, amount)
+}
+
+// collect gathers the headers of a query starting at origin, until amount
+// headers were gathered, the response limits were reached or the traversal
+// hit a header missing from the cache.
+func (c *HeaderCache) collect(origin *types.Header, query *GetBlockHeadersPacket, amount int) []rlp.RawValue {
+	var (
+		headers []rlp.RawValue
+		bytes   common.StorageSize
+	)
+	for origin != nil && len(headers) < amount && len(headers) < maxHeadersServe && bytes < softResponseLimit {
+		rlpData, err := rlp.EncodeToBytes(origin)
+		if err != nil {
+			break
+		}
+		headers = append(headers, rlpData)
+		bytes += common.StorageSize(len(rlpData))
+
+		origin = c.next(origin, query)
+	}
+	return headers
+}
+
+// next returns the cached header following origin in a query, or nil if there
+// is none. Ancestors are followed by parent hash, descendants must link back to
+// origin. The origin itself need not be cached.
+func (c *HeaderCache) next(origin *types.Header, query *GetBlockHeadersPacket) *types.Header {
+	step := query.Skip + 1
+	if origin == nil || step == 0 {
+		return nil // Skip overflow
+	}
+	number := origin.Number.Uint64()
+	if query.Reverse {
+		if number < step {
+			return nil
+		}
+		return c.ancestor(c.Get(origin.ParentHash), step-1)
+	}
+	if number+step <= number {
+		return nil
+	}
+	next := c.GetByNumber(number + step)
+	if parent := c.ancestor(next, step-1); parent == nil || parent.ParentHash != origin.Hash() {
+		return nil
+	}
+	return next
+}
+
+// ancestor follows the parent hashes of a header the given number of blocks
+// back, returning nil if any header on the way is missing from the cache.
+func (c *HeaderCache) ancestor(header *types.Header, distance uint64) *types.Header {
+	for ; header != nil && distance > 0; distance-- {
+		header = c.Get(header.ParentHash)
+	}
+	return header
+}
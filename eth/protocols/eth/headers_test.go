@@ -0,0 +1,234 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/consensus/ethash"
+	"peerInfoCollect/core"
+	"peerInfoCollect/core/forkid"
+	"peerInfoCollect/core/types"
+	"peerInfoCollect/p2p"
+	"peerInfoCollect/params"
+	"peerInfoCollect/rlp"
+)
+
+// newTestHeaders generates a chain of the given length on top of the genesis
+// of the test backend, returning the headers indexed by number.
+func newTestHeaders(backend *testBackend, n int) []*types.Header {
+	blocks, _ := core.GenerateChain(params.TestChainConfig, backend.chain.Genesis(), ethash.NewFaker(), backend.db, n, nil)
+
+	headers := []*types.Header{backend.chain.Genesis().Header()}
+	for _, block := range blocks {
+		headers = append(headers, block.Header())
+	}
+	return headers
+}
+
+// Tests that the header cache answers header queries by following the observed
+// headers.
+func TestHeaderCacheQuery(t *testing.T) {
+	backend := newTestBackend(0)
+	defer backend.close()
+
+	headers := newTestHeaders(backend, 64)
+	cache := NewHeaderCache(128)
+	for i := len(headers) - 1; i >= 0; i-- {
+		cache.Add(headers[i])
+	}
+	tests := []struct {
+		query  GetBlockHeadersPacket
+		expect []uint64
+	}{
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Number: 10}, Amount: 1}, []uint64{10}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Number: 10}, Amount: 5}, []uint64{10, 11, 12, 13, 14}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Number: 10}, Amount: 5, Reverse: true}, []uint64{10, 9, 8, 7, 6}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Number: 10}, Amount: 5, Skip: 3}, []uint64{10, 14, 18, 22, 26}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Number: 40}, Amount: 5, Skip: 3, Reverse: true}, []uint64{40, 36, 32, 28, 24}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Number: 60}, Amount: 10}, []uint64{60, 61, 62, 63, 64}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Number: 3}, Amount: 10, Reverse: true}, []uint64{3, 2, 1, 0}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Number: 65}, Amount: 1}, nil},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Hash: headers[10].Hash()}, Amount: 5, Skip: 3}, []uint64{10, 14, 18, 22, 26}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Hash: headers[40].Hash()}, Amount: 5, Skip: 3, Reverse: true}, []uint64{40, 36, 32, 28, 24}},
+		// Skip overflows must not loop back into the chain
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Hash: headers[3].Hash()}, Amount: 2, Skip: math.MaxUint64 - 1}, []uint64{3}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Hash: headers[1].Hash()}, Amount: 2, Skip: math.MaxUint64}, []uint64{1}},
+		{GetBlockHeadersPacket{Origin: HashOrNumber{Hash: common.Hash{0xff}}, Amount: 1}, nil},
+	}
+	for i, tt := range tests {
+		var want []rlp.RawValue
+		for _, n := range tt.expect {
+			enc, _ := rlp.EncodeToBytes(headers[n])
+			want = append(want, enc)
+		}
+		if have := cache.Query(&tt.query); !reflect.DeepEqual(have, want) {
+			t.Errorf("test %d: have %d headers, want %v", i, len(have), tt.expect)
+		}
+	}
+	// Forks not linking to the cached neighbours must not replace the indexed
+	// headers, nor be served
+	fork := *headers[12]
+	fork.ParentHash = common.Hash{0x01}
+	cache.Add(&fork)
+
+	if have := cache.GetByNumber(12); have.Hash() != headers[12].Hash() {
+		t.Errorf("unlinked fork replaced the indexed header")
+	}
+	query := GetBlockHeadersPacket{Origin: HashOrNumber{Number: 10}, Amount: 5}
+	if have := cache.Query(&query); len(have) != 5 {
+		t.Errorf("served %d headers, want 5", len(have))
+	}
+	// Descendants not linking back to the origin must not be served
+	cache = NewHeaderCache(128)
+	cache.Add(headers[10], headers[11], &fork, headers[13], headers[14])
+	if have := cache.Query(&query); len(have) != 2 {
+		t.Errorf("served %d headers across a fork, want 2", len(have))
+	}
+}
+
+// Tests that the header indexed at a number is only replaced by headers linking
+// to their cached neighbours, following reorgs.
+func TestHeaderCacheIndex(t *testing.T) {
+	backend := newTestBackend(0)
+	defer backend.close()
+
+	var (
+		genesis   = backend.chain.Genesis()
+		chain, _  = core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), backend.db, 4, nil)
+		blocks, _ = core.GenerateChain(params.TestChainConfig, chain[0], ethash.NewFaker(), backend.db, 3, func(i int, gen *core.BlockGen) {
+			gen.SetExtra([]byte("fork"))
+		})
+		headers = []*types.Header{genesis.Header()}
+		fork    []*types.Header // Numbers 2, 3 and 4
+	)
+	for _, block := range chain {
+		headers = append(headers, block.Header())
+	}
+	for _, block := range blocks {
+		fork = append(fork, block.Header())
+	}
+	cache := NewHeaderCache(128)
+
+	// The first header seen at a number is kept over unlinked ones
+	cache.Add(headers[3], fork[1])
+	if have := cache.GetByNumber(3); have.Hash() != headers[3].Hash() {
+		t.Fatalf("last writer replaced the first header seen")
+	}
+	// Linked headers replace unlinked ones, but not the other way around
+	cache.Add(headers[2])
+	cache.Add(fork[0])
+	if have := cache.GetByNumber(2); have.Hash() != headers[2].Hash() {
+		t.Fatalf("unlinked header replaced a linked one")
+	}
+	// Headers extending a fork move the numbers over to it
+	cache.Add(fork[2])
+	for i, header := range fork {
+		if have := cache.GetByNumber(uint64(i + 2)); have.Hash() != header.Hash() {
+			t.Errorf("header %d not reorged to the fork", i+2)
+		}
+	}
+}
+
+// Tests that the header cache evicts the least recently observed headers.
+func TestHeaderCacheEviction(t *testing.T) {
+	backend := newTestBackend(0)
+	defer backend.close()
+
+	headers := newTestHeaders(backend, 6)
+	cache := NewHeaderCache(4)
+	for i := 1; i <= 6; i++ {
+		cache.Add(headers[i])
+	}
+	cache.Add(headers[3]) // Refresh, must not evict anything
+	if n := cache.Len(); n != 4 {
+		t.Fatalf("have %d headers, want 4", n)
+	}
+	cache.Add(headers[1])
+	for n, want := range map[uint64]bool{1: true, 3: true, 4: false, 5: true, 6: true} {
+		header := headers[n]
+		if have := cache.Get(header.Hash()) != nil; have != want {
+			t.Errorf("header %d cached: have %v, want %v", n, have, want)
+		}
+		if have := cache.GetByNumber(n) != nil; have != want {
+			t.Errorf("header %d indexed: have %v, want %v", n, have, want)
+		}
+	}
+}
+
+// Tests that header requests a node can't serve from its chain are answered
+// from the headers observed on the peer's network.
+func TestGetBlockHeadersFromCache(t *testing.T) {
+	backend := newTestBackend(0)
+	defer backend.close()
+
+	source := newTestHeaders(backend, 10)
+
+	peer, _ := newTestPeer("peer", ETH66, backend)
+	defer peer.close()
+
+	query := &GetBlockHeadersPacket{Origin: HashOrNumber{Hash: source[5].Hash()}, Amount: 3, Reverse: true}
+
+	// Without a network, there's nothing to serve
+	p2p.Send(peer.app, GetBlockHeadersMsg, &GetBlockHeadersPacket66{RequestId: 1, GetBlockHeadersPacket: query})
+	if err := p2p.ExpectMsg(peer.app, BlockHeadersMsg, &BlockHeadersPacket66{RequestId: 1, BlockHeadersPacket: []*types.Header{}}); err != nil {
+		t.Fatal(err)
+	}
+	// Once the headers were observed on the peer's network, they are served
+	network := NewNetwork("test", 1, source[0].Hash(), nil, forkid.Tolerance{})
+	for i := 1; i <= 10; i++ {
+		network.Headers.Add(source[i])
+	}
+	peer.lock.Lock()
+	peer.network = network
+	peer.lock.Unlock()
+
+	p2p.Send(peer.app, GetBlockHeadersMsg, &GetBlockHeadersPacket66{RequestId: 2, GetBlockHeadersPacket: query})
+	headers := []*types.Header{
+		source[5],
+		source[4],
+		source[3],
+	}
+	if err := p2p.ExpectMsg(peer.app, BlockHeadersMsg, &BlockHeadersPacket66{RequestId: 2, BlockHeadersPacket: headers}); err != nil {
+		t.Fatal(err)
+	}
+	// The part of a range the local chain can't serve is completed from the cache
+	query = &GetBlockHeadersPacket{Origin: HashOrNumber{Number: 0}, Amount: 4}
+	p2p.Send(peer.app, GetBlockHeadersMsg, &GetBlockHeadersPacket66{RequestId: 3, GetBlockHeadersPacket: query})
+	if err := p2p.ExpectMsg(peer.app, BlockHeadersMsg, &BlockHeadersPacket66{RequestId: 3, BlockHeadersPacket: source[:4]}); err != nil {
+		t.Fatal(err)
+	}
+	// Peers of another network are never answered from the local chain
+	foreign := NewNetwork("foreign", 2, common.Hash{0x01}, nil, forkid.Tolerance{})
+	foreign.Headers.Add(source[1], source[2])
+	peer.lock.Lock()
+	peer.network = foreign
+	peer.lock.Unlock()
+
+	p2p.Send(peer.app, GetBlockHeadersMsg, &GetBlockHeadersPacket66{RequestId: 4, GetBlockHeadersPacket: query})
+	if err := p2p.ExpectMsg(peer.app, BlockHeadersMsg, &BlockHeadersPacket66{RequestId: 4, BlockHeadersPacket: []*types.Header{}}); err != nil {
+		t.Fatal(err)
+	}
+	query = &GetBlockHeadersPacket{Origin: HashOrNumber{Number: 1}, Amount: 4}
+	p2p.Send(peer.app, GetBlockHeadersMsg, &GetBlockHeadersPacket66{RequestId: 5, GetBlockHeadersPacket: query})
+	if err := p2p.ExpectMsg(peer.app, BlockHeadersMsg, &BlockHeadersPacket66{RequestId: 5, BlockHeadersPacket: source[1:3]}); err != nil {
+		t.Fatal(err)
+	}
+}
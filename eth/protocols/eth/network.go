@@ -0,0 +1,305 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/core/forkid"
+	"peerInfoCollect/metrics"
+	"peerInfoCollect/params"
+)
+
+// maxHeadJump is the number of blocks an announced head may exceed the known
+// head of a network by, larger jumps are considered bogus and ignored.
+const maxHeadJump = 1 << 14
+
+var (
+	rejectStaleMeter        = metrics.NewRegisteredMeter("eth/handshake/reject/stale", nil)
+	rejectFutureMeter       = metrics.NewRegisteredMeter("eth/handshake/reject/future", nil)
+	rejectIncompatibleMeter = metrics.NewRegisteredMeter("eth/handshake/reject/incompatible", nil)
+)
+
+// Network is a chain observed by the collector. Remote peers whose status
+// matches the network are answered with the latest status mirrored from the
+// network's other peers, so the collector never needs a chain of its own.
+type Network struct {
+	Name    string
+	ID      uint64
+	Genesis common.Hash
+	Filter  forkid.PeerFilter // Fork ID filter, nil accepts every fork ID
+	Headers *HeaderCache      // Recently observed headers, served to the network's peers
+
+	oracle *StatusOracle // Status backed by a quorum of the network's peers
+	head   uint64        // Highest block number announced by a quorum of the network's peers (atomic)
+
+	headLock    sync.Mutex
+	headReports map[string]uint64 // Highest block number announced by each peer
+}
+
+// NewNetwork creates an observed network. If config is non-nil, peers are
+// filtered by the fork IDs of the chain configuration at the head announced
+// by the network's peers, relaxed by the given tolerance.
+func NewNetwork(name string, id uint64, genesis common.Hash, config *params.ChainConfig, tolerance forkid.Tolerance) *Network {
+	n := &Network{
+		Name:    name,
+		ID:      id,
+		Genesis: genesis,
+		Headers: NewHeaderCache(defaultHeaderCacheSize),
+		oracle:  NewStatusOracle(defaultOracleQuorum, nil),
+
+		headReports: make(map[string]uint64),
+	}
+	if config != nil {
+		n.Filter = forkid.NewTolerantFilter(config, genesis, n.Head, tolerance)
+	}
+	return n
+}
+
+// Match checks whether the status of a remote peer belongs to the network.
+func (n *Network) Match(peer string, status *StatusPacket) error {
+	if status.NetworkID != n.ID {
+		return fmt.Errorf("%w: %d (!= %d)", errNetworkIDMismatch, status.NetworkID, n.ID)
+	}
+	if status.Genesis != n.Genesis {
+		return fmt.Errorf("%w: %x (!= %x)", errGenesisMismatch, status.Genesis, n.Genesis)
+	}
+	if n.Filter != nil {
+		if err := n.Filter(peer, status.ForkID); err != nil {
+			return &wrapError{kind: errForkIDRejected, err: err}
+		}
+	}
+	return nil
+}
+
+// SetHead records a block number announced by one of the network's peers.
+// The head is raised to the highest number announced by a quorum of distinct
+// peers, so no single peer can move it. Numbers too far beyond the known head
+// are ignored.
+func (n *Network) SetHead(peer string, number uint64) {
+	n.headLock.Lock()
+	defer n.headLock.Unlock()
+
+	head := atomic.LoadUint64(&n.head)
+	if head > 0 && number > head+maxHeadJump {
+		return
+	}
+	last, known := n.headReports[peer]
+	if number <= last {
+		return
+	}
+	if !known && len(n.headReports) >= maxOraclePeers {
+		n.evictLowestReport()
+	}
+	n.headReports[peer] = number
+	if number <= head {
+		return
+	}
+	// Only the reports beyond the current head can raise it.
+	var above []uint64
+	for _, reported := range n.headReports {
+		if reported > head {
+			above = append(above, reported)
+		}
+	}
+	if len(above) < defaultOracleQuorum {
+		return
+	}
+	sort.Slice(above, func(i, j int) bool { return above[i] > above[j] })
+	atomic.StoreUint64(&n.head, above[defaultOracleQuorum-1])
+}
+
+// evictLowestReport drops the head report with the lowest block number, it
+// is the least likely to matter for raising the head.
+func (n *Network) evictLowestReport() {
+	var (
+		lowest string
+		number uint64
+		found  bool
+	)
+	for peer, reported := range n.headReports {
+		if !found || reported < number {
+			lowest, number, found = peer, reported, true
+		}
+	}
+	delete(n.headReports, lowest)
+}
+
+// Head returns the highest block number announced by a quorum of the
+// network's peers, zero if there is no such number yet.
+func (n *Network) Head() uint64 {
+	return atomic.LoadUint64(&n.head)
+}
+
+// Mirror records the status of a matching peer, the status answered with is
+// the best one backed by a quorum of peers.
+func (n *Network) Mirror(peer string, status *StatusPacket) error {
+	return n.oracle.Observe(peer, status)
+}
+
+// MirrorHead records a block propagated by a matching peer as its new head.
+func (n *Network) MirrorHead(peer string, head common.Hash, td *big.Int) error {
+	return n.oracle.Update(peer, head, td)
+}
+
+// Status returns the mirrored status, or nil if no peer of the network has
+// been seen yet.
+func (n *Network) Status() *StatusPacket {
+	return n.oracle.Status()
+}
+
+// TD returns the total difficulty of the mirrored status, zero if no
+// peer of the network has been seen yet.
+func (n *Network) TD() *big.Int {
+	if status := n.Status(); status != nil && status.TD != nil {
+		return new(big.Int).Set(status.TD)
+	}
+	return new(big.Int)
+}
+
+func (n *Network) String() string {
+	return fmt.Sprintf("%s(%d)", n.Name, n.ID)
+}
+
+// Networks is the set of networks observed by the collector.
+type Networks []*Network
+
+// Match returns the network a remote status belongs to. If none matches, the
+// mismatch of the network sharing the remote genesis (or the first network)
+// is reported.
+func (ns Networks) Match(peer string, status *StatusPacket) (*Network, error) {
+	if len(ns) == 0 {
+		return nil, errStatusMismatch
+	}
+	var reason error
+	for _, n := range ns {
+		err := n.Match(peer, status)
+		if err == nil {
+			return n, nil
+		}
+		if reason == nil || n.Genesis == status.Genesis {
+			reason = err
+		}
+	}
+	return nil, &wrapError{kind: errStatusMismatch, err: reason}
+}
+
+// Lookup returns the first network with the given network ID.
+func (ns Networks) Lookup(id uint64) *Network {
+	for _, n := range ns {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// wrapError tags an error with the kind of failure, keeping both the kind and
+// the cause reachable through errors.Is.
+type wrapError struct {
+	kind error
+	err  error
+}
+
+func (e *wrapError) Error() string        { return fmt.Sprintf("%v: %v", e.kind, e.err) }
+func (e *wrapError) Is(target error) bool { return target == e.kind }
+func (e *wrapError) Unwrap() error        { return e.err }
+
+// markRejection classifies a status rejection in the metrics: peers lagging
+// behind the fork rules of their network are stale, peers applying forks not
+// known locally are future, everything else is incompatible.
+func markRejection(err error) {
+	switch {
+	case errors.Is(err, forkid.ErrRemoteStale):
+		rejectStaleMeter.Mark(1)
+	case errors.Is(err, forkid.ErrRemoteFuture):
+		rejectFutureMeter.Mark(1)
+	default:
+		rejectIncompatibleMeter.Mark(1)
+	}
+}
+
+// knownNetwork is a network that can be selected by name.
+type knownNetwork struct {
+	id      uint64
+	genesis common.Hash
+	config  *params.ChainConfig
+}
+
+var knownNetworks = map[string]knownNetwork{
+	"mainnet": {1, params.MainnetGenesisHash, params.MainnetChainConfig},
+	"ropsten": {3, params.RopstenGenesisHash, params.RopstenChainConfig},
+	"rinkeby": {4, params.RinkebyGenesisHash, params.RinkebyChainConfig},
+	"goerli":  {5, params.GoerliGenesisHash, params.GoerliChainConfig},
+	"sepolia": {11155111, params.SepoliaGenesisHash, params.SepoliaChainConfig},
+}
+
+// ParseNetworks creates the observed networks from their specifications.
+// A specification is either the name of a known network (mainnet, ropsten,
+// rinkeby, goerli, sepolia) or name:networkid:genesis for any other chain,
+// in which case fork IDs are not checked.
+func ParseNetworks(specs []string, tolerance forkid.Tolerance) (Networks, error) {
+	var (
+		networks Networks
+		names    = make(map[string]bool)
+	)
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		n, err := parseNetwork(spec, tolerance)
+		if err != nil {
+			return nil, err
+		}
+		if names[n.Name] {
+			return nil, fmt.Errorf("duplicate network %q", n.Name)
+		}
+		names[n.Name] = true
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
+
+func parseNetwork(spec string, tolerance forkid.Tolerance) (*Network, error) {
+	name := strings.ToLower(spec)
+	if known, ok := knownNetworks[name]; ok {
+		return NewNetwork(name, known.id, known.genesis, known.config, tolerance), nil
+	}
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid network %q, want a known name or name:networkid:genesis", spec)
+	}
+	id, err := strconv.ParseUint(parts[1], 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network ID in %q: %v", spec, err)
+	}
+	genesis, err := hex.DecodeString(strings.TrimPrefix(parts[2], "0x"))
+	if err != nil || len(genesis) != common.HashLength {
+		return nil, fmt.Errorf("invalid genesis hash in %q", spec)
+	}
+	return NewNetwork(parts[0], id, common.BytesToHash(genesis), nil, tolerance), nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/core/forkid"
+	"peerInfoCollect/p2p"
+	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/params"
+)
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"mainnet", " Sepolia ", "", "bsc:56:0x0d21840abff46b96c84b2ac9e10e4f5cdaeb5693cb665db62a2f3b02d2d57b5b"}, forkid.Tolerance{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 3 {
+		t.Fatalf("have %d networks, want 3", len(networks))
+	}
+	if n := networks[0]; n.Name != "mainnet" || n.ID != 1 || n.Genesis != params.MainnetGenesisHash || n.Filter == nil {
+		t.Errorf("wrong mainnet network: %+v", n)
+	}
+	if n := networks[1]; n.Name != "sepolia" || n.ID != 11155111 || n.Genesis != params.SepoliaGenesisHash {
+		t.Errorf("wrong sepolia network: %+v", n)
+	}
+	if n := networks[2]; n.Name != "bsc" || n.ID != 56 || n.Filter != nil ||
+		n.Genesis != common.HexToHash("0x0d21840abff46b96c84b2ac9e10e4f5cdaeb5693cb665db62a2f3b02d2d57b5b") {
+		t.Errorf("wrong custom network: %+v", n)
+	}
+	for _, spec := range [][]string{
+		{"nosuchnet"},
+		{"custom:x:0x01"},
+		{"custom:7:0x0102"},
+		{"mainnet", "mainnet"},
+	} {
+		if _, err := ParseNetworks(spec, forkid.Tolerance{}); err == nil {
+			t.Errorf("spec %q: expected error", spec)
+		}
+	}
+}
+
+func TestNetworksMatch(t *testing.T) {
+	networks, err := ParseNetworks([]string{"mainnet", "goerli", "custom:7:0x0000000000000000000000000000000000000000000000000000000000000007"}, forkid.Tolerance{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mainnetFork = forkid.NewID(params.MainnetChainConfig, params.MainnetGenesisHash, 14000000)
+		goerliFork  = forkid.NewID(params.GoerliChainConfig, params.GoerliGenesisHash, 6000000)
+	)
+	tests := []struct {
+		status  StatusPacket
+		network string
+		err     error
+	}{
+		{StatusPacket{NetworkID: 1, Genesis: params.MainnetGenesisHash, ForkID: mainnetFork}, "mainnet", nil},
+		{StatusPacket{NetworkID: 5, Genesis: params.GoerliGenesisHash, ForkID: goerliFork}, "goerli", nil},
+		{StatusPacket{NetworkID: 7, Genesis: common.Hash{31: 7}, ForkID: forkid.ID{Hash: [4]byte{1, 2, 3, 4}}}, "custom", nil},
+		{StatusPacket{NetworkID: 1, Genesis: params.MainnetGenesisHash, ForkID: forkid.ID{Hash: [4]byte{1, 2, 3, 4}}}, "", errStatusMismatch},
+		{StatusPacket{NetworkID: 1, Genesis: params.GoerliGenesisHash, ForkID: goerliFork}, "", errStatusMismatch},
+		{StatusPacket{NetworkID: 56, Genesis: common.Hash{1}}, "", errStatusMismatch},
+	}
+	for i, test := range tests {
+		n, err := networks.Match("a", &test.status)
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d: wrong error %v, want %v", i, err, test.err)
+			continue
+		}
+		if err == nil && n.Name != test.network {
+			t.Errorf("test %d: matched %v, want %s", i, n, test.network)
+		}
+	}
+}
+
+func TestMirrorHandshake(t *testing.T) {
+	networks, err := ParseNetworks([]string{"mainnet", "goerli"}, forkid.Tolerance{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		goerli = networks[1]
+		remote = StatusPacket{
+			ProtocolVersion: ETH66,
+			NetworkID:       5,
+			TD:              big.NewInt(10790000),
+			Head:            common.Hash{0xaa},
+			Genesis:         params.GoerliGenesisHash,
+			ForkID:          forkid.NewID(params.GoerliChainConfig, params.GoerliGenesisHash, 6000000),
+		}
+	)
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+	defer net.Close()
+
+	peer := NewPeer(ETH66, p2p.NewPeer(enode.ID{1}, "peer", nil), net, nil)
+	defer peer.Close()
+
+	go p2p.Send(app, StatusMsg, &remote)
+
+	done := make(chan error, 1)
+	go func() {
+		n, err := peer.MirrorHandshake(networks)
+		if err == nil && n != goerli {
+			err = errors.New("wrong network matched")
+		}
+		done <- err
+	}()
+	// The answer must mirror the remote chain of the matched network, with
+	// the version negotiated for this peer.
+	msg, err := app.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var answer StatusPacket
+	if err := msg.Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if answer.ProtocolVersion != ETH66 {
+		t.Fatalf("wrong protocol version %d", answer.ProtocolVersion)
+	}
+	if answer.NetworkID != 5 || answer.Genesis != remote.Genesis || answer.Head != remote.Head || answer.TD.Cmp(remote.TD) != 0 || answer.ForkID != remote.ForkID {
+		t.Fatalf("wrong answer: %+v", answer)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if goerli.TD().Cmp(remote.TD) != 0 || networks[0].Status() != nil {
+		t.Fatal("status mirrored into the wrong network")
+	}
+}
+
+func TestNetworkHeadQuorum(t *testing.T) {
+	n := NewNetwork("mainnet", 1, params.MainnetGenesisHash, params.MainnetChainConfig, forkid.Tolerance{})
+
+	// A bogus first announcement must not pin the head.
+	n.SetHead("a", 1<<63)
+	n.SetHead("a", 1<<63)
+	if head := n.Head(); head != 0 {
+		t.Fatalf("head set by a single peer: %d", head)
+	}
+	n.SetHead("b", 14000000)
+	n.SetHead("c", 14000000)
+	if head := n.Head(); head != 14000000 {
+		t.Fatalf("wrong head %d, want the highest number announced by a quorum", head)
+	}
+	// A single peer climbing a ladder of numbers must not raise the head.
+	for i := uint64(1); i <= 10; i++ {
+		n.SetHead("d", 14000000+i*maxHeadJump)
+	}
+	if head := n.Head(); head != 14000000 {
+		t.Fatalf("head raised by a single peer: %d", head)
+	}
+	n.SetHead("b", 14000002)
+	if head := n.Head(); head != 14000002 {
+		t.Fatalf("wrong head %d after the quorum moved", head)
+	}
+}
+
+func TestNetworkForkTolerance(t *testing.T) {
+	strict := NewNetwork("mainnet", 1, params.MainnetGenesisHash, params.MainnetChainConfig, forkid.Tolerance{})
+	tolerant := NewNetwork("mainnet", 1, params.MainnetGenesisHash, params.MainnetChainConfig, forkid.Tolerance{Behind: 1, Ahead: true})
+
+	// The head follows the announcements of the peers, ignoring bogus jumps.
+	for _, n := range []*Network{strict, tolerant} {
+		n.SetHead("a", 14000000)
+		n.SetHead("b", 13999999)
+		n.SetHead("b", 14000000)
+		n.SetHead("c", 14000000)
+		n.SetHead("a", 14000000+maxHeadJump+1)
+		n.SetHead("b", 14000000+maxHeadJump+1)
+		n.SetHead("c", 14000000+maxHeadJump+1)
+		if head := n.Head(); head != 14000000 {
+			t.Fatalf("wrong head %d", head)
+		}
+	}
+	london := forkid.NewID(params.MainnetChainConfig, params.MainnetGenesisHash, 13000000)
+	status := &StatusPacket{NetworkID: 1, Genesis: params.MainnetGenesisHash, ForkID: forkid.ID{Hash: london.Hash}}
+
+	_, err := Networks{strict}.Match("a", status)
+	if !errors.Is(err, errStatusMismatch) || !errors.Is(err, errForkIDRejected) || !errors.Is(err, forkid.ErrRemoteStale) {
+		t.Fatalf("wrong rejection %v", err)
+	}
+	if _, err := (Networks{tolerant}).Match("a", status); err != nil {
+		t.Fatalf("peer one fork behind rejected: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/mclock"
+)
+
+const (
+	// defaultOracleQuorum is the number of peers which must report at least the
+	// total difficulty of a status before it is mirrored.
+	defaultOracleQuorum = 3
+
+	// oracleStatusTTL is the time after which a peer report is disregarded if
+	// the peer did not refresh it.
+	oracleStatusTTL = 10 * time.Minute
+
+	// maxOraclePeers is the maximum number of peer reports to keep, the oldest
+	// report is dropped beyond it.
+	maxOraclePeers = 1024
+
+	// maxTDBits is the maximum bit length of an acceptable total difficulty. TD
+	// at mainnet block #7753254 is 76 bits. If it becomes 100 million times
+	// larger, it will still fit within 100 bits.
+	maxTDBits = 100
+)
+
+// oracleReport is the latest status reported by a single peer.
+type oracleReport struct {
+	status *StatusPacket
+	time   mclock.AbsTime
+}
+
+// StatusOracle estimates the chain status of a network from the statuses its
+// peers report. A single peer can neither lower nor inflate the estimate: the
+// best status is the one whose total difficulty is matched or exceeded by a
+// quorum of peers, and it is only replaced by a better one or once it expired.
+type StatusOracle struct {
+	quorum int
+	clock  mclock.Clock
+
+	lock     sync.Mutex
+	reports  map[string]*oracleReport
+	best     *StatusPacket
+	bestTime mclock.AbsTime
+}
+
+// NewStatusOracle creates an oracle requiring the given quorum of peers.
+func NewStatusOracle(quorum int, clock mclock.Clock) *StatusOracle {
+	if quorum < 1 {
+		quorum = 1
+	}
+	if clock == nil {
+		clock = mclock.System{}
+	}
+	return &StatusOracle{
+		quorum:  quorum,
+		clock:   clock,
+		reports: make(map[string]*oracleReport),
+	}
+}
+
+// Observe records the status reported by a peer, replacing its previous report.
+func (o *StatusOracle) Observe(peer string, status *StatusPacket) error {
+	if err := checkTD(status.TD); err != nil {
+		return err
+	}
+	cpy := *status
+	cpy.TD = new(big.Int).Set(status.TD)
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if _, ok := o.reports[peer]; !ok && len(o.reports) >= maxOraclePeers {
+		o.evictOldest()
+	}
+	o.reports[peer] = &oracleReport{status: &cpy, time: o.clock.Now()}
+	o.update()
+	return nil
+}
+
+// Update advances the head reported by a known peer, as announced by a block
+// propagation. Updates of unknown peers and lower total difficulties are ignored.
+func (o *StatusOracle) Update(peer string, head common.Hash, td *big.Int) error {
+	if err := checkTD(td); err != nil {
+		return err
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	report := o.reports[peer]
+	if report == nil || report.status.TD.Cmp(td) >= 0 {
+		return nil
+	}
+	cpy := *report.status
+	cpy.Head, cpy.TD = head, new(big.Int).Set(td)
+	o.reports[peer] = &oracleReport{status: &cpy, time: o.clock.Now()}
+	o.update()
+	return nil
+}
+
+// Status returns the best status backed by a quorum of peers, or nil if no
+// peer reported yet.
+func (o *StatusOracle) Status() *StatusPacket {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.best == nil {
+		return nil
+	}
+	cpy := *o.best
+	cpy.TD = new(big.Int).Set(o.best.TD)
+	return &cpy
+}
+
+// update drops the stale reports and recomputes the best status. As long as
+// fewer peers than the quorum reported, all of them must back the status.
+func (o *StatusOracle) update() {
+	now := o.clock.Now()
+	tds := make([]*StatusPacket, 0, len(o.reports))
+	for peer, report := range o.reports {
+		if now.Sub(report.time) > oracleStatusTTL {
+			delete(o.reports, peer)
+			continue
+		}
+		tds = append(tds, report.status)
+	}
+	if len(tds) == 0 {
+		return
+	}
+	sort.Slice(tds, func(i, j int) bool {
+		return tds[i].TD.Cmp(tds[j].TD) > 0
+	})
+	candidate := tds[min(len(tds), o.quorum)-1]
+	if o.best == nil || candidate.TD.Cmp(o.best.TD) >= 0 || now.Sub(o.bestTime) > oracleStatusTTL {
+		o.best, o.bestTime = candidate, now
+	}
+}
+
+// evictOldest drops the least recently refreshed peer report.
+func (o *StatusOracle) evictOldest() {
+	var (
+		oldest  string
+		updated mclock.AbsTime
+		found   bool
+	)
+	for peer, report := range o.reports {
+		if !found || report.time < updated {
+			oldest, updated, found = peer, report.time, true
+		}
+	}
+	delete(o.reports, oldest)
+}
+
+// checkTD rejects missing and implausibly large total difficulties.
+func checkTD(td *big.Int) error {
+	if td == nil || td.Sign() < 0 {
+		return fmt.Errorf("%w: invalid total difficulty", errDecode)
+	}
+	if bits := td.BitLen(); bits > maxTDBits {
+		return fmt.Errorf("too large total difficulty: bitlen %d", bits)
+	}
+	return nil
+}
+
+// min is a helper function which returns the smaller of the two given integers.
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/mclock"
+)
+
+func oracleStatus(head byte, td int64) *StatusPacket {
+	return &StatusPacket{Head: common.Hash{head}, TD: big.NewInt(td)}
+}
+
+func checkOracle(t *testing.T, o *StatusOracle, head byte, td int64) {
+	t.Helper()
+
+	status := o.Status()
+	if status == nil {
+		t.Fatalf("no status, want head %x td %d", head, td)
+	}
+	if status.Head != (common.Hash{head}) || status.TD.Cmp(big.NewInt(td)) != 0 {
+		t.Fatalf("wrong status: head %x td %v, want head %x td %d", status.Head[0], status.TD, head, td)
+	}
+}
+
+func TestStatusOracleQuorum(t *testing.T) {
+	clock := new(mclock.Simulated)
+	o := NewStatusOracle(3, clock)
+	if o.Status() != nil {
+		t.Fatal("status before any report")
+	}
+	// Below the quorum, all peers must back the status.
+	o.Observe("a", oracleStatus(1, 100))
+	checkOracle(t, o, 1, 100)
+	o.Observe("b", oracleStatus(2, 200))
+	checkOracle(t, o, 1, 100)
+
+	// A single lying peer can't inflate the status.
+	o.Observe("evil", oracleStatus(0xee, 1<<62))
+	checkOracle(t, o, 1, 100)
+	o.Observe("c", oracleStatus(3, 300))
+	checkOracle(t, o, 2, 200)
+	o.Observe("d", oracleStatus(4, 400))
+	checkOracle(t, o, 3, 300)
+
+	// Block propagations advance the heads of known peers only.
+	o.Update("unknown", common.Hash{5}, big.NewInt(500))
+	checkOracle(t, o, 3, 300)
+	o.Update("c", common.Hash{5}, big.NewInt(500))
+	o.Update("d", common.Hash{5}, big.NewInt(500))
+	checkOracle(t, o, 5, 500)
+
+	// A lagging peer can't roll the status back.
+	o.Update("d", common.Hash{1}, big.NewInt(100))
+	o.Observe("c", oracleStatus(1, 100))
+	checkOracle(t, o, 5, 500)
+
+	// Once the backing reports expired, the status follows the live peers.
+	clock.Run(oracleStatusTTL + 1)
+	o.Observe("e", oracleStatus(6, 50))
+	checkOracle(t, o, 6, 50)
+}
+
+func TestStatusOracleMaliciousTD(t *testing.T) {
+	o := NewStatusOracle(1, new(mclock.Simulated))
+	o.Observe("a", oracleStatus(1, 100))
+
+	huge := new(big.Int).Lsh(common.Big1, maxTDBits)
+	tests := []*big.Int{
+		nil,
+		big.NewInt(-1),
+		huge,
+		new(big.Int).Lsh(huge, 1000),
+	}
+	for i, td := range tests {
+		if err := o.Observe("evil", &StatusPacket{Head: common.Hash{0xee}, TD: td}); err == nil {
+			t.Errorf("test %d: status with td %v accepted", i, td)
+		}
+		if err := o.Update("a", common.Hash{0xee}, td); err == nil {
+			t.Errorf("test %d: update with td %v accepted", i, td)
+		}
+	}
+	checkOracle(t, o, 1, 100)
+
+	// The largest acceptable TD still passes.
+	max := new(big.Int).Sub(huge, common.Big1)
+	if err := o.Update("a", common.Hash{2}, max); err != nil {
+		t.Fatal(err)
+	}
+	if status := o.Status(); status.TD.Cmp(max) != 0 {
+		t.Fatalf("wrong td %v", status.TD)
+	}
+	// Handed out statuses must not alias the oracle state.
+	o.Status().TD.SetInt64(0)
+	if o.Status().TD.Cmp(max) != 0 {
+		t.Fatal("oracle status modified through a copy")
+	}
+}
+
+func TestStatusOracleEviction(t *testing.T) {
+	clock := new(mclock.Simulated)
+	o := NewStatusOracle(1, clock)
+	for i := 0; i < maxOraclePeers+10; i++ {
+		o.Observe(string(rune('a'+i)), oracleStatus(byte(i), int64(i)))
+		clock.Run(1)
+	}
+	if n := len(o.reports); n != maxOraclePeers {
+		t.Fatalf("have %d reports, want %d", n, maxOraclePeers)
+	}
+	if _, ok := o.reports["a"]; ok {
+		t.Fatal("oldest report not evicted")
+	}
+}
@@ -75,6 +75,9 @@ type Peer struct {
 	head common.Hash // Latest advertised head block hash
 	td   *big.Int    // Latest advertised head block total difficulty
 
+	network  *Network          // Observed network the peer was matched to, nil if not mirrored
+	messages map[uint64]uint64 // Number of messages received from the peer by code
+
 	knownBlocks     *knownCache            // Set of block hashes known to be known by this peer
 	queuedBlocks    chan *blockPropagation // Queue of blocks to broadcast to the peer
 	queuedBlockAnns chan *types.Block      // Queue of blocks to announce to the peer
@@ -110,6 +113,7 @@ func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter, txpool TxPool) *Pe
 		reqCancel:       make(chan *cancel),
 		resDispatch:     make(chan *response),
 		txpool:          txpool,
+		messages:        make(map[uint64]uint64),
 		term:            make(chan struct{}),
 	}
 	// Start up all the broadcasters
@@ -133,6 +137,43 @@ func (p *Peer) ID() string {
 	return p.id
 }
 
+// Network retrieves the observed network the peer was matched to during the
+// handshake, nil if the peer was not mirrored.
+func (p *Peer) Network() *Network {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.network
+}
+
+// Messages returns the number of messages received from the peer by code.
+func (p *Peer) Messages() map[uint64]uint64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	messages := make(map[uint64]uint64, len(p.messages))
+	for code, n := range p.messages {
+		messages[code] = n
+	}
+	return messages
+}
+
+// countMessage counts a message received from the peer.
+func (p *Peer) countMessage(code uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.messages[code]++
+}
+
+// ObserveHeaders stores headers delivered or propagated by the peer in the
+// header cache of its network, to be served to other peers.
+func (p *Peer) ObserveHeaders(headers []*types.Header) {
+	if network := p.Network(); network != nil {
+		network.Headers.Add(headers...)
+	}
+}
+
 // Version retrieves the peer's negoatiated `eth` protocol version.
 func (p *Peer) Version() uint {
 	return p.version
@@ -75,6 +75,7 @@ var (
 	errNetworkIDMismatch       = errors.New("network ID mismatch")
 	errGenesisMismatch         = errors.New("genesis mismatch")
 	errForkIDRejected          = errors.New("fork ID rejected")
+	errStatusMismatch          = errors.New("not match")
 )
 
 // Packet represents a p2p message in the `eth` protocol.
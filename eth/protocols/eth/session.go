@@ -0,0 +1,152 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"peerInfoCollect/log"
+	"peerInfoCollect/metrics"
+	"peerInfoCollect/p2p/clientinfo"
+	"peerInfoCollect/record"
+)
+
+// TrackSession records the start of the session with the peer and, once the
+// underlying connection was torn down, its end along with the disconnect
+// reason and the traffic exchanged. While the session lasts, the peer is
+// counted in the gauge of its client version.
+func (p *Peer) TrackSession() {
+	started := time.Now()
+	p.publishSession(p.sessionRecord(record.SessionConnected, started))
+
+	var gauge string
+	if metrics.Enabled {
+		gauge = clientGaugeName(clientinfo.Parse(p.Name()))
+		updateClientGauge(gauge, 1)
+	}
+	go func() {
+		<-p.Peer.Closed()
+		if gauge != "" {
+			updateClientGauge(gauge, -1)
+		}
+
+		rec := p.sessionRecord(record.SessionDisconnected, time.Now())
+		reason, remote := p.Peer.DiscReason()
+		rec.Reason = reason.String()
+		rec.RemoteRequested = remote
+		rec.Duration = uint64(time.Since(started))
+		messages := p.Messages()
+		for code, count := range messages {
+			rec.Messages = append(rec.Messages, record.MessageCount{Code: code, Count: count})
+		}
+		sort.Slice(rec.Messages, func(i, j int) bool {
+			return rec.Messages[i].Code < rec.Messages[j].Code
+		})
+		rec.BytesIn, rec.BytesOut = p.Peer.Traffic()
+		p.publishSession(rec)
+	}()
+}
+
+// sessionRecord assembles the fields of a session record known at any time.
+func (p *Peer) sessionRecord(event string, now time.Time) *record.PeerSessionRecordInfo {
+	head, td := p.Head()
+	rec := &record.PeerSessionRecordInfo{
+		PeerId:   p.id,
+		Enode:    p.Node().URLv4(),
+		PeerAddr: p.RemoteAddr().String(),
+		Name:     p.Name(),
+		Inbound:  p.Peer.Inbound(),
+		Event:    event,
+		Head:     head.String(),
+		Time:     uint64(now.UnixNano()),
+	}
+	if td != nil {
+		rec.TD = td.String()
+	}
+	if network := p.Network(); network != nil {
+		rec.Network = network.Name
+	}
+	return rec
+}
+
+// publishSession publishes a session record to the record sinks.
+func (p *Peer) publishSession(rec *record.PeerSessionRecordInfo) {
+	if err := record.Publish(rec); err != nil {
+		log.Debug("Failed to publish peer session", "peer", p.id, "event", rec.Event, "err", err)
+	}
+}
+
+// knownClients are the clients counted in gauges of their own, peers running
+// any other client are counted as eth/clients/other.
+var knownClients = map[string]bool{
+	"besu":         true,
+	"coregeth":     true,
+	"erigon":       true,
+	"geth":         true,
+	"nethermind":   true,
+	"nimbus":       true,
+	"openethereum": true,
+	"reth":         true,
+}
+
+// clientGaugeLock serializes the updates of the client gauges, so that a
+// gauge isn't unregistered while another session registers it.
+var clientGaugeLock sync.Mutex
+
+// updateClientGauge adds delta to a client gauge. Gauges dropping to zero are
+// unregistered, keeping the number of gauges bounded by the connected peers.
+func updateClientGauge(name string, delta int64) {
+	clientGaugeLock.Lock()
+	defer clientGaugeLock.Unlock()
+
+	gauge := metrics.GetOrRegisterGauge(name, nil)
+	gauge.Inc(delta)
+	if gauge.Value() <= 0 {
+		metrics.Unregister(name)
+	}
+}
+
+// clientGaugeName returns the name of the gauge counting the connected peers
+// running a client release, e.g. eth/clients/geth/1_10. Only the major and
+// minor version are kept.
+func clientGaugeName(info clientinfo.Info) string {
+	switch {
+	case !info.Parsed():
+		return "eth/clients/unknown"
+	case !knownClients[info.Client]:
+		return "eth/clients/other"
+	}
+	version := strings.SplitN(info.Version, ".", 3)
+	if len(version) > 2 {
+		version = version[:2]
+	}
+	return "eth/clients/" + info.Client + "/" + sanitizeMetricName(strings.Join(version, "."))
+}
+
+// sanitizeMetricName replaces the characters not allowed in Prometheus metric
+// names with underscores.
+func sanitizeMetricName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
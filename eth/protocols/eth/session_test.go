@@ -0,0 +1,60 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+
+	"peerInfoCollect/metrics"
+	"peerInfoCollect/p2p/clientinfo"
+)
+
+func TestClientGaugeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Geth/v1.10.17-stable-25c9b49f/linux-amd64/go1.18", "eth/clients/geth/1_10"},
+		{"erigon/v2022.05.2-stable-d7ed9dfc/linux-amd64/go1.18.1", "eth/clients/erigon/2022_05"},
+		{"besu/v22.4.1/linux-x86_64/openjdk-java-11", "eth/clients/besu/22_4"},
+		{"MyFork/v1.10.17/linux-amd64/go1.18", "eth/clients/other"},
+		{"garbage", "eth/clients/unknown"},
+	}
+	for _, tt := range tests {
+		if have := clientGaugeName(clientinfo.Parse(tt.name)); have != tt.want {
+			t.Errorf("%q: have %q, want %q", tt.name, have, tt.want)
+		}
+	}
+}
+
+func TestClientGaugeUnregister(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	const name = "eth/clients/test/1_0"
+	updateClientGauge(name, 1)
+	updateClientGauge(name, 1)
+	updateClientGauge(name, -1)
+	if metrics.DefaultRegistry.Get(name) == nil {
+		t.Fatal("gauge unregistered while peers are counted")
+	}
+	updateClientGauge(name, -1)
+	if metrics.DefaultRegistry.Get(name) != nil {
+		t.Fatal("gauge not unregistered at zero")
+	}
+}
@@ -155,6 +155,9 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 	if cs.doneCh != nil {
 		return nil // Sync already running
 	}
+	if cs.handler.observer != nil {
+		return nil // Observers never synchronise
+	}
 	// If a beacon client once took over control, disable the entire legacy sync
 	// path from here on end. Note, there is a slight "race" between reaching TTD
 	// and the beacon client taking over. The downloader will enforce that nothing
@@ -184,9 +187,11 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 		return nil
 	}
 	mode, ourTD := cs.modeAndLocalHead()
-	ourTD = eth.TdRecord.Load().(*big.Int)
-	if ourTD == nil{
-		ourTD = big.NewInt(0)
+	// Compare against the status of our own network backed by a quorum of its
+	// peers, not the local chain
+	ourTD = new(big.Int)
+	if network := cs.handler.networks.Lookup(cs.handler.networkID); network != nil {
+		ourTD = network.TD()
 	}
 	log.Info("nextSyncOp---","ourTD 值",ourTD.Uint64())
 	op := peerToSyncOp(mode, peer)
@@ -209,6 +214,11 @@ func peerToSyncOp(mode downloader.SyncMode, p *eth.Peer) *chainSyncOp {
 }
 
 func (cs *chainSyncer) modeAndLocalHead() (downloader.SyncMode, *big.Int) {
+	// If we're only observing, the local chain never moves
+	if cs.handler.observer != nil {
+		head := cs.handler.chain.CurrentBlock()
+		return downloader.ObserverSync, cs.handler.chain.GetTd(head.Hash(), head.NumberU64())
+	}
 	// If we're in snap sync mode, return that directly
 	if atomic.LoadUint32(&cs.handler.snapSync) == 1 {
 		block := cs.handler.chain.CurrentFastBlock()
@@ -25,6 +25,7 @@ import (
 	"peerInfoCollect/log"
 	"peerInfoCollect/p2p"
 	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/peerdb"
 	"peerInfoCollect/rpc"
 )
 
@@ -44,6 +45,11 @@ func (n *Node) apis() []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   debug.Handler,
+		}, {
+			Namespace: "peerdb",
+			Version:   "1.0",
+			Service:   peerdb.NewAPI(PeerRegistry),
+			Public:    true,
 		},
 	}
 }
@@ -30,7 +30,9 @@ import (
 	"peerInfoCollect/crypto"
 	"peerInfoCollect/log"
 	"peerInfoCollect/p2p"
+	"peerInfoCollect/p2p/disctap"
 	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/record"
 	"peerInfoCollect/rpc"
 )
 
@@ -151,6 +153,14 @@ type Config struct {
 
 	// JWTSecret is the hex-encoded jwt secret.
 	JWTSecret string `toml:",omitempty"`
+
+	// Record configures the sinks collected observations are published to.
+	// It is filled from the top level [Record] section of the config file.
+	Record record.Config `toml:"-"`
+
+	// DiscoveryTap configures publishing a sample of the inbound discovery
+	// packets to the record sinks.
+	DiscoveryTap disctap.Config `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
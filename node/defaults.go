@@ -23,7 +23,9 @@ import (
 	"runtime"
 
 	"peerInfoCollect/p2p"
+	"peerInfoCollect/p2p/disctap"
 	"peerInfoCollect/p2p/nat"
+	"peerInfoCollect/record"
 	"peerInfoCollect/rpc"
 )
 
@@ -57,6 +59,8 @@ var DefaultConfig = Config{
 		MaxPeers:   50,
 		NAT:        nat.Any(),
 	},
+	Record:       record.DefaultConfig,
+	DiscoveryTap: disctap.DefaultConfig,
 }
 
 // DefaultDataDir is the default data directory to use for the databases and other
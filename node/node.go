@@ -22,7 +22,7 @@ import (
 	crand "crypto/rand"
 	"errors"
 	"fmt"
-	lru "github.com/hashicorp/golang-lru"
+	"peerInfoCollect/peerdb"
 	"peerInfoCollect/record"
 	"net/http"
 	"os"
@@ -39,21 +39,16 @@ import (
 	"peerInfoCollect/event"
 	"peerInfoCollect/log"
 	"peerInfoCollect/p2p"
+	"peerInfoCollect/p2p/disctap"
 	"peerInfoCollect/rpc"
 	"github.com/prometheus/tsdb/fileutil"
 )
 
 
-var PeerInfoCache *lru.Cache
-
-var BlockHashCache *lru.Cache
-
-
-//add lru cache record
-func init()  {
-	BlockHashCache,_ = lru.New(10000)
-	PeerInfoCache,_ = lru.New(100)
-}
+// PeerRegistry keeps every peer the collector has talked to. It lives in
+// memory until the node starts and switches to the peers database of the
+// data directory.
+var PeerRegistry = peerdb.New(nil)
 
 // Node is a container on which services can be registered.
 type Node struct {
@@ -66,6 +61,8 @@ type Node struct {
 	dirLock       fileutil.Releaser // prevents concurrent use of instance directory
 	stop          chan struct{}     // Channel to wait for termination notifications
 	server        *p2p.Server       // Currently running P2P networking layer
+	discTap       *disctap.Tap      // Publishes sampled discovery packets, nil if disabled
+	recordOpen    bool              // Whether Start opened the record sinks and the peer registry
 	startStopLock sync.Mutex        // Start/Stop are protected by an additional lock
 	state         int               // Tracks state of node lifecycle
 
@@ -187,6 +184,33 @@ func (n *Node) Start() error {
 		return ErrNodeStopped
 	}
 	n.state = runningState
+
+	// Open the record sinks before any peer can produce observations.
+	recordCfg := n.config.Record
+	if recordCfg.SpoolDir != "" {
+		recordCfg.SpoolDir = n.ResolvePath(recordCfg.SpoolDir)
+	}
+	if recordCfg.GeoCity != "" {
+		recordCfg.GeoCity = n.ResolvePath(recordCfg.GeoCity)
+	}
+	if recordCfg.GeoASN != "" {
+		recordCfg.GeoASN = n.ResolvePath(recordCfg.GeoASN)
+	}
+	if err := record.Open(&recordCfg); err != nil {
+		n.lock.Unlock()
+		n.doClose(nil)
+		return err
+	}
+	n.recordOpen = true
+	if err := n.openPeerRegistry(); err != nil {
+		n.lock.Unlock()
+		n.doClose(nil)
+		return err
+	}
+	if n.config.DiscoveryTap.Enabled() {
+		n.discTap = disctap.New(n.config.DiscoveryTap, nil)
+		n.server.Config.DiscoveryTap = n.discTap
+	}
 	// open networking and RPC endpoints
 	err := n.openEndpoints()
 	lifecycles := make([]Lifecycle, len(n.lifecycles))
@@ -207,13 +231,6 @@ func (n *Node) Start() error {
 		started = append(started, lifecycle)
 	}
 
-	//// Start mongo db
-	//record.MgoCli = record.GetMgoCli()
-	//record.NewConnectionWithDBName("blockRecord","blockinfo")
-
-	//start redis
-	record.RdbClient = record.GetRdbCli()
-
 	// Check if any lifecycle failed to start.
 	if err != nil {
 		n.stopServices(started)
@@ -251,6 +268,8 @@ func (n *Node) Close() error {
 
 // doClose releases resources acquired by New(), collecting errors.
 func (n *Node) doClose(errs []error) error {
+	n.closeRecords()
+
 	// Close databases. This needs the lock because it needs to
 	// synchronize with OpenDatabase*.
 	n.lock.Lock()
@@ -332,6 +351,24 @@ func (n *Node) stopServices(running []Lifecycle) error {
 	return nil
 }
 
+// closeRecords stops the discovery tap, publishing the packets still queued,
+// flushes and closes the record sinks and detaches the peer registry, whose
+// database is closed with the others. It runs once nothing can publish
+// anymore, also when Start fails midway.
+func (n *Node) closeRecords() {
+	if n.discTap != nil {
+		n.discTap.Close()
+	}
+	if !n.recordOpen {
+		return
+	}
+	if err := record.Close(); err != nil {
+		n.log.Warn("Failed to close record sinks", "err", err)
+	}
+	PeerRegistry.SetDatabase(nil)
+	n.recordOpen = false
+}
+
 func (n *Node) openDataDir() error {
 	if n.config.DataDir == "" {
 		return nil // ephemeral
@@ -698,6 +735,22 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 	return db, err
 }
 
+// openPeerRegistry attaches the peer registry to the peers database in the
+// instance directory. The node lock must be held.
+func (n *Node) openPeerRegistry() error {
+	var db ethdb.Database
+	if n.config.DataDir == "" {
+		db = rawdb.NewMemoryDatabase()
+	} else {
+		var err error
+		if db, err = rawdb.NewLevelDBDatabase(n.ResolvePath("peers"), 16, 16, "peers/", false); err != nil {
+			return err
+		}
+	}
+	PeerRegistry.SetDatabase(n.wrapDatabase(db))
+	return nil
+}
+
 // ResolvePath returns the absolute path of a resource in the instance directory.
 func (n *Node) ResolvePath(x string) string {
 	return n.config.ResolvePath(x)
@@ -0,0 +1,148 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package clientinfo parses the client names peers report in the RLPx hello,
+// such as Geth/v1.10.17-stable/linux-amd64/go1.18, into structured fields.
+package clientinfo
+
+import (
+	"strings"
+)
+
+// Info is the parsed form of a client name. If the name could not be parsed,
+// only Raw is set.
+type Info struct {
+	Client  string `json:"client"`  // lower-case client name, e.g. geth
+	Version string `json:"version"` // numeric version without the v prefix, e.g. 1.10.17
+	Build   string `json:"build"`   // version suffix, e.g. stable-25c9b49f
+	OS      string `json:"os"`      // normalized operating system, e.g. linux
+	Arch    string `json:"arch"`    // normalized architecture, e.g. amd64
+	Runtime string `json:"runtime"` // language runtime, e.g. go1.18
+	Raw     string `json:"raw"`     // the name as reported by the peer
+}
+
+// Parsed reports whether the name was recognized.
+func (i Info) Parsed() bool {
+	return i.Client != ""
+}
+
+// Operating system and architecture spellings used by the various clients,
+// mapped to the names used by Go.
+var (
+	osNames = map[string]string{
+		"linux":   "linux",
+		"windows": "windows",
+		"win":     "windows",
+		"darwin":  "darwin",
+		"macos":   "darwin",
+		"osx":     "darwin",
+		"freebsd": "freebsd",
+		"openbsd": "openbsd",
+		"netbsd":  "netbsd",
+		"android": "android",
+		"ios":     "ios",
+	}
+	archNames = map[string]string{
+		"amd64":   "amd64",
+		"x86_64":  "amd64",
+		"x64":     "amd64",
+		"386":     "386",
+		"i386":    "386",
+		"i686":    "386",
+		"x86":     "386",
+		"arm64":   "arm64",
+		"aarch64": "arm64",
+		"arm":     "arm",
+		"armv7":   "arm",
+		"armv7l":  "arm",
+		"riscv64": "riscv64",
+		"ppc64le": "ppc64le",
+		"s390x":   "s390x",
+	}
+)
+
+// Parse splits a client name into its fields. Names follow the layout
+//
+//	client[/identity]/version[/platform[/runtime]]
+//
+// shared by Geth, Nethermind, Erigon, Besu, Reth, OpenEthereum and most other
+// clients; the platform part is either os-arch or arch-os.
+func Parse(name string) Info {
+	info := Info{Raw: name}
+
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 || parts[0] == "" {
+		return info
+	}
+	// Locate the version, skipping any custom identity before it
+	v := -1
+	for i := 1; i < len(parts); i++ {
+		if version, build, ok := parseVersion(parts[i]); ok {
+			info.Version, info.Build, v = version, build, i
+			break
+		}
+	}
+	if v < 0 {
+		return info
+	}
+	info.Client = strings.ToLower(parts[0])
+
+	rest := parts[v+1:]
+	if len(rest) > 0 {
+		info.OS, info.Arch = parsePlatform(rest[0])
+		if info.OS != "" || info.Arch != "" {
+			rest = rest[1:]
+		}
+	}
+	info.Runtime = strings.Join(rest, "/")
+	return info
+}
+
+// parseVersion splits a version tag such as v1.10.17-stable-25c9b49f into the
+// numeric version and the build suffix.
+func parseVersion(s string) (version string, build string, ok bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	end := 0
+	for end < len(s) && (s[end] == '.' || (s[end] >= '0' && s[end] <= '9')) {
+		end++
+	}
+	version = strings.TrimRight(s[:end], ".")
+	if version == "" || version[0] == '.' {
+		return "", "", false
+	}
+	return version, strings.TrimLeft(s[end:], "-+"), true
+}
+
+// parsePlatform extracts the operating system and architecture from a
+// platform string such as linux-amd64, X64-Linux or x86_64-unknown-linux-gnu.
+func parsePlatform(s string) (os string, arch string) {
+	s = strings.ToLower(s)
+	for _, token := range strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == ' ' }) {
+		// Match whole tokens first, x86_64 contains an underscore itself
+		if a, ok := archNames[token]; ok && arch == "" {
+			arch = a
+			continue
+		}
+		for _, sub := range strings.Split(token, "_") {
+			if o, ok := osNames[sub]; ok && os == "" {
+				os = o
+			} else if a, ok := archNames[sub]; ok && arch == "" {
+				arch = a
+			}
+		}
+	}
+	return os, arch
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clientinfo
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Info
+	}{
+		{
+			name: "Geth/v1.10.17-stable-25c9b49f/linux-amd64/go1.18",
+			want: Info{Client: "geth", Version: "1.10.17", Build: "stable-25c9b49f", OS: "linux", Arch: "amd64", Runtime: "go1.18"},
+		},
+		{
+			name: "Geth/node-1/v1.10.17-stable/darwin-arm64/go1.18.1",
+			want: Info{Client: "geth", Version: "1.10.17", Build: "stable", OS: "darwin", Arch: "arm64", Runtime: "go1.18.1"},
+		},
+		{
+			name: "Nethermind/v1.13.0-0-2e8910b5b-20220520/X64-Linux/6.0.4",
+			want: Info{Client: "nethermind", Version: "1.13.0", Build: "0-2e8910b5b-20220520", OS: "linux", Arch: "amd64", Runtime: "6.0.4"},
+		},
+		{
+			name: "erigon/v2022.05.2-stable-d7ed9dfc/linux-amd64/go1.18.1",
+			want: Info{Client: "erigon", Version: "2022.05.2", Build: "stable-d7ed9dfc", OS: "linux", Arch: "amd64", Runtime: "go1.18.1"},
+		},
+		{
+			name: "besu/v22.4.1/linux-x86_64/openjdk-java-11",
+			want: Info{Client: "besu", Version: "22.4.1", OS: "linux", Arch: "amd64", Runtime: "openjdk-java-11"},
+		},
+		{
+			name: "reth/v0.1.0-alpha.1-1a2b3c4d/x86_64-unknown-linux-gnu",
+			want: Info{Client: "reth", Version: "0.1.0", Build: "alpha.1-1a2b3c4d", OS: "linux", Arch: "amd64"},
+		},
+		{
+			name: "OpenEthereum//v3.3.5-stable-6c2d392d8/x86_64-linux-gnu/rustc1.58.1",
+			want: Info{Client: "openethereum", Version: "3.3.5", Build: "stable-6c2d392d8", OS: "linux", Arch: "amd64", Runtime: "rustc1.58.1"},
+		},
+		{
+			name: "Geth/v1.10.17/go1.18",
+			want: Info{Client: "geth", Version: "1.10.17", Runtime: "go1.18"},
+		},
+		// Unparseable names are kept raw
+		{name: "nimbus-eth1 v0.1.0 [linux: amd64, rocksdb]"},
+		{name: "Geth/custom"},
+		{name: "/v1.0.0"},
+		{name: ""},
+	}
+	for _, tt := range tests {
+		tt.want.Raw = tt.name
+		if have := Parse(tt.name); have != tt.want {
+			t.Errorf("%q: wrong result\nhave %+v\nwant %+v", tt.name, have, tt.want)
+		}
+		if Parse(tt.name).Parsed() != (tt.want.Client != "") {
+			t.Errorf("%q: wrong parsed flag", tt.name)
+		}
+	}
+}
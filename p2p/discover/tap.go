@@ -0,0 +1,76 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"time"
+
+	"peerInfoCollect/p2p/discover/v4wire"
+	"peerInfoCollect/p2p/discover/v5wire"
+	"peerInfoCollect/p2p/enode"
+)
+
+// PacketTap is notified of inbound discovery packets. Packets that can't be
+// decoded are not reported. Packet is called on the read loop of the listener
+// and must not block.
+type PacketTap interface {
+	Packet(p *TapPacket)
+}
+
+// TapPacket describes an inbound discovery packet.
+type TapPacket struct {
+	Protocol string   // "discv4" or "discv5"
+	Name     string   // packet type, e.g. "PING/v4"
+	From     enode.ID // sender, zero if the packet doesn't reveal it
+	Addr     *net.UDPAddr
+	Seq      uint64 // ENR sequence number claimed by the sender, zero if none
+	Size     int
+	Err      error // reason the packet was rejected
+
+	Received time.Time
+	Duration time.Duration // time taken to decode and handle the packet
+}
+
+// v4PacketSeq returns the ENR sequence number a discv4 packet claims for its
+// sender.
+func v4PacketSeq(p v4wire.Packet) uint64 {
+	switch p := p.(type) {
+	case *v4wire.Ping:
+		return p.ENRSeq
+	case *v4wire.Pong:
+		return p.ENRSeq
+	case *v4wire.ENRResponse:
+		return p.Record.Seq()
+	}
+	return 0
+}
+
+// v5PacketSeq returns the ENR sequence number a discv5 packet claims for its
+// sender. The record sent in a handshake takes precedence.
+func v5PacketSeq(p v5wire.Packet, fromNode *enode.Node) uint64 {
+	if fromNode != nil {
+		return fromNode.Seq()
+	}
+	switch p := p.(type) {
+	case *v5wire.Ping:
+		return p.ENRSeq
+	case *v5wire.Pong:
+		return p.ENRSeq
+	}
+	return 0
+}
@@ -72,6 +72,7 @@ type UDPv4 struct {
 	localNode   *enode.LocalNode
 	db          *enode.DB
 	tab         *Table
+	tap         PacketTap
 	closeOnce   sync.Once
 	wg          sync.WaitGroup
 
@@ -140,6 +141,7 @@ func ListenV4(c UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv4, error) {
 		closeCtx:        closeCtx,
 		cancelCloseCtx:  cancel,
 		log:             cfg.Log,
+		tap:             cfg.Tap,
 	}
 
 	tab, err := newTable(t, ln.Database(), cfg.Bootnodes, t.log)
@@ -541,6 +543,7 @@ func (t *UDPv4) readLoop(unhandled chan<- ReadPacket) {
 }
 
 func (t *UDPv4) handlePacket(from *net.UDPAddr, buf []byte) error {
+	start := time.Now()
 	rawpacket, fromKey, hash, err := v4wire.Decode(buf)
 	if err != nil {
 		t.log.Debug("Bad discv4 packet", "addr", from, "err", err)
@@ -555,6 +558,19 @@ func (t *UDPv4) handlePacket(from *net.UDPAddr, buf []byte) error {
 	if err == nil && packet.handle != nil {
 		packet.handle(packet, from, fromID, hash)
 	}
+	if t.tap != nil {
+		t.tap.Packet(&TapPacket{
+			Protocol: "discv4",
+			Name:     packet.Name(),
+			From:     fromID,
+			Addr:     from,
+			Seq:      v4PacketSeq(rawpacket),
+			Size:     len(buf),
+			Err:      err,
+			Received: start,
+			Duration: time.Since(start),
+		})
+	}
 	return err
 }
 
@@ -142,6 +142,41 @@ func TestUDPv4_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, &v4wire.Neighbors{Expiration: futureExp})
 }
 
+type tapFunc func(*TapPacket)
+
+func (fn tapFunc) Packet(p *TapPacket) { fn(p) }
+
+func TestUDPv4_tap(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.close()
+
+	var tapped []*TapPacket
+	test.udp.tap = tapFunc(func(p *TapPacket) { tapped = append(tapped, p) })
+	test.packetIn(errExpired, &v4wire.Ping{From: testRemote, To: testLocalAnnounced, Version: 4, ENRSeq: 7})
+	test.packetIn(errUnknownNode, &v4wire.Findnode{Expiration: futureExp})
+
+	if len(tapped) != 2 {
+		t.Fatalf("wrong number of tapped packets %d", len(tapped))
+	}
+	remoteID := enode.PubkeyToIDV4(&test.remotekey.PublicKey)
+	for i, want := range []struct {
+		name string
+		seq  uint64
+		err  error
+	}{
+		{"PING/v4", 7, errExpired},
+		{"FINDNODE/v4", 0, errUnknownNode},
+	} {
+		p := tapped[i]
+		if p.Protocol != "discv4" || p.Name != want.name || p.Seq != want.seq || p.Err != want.err {
+			t.Errorf("packet %d: wrong packet %+v", i, p)
+		}
+		if p.From != remoteID || !p.Addr.IP.Equal(test.remoteaddr.IP) || p.Size != len(test.sent[i]) {
+			t.Errorf("packet %d: wrong sender %v %v", i, p.From, p.Addr)
+		}
+	}
+}
+
 func TestUDPv4_pingTimeout(t *testing.T) {
 	t.Parallel()
 	test := newUDPTest(t)
@@ -71,6 +71,7 @@ type UDPv5 struct {
 	log          log.Logger
 	clock        mclock.Clock
 	validSchemes enr.IdentityScheme
+	tap          PacketTap
 
 	// talkreq handler registry
 	trlock     sync.Mutex
@@ -148,6 +149,7 @@ func newUDPv5(conn UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
 		log:          cfg.Log,
 		validSchemes: cfg.ValidSchemes,
 		clock:        cfg.Clock,
+		tap:          cfg.Tap,
 		trhandlers:   make(map[string]TalkRequestHandler),
 		// channels into dispatch
 		packetInCh:    make(chan ReadPacket, 1),
@@ -644,6 +646,7 @@ func (t *UDPv5) dispatchReadPacket(from *net.UDPAddr, content []byte) bool {
 
 // handlePacket decodes and processes an incoming packet from the network.
 func (t *UDPv5) handlePacket(rawpacket []byte, fromAddr *net.UDPAddr) error {
+	start := time.Now()
 	addr := fromAddr.String()
 	fromID, fromNode, packet, err := t.codec.Decode(rawpacket, addr)
 	if err != nil {
@@ -659,6 +662,18 @@ func (t *UDPv5) handlePacket(rawpacket []byte, fromAddr *net.UDPAddr) error {
 		t.log.Trace("<< "+packet.Name(), "id", fromID, "addr", addr)
 	}
 	t.handle(packet, fromID, fromAddr)
+	if t.tap != nil {
+		t.tap.Packet(&TapPacket{
+			Protocol: "discv5",
+			Name:     packet.Name(),
+			From:     fromID,
+			Addr:     fromAddr,
+			Seq:      v5PacketSeq(packet, fromNode),
+			Size:     len(rawpacket),
+			Received: start,
+			Duration: time.Since(start),
+		})
+	}
 	return nil
 }
 
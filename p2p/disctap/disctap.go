@@ -0,0 +1,181 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package disctap publishes a sample of the inbound discovery packets to the
+// record sinks.
+//
+// Packets are sampled by sender: a node is either recorded with all of its
+// packets or not at all, so the number of distinct senders seen divided by the
+// sample fraction estimates the size of the discovery population.
+package disctap
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sync"
+
+	"peerInfoCollect/metrics"
+	"peerInfoCollect/p2p/discover"
+	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/record"
+	"golang.org/x/time/rate"
+)
+
+// queueSize is the number of packets buffered for publishing, packets arriving
+// while the queue is full are dropped.
+const queueSize = 256
+
+var (
+	publishMeter = metrics.NewRegisteredMeter("p2p/disctap/publish", nil)
+	limitMeter   = metrics.NewRegisteredMeter("p2p/disctap/limited", nil)
+	dropMeter    = metrics.NewRegisteredMeter("p2p/disctap/drop", nil)
+)
+
+// Config holds settings for the tap.
+type Config struct {
+	Sample    float64 // fraction of senders whose packets are recorded, zero disables the tap
+	RateLimit float64 // maximum records / second (default 100)
+}
+
+// DefaultConfig contains the default settings for the tap. It is disabled.
+var DefaultConfig = Config{
+	RateLimit: 100,
+}
+
+// Tap is a discover.PacketTap publishing sampled packets as
+// record.DiscPacketRecordInfo. Records are published on a goroutine of the
+// tap, so the discovery read loops never wait for the sinks.
+type Tap struct {
+	threshold uint64
+	sample    float64
+	limiter   *rate.Limiter
+	emit      func(record.Record)
+
+	queue     chan record.Record
+	quit      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// Enabled reports whether cfg samples any packets.
+func (cfg Config) Enabled() bool {
+	return cfg.Sample > 0
+}
+
+// New creates a tap. If emit is nil, packets are published to the record sinks.
+// The tap must be closed to release its goroutine.
+func New(cfg Config, emit func(record.Record)) *Tap {
+	if cfg.Sample > 1 {
+		cfg.Sample = 1
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = DefaultConfig.RateLimit
+	}
+	if emit == nil {
+		emit = func(rec record.Record) { record.Publish(rec) }
+	}
+	t := &Tap{
+		sample:  cfg.Sample,
+		limiter: rate.NewLimiter(rate.Limit(cfg.RateLimit), int(math.Ceil(cfg.RateLimit))),
+		emit:    emit,
+		queue:   make(chan record.Record, queueSize),
+		quit:    make(chan struct{}),
+	}
+	switch {
+	case cfg.Sample <= 0:
+		t.sample = 0
+	case cfg.Sample == 1:
+		t.threshold = math.MaxUint64
+	default:
+		t.threshold = uint64(cfg.Sample * math.MaxUint64)
+	}
+	t.wg.Add(1)
+	go t.loop()
+	return t
+}
+
+// Close stops the tap after publishing the queued packets.
+func (t *Tap) Close() {
+	t.closeOnce.Do(func() { close(t.quit) })
+	t.wg.Wait()
+}
+
+// loop publishes the queued records until the tap is closed.
+func (t *Tap) loop() {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case rec := <-t.queue:
+			t.emit(rec)
+		case <-t.quit:
+			for {
+				select {
+				case rec := <-t.queue:
+					t.emit(rec)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Packet implements discover.PacketTap.
+func (t *Tap) Packet(p *discover.TapPacket) {
+	if !t.sampled(p) {
+		return
+	}
+	if !t.limiter.Allow() {
+		limitMeter.Mark(1)
+		return
+	}
+	rec := &record.DiscPacketRecordInfo{
+		PeerAddr: p.Addr.String(),
+		Protocol: p.Protocol,
+		Packet:   p.Name,
+		Seq:      p.Seq,
+		Size:     uint64(p.Size),
+		Duration: uint64(p.Duration),
+		Time:     uint64(p.Received.UnixNano()),
+	}
+	if p.From != (enode.ID{}) {
+		rec.PeerId = p.From.String()
+	}
+	if p.Err != nil {
+		rec.Error = p.Err.Error()
+	}
+	select {
+	case t.queue <- rec:
+		publishMeter.Mark(1)
+	default:
+		dropMeter.Mark(1)
+	}
+}
+
+// sampled reports whether the packet's sender is part of the sample. Node IDs
+// are hashes, so their leading bytes are uniformly distributed. Packets not
+// revealing the sender are sampled randomly.
+func (t *Tap) sampled(p *discover.TapPacket) bool {
+	if t.sample == 0 {
+		return false
+	}
+	if p.From == (enode.ID{}) {
+		return rand.Float64() < t.sample
+	}
+	return binary.BigEndian.Uint64(p.From[:8]) <= t.threshold
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package disctap
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"peerInfoCollect/p2p/discover"
+	"peerInfoCollect/p2p/enode"
+	"peerInfoCollect/record"
+)
+
+func testPacket(id enode.ID) *discover.TapPacket {
+	return &discover.TapPacket{
+		Protocol: "discv4",
+		Name:     "PING/v4",
+		From:     id,
+		Addr:     &net.UDPAddr{IP: net.IP{1, 2, 3, 4}, Port: 30303},
+		Seq:      5,
+		Size:     120,
+		Err:      errors.New("expired"),
+		Received: time.Unix(0, 100),
+		Duration: 7,
+	}
+}
+
+func TestTapRecord(t *testing.T) {
+	var recs []record.Record
+	tap := New(Config{Sample: 1}, func(rec record.Record) { recs = append(recs, rec) })
+
+	id := enode.HexID("a448f24c6d18e575453db13171562b71999873db5b286df957af199ec94617f7")
+	tap.Packet(testPacket(id))
+	tap.Close()
+	want := &record.DiscPacketRecordInfo{
+		PeerId:   id.String(),
+		PeerAddr: "1.2.3.4:30303",
+		Protocol: "discv4",
+		Packet:   "PING/v4",
+		Seq:      5,
+		Size:     120,
+		Error:    "expired",
+		Duration: 7,
+		Time:     100,
+	}
+	if len(recs) != 1 || !reflect.DeepEqual(recs[0], want) {
+		t.Fatalf("wrong records %+v", recs)
+	}
+}
+
+func TestTapSample(t *testing.T) {
+	var (
+		count int
+		tap   = New(Config{Sample: 0.25, RateLimit: 1e6}, func(record.Record) { count++ })
+	)
+	for i := 0; i < 4; i++ {
+		// The first ID byte decides whether a sender is part of the sample.
+		id := enode.ID{byte(i * 64), 1}
+		for j := 0; j < 10; j++ {
+			tap.Packet(testPacket(id))
+		}
+	}
+	tap.Close()
+	if count != 10 {
+		t.Fatalf("wrong number of sampled packets %d, want 10", count)
+	}
+
+	disabled := New(Config{}, func(record.Record) { t.Fatal("disabled tap recorded a packet") })
+	disabled.Packet(testPacket(enode.ID{}))
+	disabled.Close()
+}
+
+func TestTapRateLimit(t *testing.T) {
+	var (
+		count int
+		tap   = New(Config{Sample: 1, RateLimit: 10}, func(record.Record) { count++ })
+	)
+	for i := 0; i < 100; i++ {
+		tap.Packet(testPacket(enode.ID{1}))
+	}
+	tap.Close()
+	if count < 10 || count > 11 {
+		t.Fatalf("wrong number of packets passing the limit %d, want 10", count)
+	}
+}
+
+func TestTapQueueFull(t *testing.T) {
+	var (
+		release = make(chan struct{})
+		count   int
+		tap     = New(Config{Sample: 1, RateLimit: 1e6}, func(record.Record) {
+			<-release
+			count++
+		})
+	)
+	// Packets must be dropped rather than wait for a stuck sink.
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*queueSize; i++ {
+			tap.Packet(testPacket(enode.ID{1}))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Packet blocked on a full queue")
+	}
+	close(release)
+	tap.Close()
+	if count < queueSize || count > queueSize+1 {
+		t.Fatalf("wrong number of published packets %d, want %d", count, queueSize)
+	}
+	tap.Close()
+}
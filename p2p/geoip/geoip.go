@@ -0,0 +1,181 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package geoip resolves peer addresses to their location and autonomous
+// system using local MaxMind GeoLite2 City and ASN databases.
+package geoip
+
+import (
+	"net"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// cacheSize is the number of resolved addresses kept in memory.
+const cacheSize = 16384
+
+// Info is the location and network of an IP address. Fields the databases
+// don't know about are left empty.
+type Info struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2 country code
+	City    string `json:"city,omitempty"`    // English city name
+	ASN     uint64 `json:"asn,omitempty"`     // autonomous system number
+	Org     string `json:"org,omitempty"`     // autonomous system organisation
+}
+
+// Resolver looks up addresses in a city and an ASN database, either of which
+// may be missing. It is safe for concurrent use.
+type Resolver struct {
+	city  *geoip2.Reader
+	asn   *geoip2.Reader
+	cache *lru.Cache // IP -> *Info, nil if nothing is known
+}
+
+// NewResolver opens the databases at the given paths. Empty paths are
+// skipped.
+func NewResolver(cityPath, asnPath string) (*Resolver, error) {
+	r := new(Resolver)
+	if cityPath != "" {
+		db, err := geoip2.Open(cityPath)
+		if err != nil {
+			return nil, err
+		}
+		r.city = db
+	}
+	if asnPath != "" {
+		db, err := geoip2.Open(asnPath)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.asn = db
+	}
+	r.cache, _ = lru.New(cacheSize)
+	return r, nil
+}
+
+// Lookup resolves an address given as IP or host:port. It returns nil if the
+// address is not an IP or the databases know nothing about it.
+func (r *Resolver) Lookup(addr string) *Info {
+	ip := parseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	key := ip.String()
+	if cached, ok := r.cache.Get(key); ok {
+		return copyInfo(cached.(*Info))
+	}
+	var (
+		info  Info
+		found bool
+	)
+	if r.city != nil {
+		if rec, err := r.city.City(ip); err == nil {
+			info.Country = rec.Country.IsoCode
+			info.City = rec.City.Names["en"]
+			found = found || info.Country != "" || info.City != ""
+		}
+	}
+	if r.asn != nil {
+		if rec, err := r.asn.ASN(ip); err == nil {
+			info.ASN = uint64(rec.AutonomousSystemNumber)
+			info.Org = rec.AutonomousSystemOrganization
+			found = found || info.ASN != 0 || info.Org != ""
+		}
+	}
+	if !found {
+		r.cache.Add(key, (*Info)(nil))
+		return nil
+	}
+	r.cache.Add(key, &info)
+	return copyInfo(&info)
+}
+
+// Close releases the databases.
+func (r *Resolver) Close() error {
+	var err error
+	for _, db := range []*geoip2.Reader{r.city, r.asn} {
+		if db == nil {
+			continue
+		}
+		if cerr := db.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// parseIP extracts the IP of an address given as IP or host:port.
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
+func copyInfo(info *Info) *Info {
+	if info == nil {
+		return nil
+	}
+	cpy := *info
+	return &cpy
+}
+
+var (
+	activeLock sync.RWMutex
+	active     *Resolver
+)
+
+// Setup opens the given databases and installs them as the process wide
+// resolver used by Lookup, closing the previous one. If both paths are
+// empty, lookups are disabled.
+func Setup(cityPath, asnPath string) error {
+	var r *Resolver
+	if cityPath != "" || asnPath != "" {
+		var err error
+		if r, err = NewResolver(cityPath, asnPath); err != nil {
+			return err
+		}
+	}
+	activeLock.Lock()
+	prev := active
+	active = r
+	activeLock.Unlock()
+
+	if prev != nil {
+		return prev.Close()
+	}
+	return nil
+}
+
+// Lookup resolves an address with the process wide resolver, returning nil
+// if none is installed or nothing is known about the address.
+func Lookup(addr string) *Info {
+	activeLock.RLock()
+	defer activeLock.RUnlock()
+
+	if active == nil {
+		return nil
+	}
+	return active.Lookup(addr)
+}
+
+// Close closes the process wide resolver and disables lookups.
+func Close() error {
+	return Setup("", "")
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// mmdbMap is a map of the MaxMind DB data section. Keys are strings, values
+// are strings, uint16, uint32, []interface{} or mmdbMap.
+type mmdbMap [][2]interface{}
+
+func encodeMMDB(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		if len(v) >= 29 {
+			return append([]byte{2<<5 | 29, byte(len(v) - 29)}, v...)
+		}
+		return append([]byte{2<<5 | byte(len(v))}, v...)
+	case uint16:
+		return encodeMMDBUint(5, uint64(v))
+	case uint32:
+		return encodeMMDBUint(6, uint64(v))
+	case []interface{}:
+		out := []byte{byte(len(v)), 11 - 7}
+		for _, elem := range v {
+			out = append(out, encodeMMDB(elem)...)
+		}
+		return out
+	case mmdbMap:
+		out := []byte{7<<5 | byte(len(v))}
+		for _, kv := range v {
+			out = append(out, encodeMMDB(kv[0])...)
+			out = append(out, encodeMMDB(kv[1])...)
+		}
+		return out
+	}
+	panic("unsupported mmdb value")
+}
+
+func encodeMMDBUint(typ byte, v uint64) []byte {
+	var be []byte
+	for ; v > 0; v >>= 8 {
+		be = append([]byte{byte(v)}, be...)
+	}
+	return append([]byte{typ<<5 | byte(len(be))}, be...)
+}
+
+// writeTestDB writes an IPv4 database mapping every address of the given
+// first octet to data.
+func writeTestDB(t *testing.T, dbType string, octet byte, data mmdbMap) string {
+	const nodes = 8
+	var blob []byte
+	for i := 0; i < nodes; i++ {
+		next := uint32(i + 1)
+		if i == nodes-1 {
+			next = nodes + 16 // pointer to the first data section entry
+		}
+		left, right := uint32(nodes), uint32(nodes)
+		if octet>>(7-i)&1 == 0 {
+			left = next
+		} else {
+			right = next
+		}
+		blob = append(blob, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+	}
+	blob = append(blob, make([]byte, 16)...)
+	blob = append(blob, encodeMMDB(data)...)
+	blob = append(blob, "\xab\xcd\xefMaxMind.com"...)
+	blob = append(blob, encodeMMDB(mmdbMap{
+		{"binary_format_major_version", uint16(2)},
+		{"binary_format_minor_version", uint16(0)},
+		{"database_type", dbType},
+		{"ip_version", uint16(4)},
+		{"languages", []interface{}{"en"}},
+		{"node_count", uint32(nodes)},
+		{"record_size", uint16(24)},
+	})...)
+
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+	if err := os.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolver(t *testing.T) {
+	city := writeTestDB(t, "GeoLite2-City", 1, mmdbMap{
+		{"country", mmdbMap{{"iso_code", "DE"}}},
+		{"city", mmdbMap{{"names", mmdbMap{{"en", "Berlin"}}}}},
+	})
+	asn := writeTestDB(t, "GeoLite2-ASN", 1, mmdbMap{
+		{"autonomous_system_number", uint32(3320)},
+		{"autonomous_system_organization", "Deutsche Telekom AG"},
+	})
+	r, err := NewResolver(city, asn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	want := &Info{Country: "DE", City: "Berlin", ASN: 3320, Org: "Deutsche Telekom AG"}
+	for _, addr := range []string{"1.2.3.4:30303", "1.2.3.4", "1.200.0.1:0"} {
+		if have := r.Lookup(addr); !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: have %+v, want %+v", addr, have, want)
+		}
+	}
+	// Cached results must not be shared with callers
+	r.Lookup("1.2.3.4").Country = "mutated"
+	if have := r.Lookup("1.2.3.4"); !reflect.DeepEqual(have, want) {
+		t.Errorf("cached result modified: %+v", have)
+	}
+	for _, addr := range []string{"2.2.3.4:30303", "[::1]:30303", "localhost:30303", ""} {
+		if have := r.Lookup(addr); have != nil {
+			t.Errorf("%s: have %+v, want nil", addr, have)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	asn := writeTestDB(t, "GeoLite2-ASN", 10, mmdbMap{
+		{"autonomous_system_number", uint32(64512)},
+		{"autonomous_system_organization", "Private"},
+	})
+	if Lookup("10.0.0.1") != nil {
+		t.Fatal("lookup without installed resolver")
+	}
+	if err := Setup("", asn); err != nil {
+		t.Fatal(err)
+	}
+	if have := Lookup("10.0.0.1:30303"); have == nil || have.ASN != 64512 || have.Country != "" {
+		t.Fatalf("wrong lookup result %+v", have)
+	}
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	if Lookup("10.0.0.1") != nil {
+		t.Fatal("lookup after close")
+	}
+	if err := Setup(filepath.Join(t.TempDir(), "missing.mmdb"), ""); err == nil {
+		t.Fatal("no error for missing database")
+	}
+}
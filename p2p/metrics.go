@@ -20,6 +20,7 @@ package p2p
 
 import (
 	"net"
+	"sync/atomic"
 
 	"peerInfoCollect/metrics"
 )
@@ -46,24 +47,25 @@ var (
 // meteredConn is a wrapper around a net.Conn that meters both the
 // inbound and outbound network traffic.
 type meteredConn struct {
+	ingress uint64 // Bytes read from the connection (atomic)
+	egress  uint64 // Bytes written to the connection (atomic)
+
 	net.Conn
 }
 
-// newMeteredConn creates a new metered connection, bumps the ingress or egress
-// connection meter and also increases the metered peer count. If the metrics
-// system is disabled, function returns the original connection.
+// newMeteredConn creates a new metered connection counting the traffic of the
+// connection. If the metrics system is enabled, it also bumps the ingress or
+// egress connection meter and increases the metered peer count.
 func newMeteredConn(conn net.Conn, ingress bool, addr *net.TCPAddr) net.Conn {
-	// Short circuit if metrics are disabled
-	if !metrics.Enabled {
-		return conn
-	}
-	// Bump the connection counters and wrap the connection
-	if ingress {
-		ingressConnectMeter.Mark(1)
-	} else {
-		egressConnectMeter.Mark(1)
+	// Bump the connection counters if metrics are enabled
+	if metrics.Enabled {
+		if ingress {
+			ingressConnectMeter.Mark(1)
+		} else {
+			egressConnectMeter.Mark(1)
+		}
+		activePeerGauge.Inc(1)
 	}
-	activePeerGauge.Inc(1)
 	return &meteredConn{Conn: conn}
 }
 
@@ -71,6 +73,7 @@ func newMeteredConn(conn net.Conn, ingress bool, addr *net.TCPAddr) net.Conn {
 // and the peer ingress traffic meters along the way.
 func (c *meteredConn) Read(b []byte) (n int, err error) {
 	n, err = c.Conn.Read(b)
+	atomic.AddUint64(&c.ingress, uint64(n))
 	ingressTrafficMeter.Mark(int64(n))
 	return n, err
 }
@@ -79,6 +82,7 @@ func (c *meteredConn) Read(b []byte) (n int, err error) {
 // and the peer egress traffic meters along the way.
 func (c *meteredConn) Write(b []byte) (n int, err error) {
 	n, err = c.Conn.Write(b)
+	atomic.AddUint64(&c.egress, uint64(n))
 	egressTrafficMeter.Mark(int64(n))
 	return n, err
 }
@@ -87,8 +91,13 @@ func (c *meteredConn) Write(b []byte) (n int, err error) {
 // the peer from the traffic registries and emits close event.
 func (c *meteredConn) Close() error {
 	err := c.Conn.Close()
-	if err == nil {
+	if err == nil && metrics.Enabled {
 		activePeerGauge.Dec(1)
 	}
 	return err
 }
+
+// Traffic returns the number of bytes read from and written to the connection.
+func (c *meteredConn) Traffic() (ingress uint64, egress uint64) {
+	return atomic.LoadUint64(&c.ingress), atomic.LoadUint64(&c.egress)
+}
@@ -114,6 +114,9 @@ type Peer struct {
 	closed   chan struct{}
 	disc     chan DiscReason
 
+	discReason DiscReason // Reason the connection was closed for, set before closed
+	discRemote bool       // Whether the remote side requested the disconnect
+
 	// events receives message send / receive events if set
 	events   *event.Feed
 	testPipe *MsgPipeRW // for testing
@@ -213,6 +216,26 @@ func (p *Peer) Disconnect(reason DiscReason) {
 	}
 }
 
+// Closed returns a channel which is closed once the connection was torn down.
+func (p *Peer) Closed() <-chan struct{} {
+	return p.closed
+}
+
+// DiscReason returns the reason the connection was closed for and whether the
+// remote side requested the disconnect. It is only meaningful once the channel
+// returned by Closed was closed.
+func (p *Peer) DiscReason() (reason DiscReason, remote bool) {
+	return p.discReason, p.discRemote
+}
+
+// Traffic returns the number of bytes received from and sent to the peer.
+func (p *Peer) Traffic() (ingress uint64, egress uint64) {
+	if c, ok := p.rw.fd.(*meteredConn); ok {
+		return c.Traffic()
+	}
+	return 0, 0
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	id := p.ID()
@@ -286,6 +309,7 @@ loop:
 		}
 	}
 
+	p.discReason, p.discRemote = reason, remoteRequested
 	close(p.closed)
 	p.rw.close(reason)
 	p.wg.Wait()
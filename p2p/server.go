@@ -93,6 +93,9 @@ type Config struct {
 	// protocol should be started or not.
 	DiscoveryV5 bool `toml:",omitempty"`
 
+	// DiscoveryTap, if set, is notified of the inbound discovery packets.
+	DiscoveryTap discover.PacketTap `toml:"-"`
+
 	// Name sets the node name of this server.
 	// Use common.MakeName to create a name that follows existing conventions.
 	Name string `toml:"-"`
@@ -583,6 +586,7 @@ func (srv *Server) setupDiscovery() error {
 			NetRestrict: srv.NetRestrict,
 			Bootnodes:   srv.BootstrapNodes,
 			Unhandled:   unhandled,
+			Tap:         srv.DiscoveryTap,
 			Log:         srv.log,
 		}
 		ntab, err := discover.ListenV4(conn, srv.localnode, cfg)
@@ -599,6 +603,7 @@ func (srv *Server) setupDiscovery() error {
 			PrivateKey:  srv.PrivateKey,
 			NetRestrict: srv.NetRestrict,
 			Bootnodes:   srv.BootstrapNodesV5,
+			Tap:         srv.DiscoveryTap,
 			Log:         srv.log,
 		}
 		var err error
@@ -0,0 +1,37 @@
+package peerdb
+
+import "errors"
+
+var errUnknownPeer = errors.New("unknown peer")
+
+// API exposes the peer registry over RPC.
+type API struct {
+	registry *Registry
+}
+
+// NewAPI creates the RPC service of a registry.
+func NewAPI(registry *Registry) *API {
+	return &API{registry}
+}
+
+// Peers returns every peer in the registry, most recently seen first. If
+// limit is positive, at most that many peers are returned.
+func (api *API) Peers(limit *int) []*Entry {
+	if limit == nil {
+		return api.registry.Entries()
+	}
+	return api.registry.scan(nil, *limit)
+}
+
+// Peer returns the registry entry of a single peer.
+func (api *API) Peer(id string) (*Entry, error) {
+	if e := api.registry.Get(id); e != nil {
+		return e, nil
+	}
+	return nil, errUnknownPeer
+}
+
+// Count returns the number of peers in the registry.
+func (api *API) Count() int {
+	return api.registry.Len()
+}
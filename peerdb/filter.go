@@ -0,0 +1,108 @@
+package peerdb
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// AddrFilter matches network addresses by IP, either against a CIDR or
+// against a plain string prefix of the IP. Ports and IPv6 brackets are
+// ignored.
+type AddrFilter struct {
+	prefix string
+	subnet *net.IPNet
+}
+
+// ParseAddrFilter creates an address filter. Filters containing a slash are
+// parsed as CIDR.
+func ParseAddrFilter(filter string) (*AddrFilter, error) {
+	if !strings.Contains(filter, "/") {
+		return &AddrFilter{prefix: filter}, nil
+	}
+	_, subnet, err := net.ParseCIDR(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address filter %q: %v", filter, err)
+	}
+	return &AddrFilter{subnet: subnet}, nil
+}
+
+// Match reports whether the IP of a host:port or bare host address passes the
+// filter.
+func (f *AddrFilter) Match(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = strings.Trim(addr, "[]")
+	}
+	if f.subnet != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && f.subnet.Contains(ip)
+	}
+	return strings.HasPrefix(host, f.prefix)
+}
+
+// Filter selects entries of the registry. Zero fields match every peer.
+type Filter struct {
+	ID        string `json:"id"`        // prefix of the node ID
+	Addr      string `json:"addr"`      // IP prefix of any address the peer was seen at, either a CIDR or a plain string prefix
+	Client    string `json:"client"`    // parsed client name, case-insensitive
+	NetworkID uint64 `json:"networkId"` // network ID of the last status
+	Since     uint64 `json:"since"`     // unix seconds the peer was last seen at or after
+	Limit     int    `json:"limit"`     // maximum number of peers returned
+
+	addr *AddrFilter
+}
+
+// init validates the filter and parses its address filter.
+func (f *Filter) init() error {
+	if f.Addr == "" || f.addr != nil {
+		return nil
+	}
+	addr, err := ParseAddrFilter(f.Addr)
+	if err != nil {
+		return err
+	}
+	f.addr = addr
+	return nil
+}
+
+// Match reports whether an entry passes the filter, ignoring the limit. An
+// invalid address filter matches no entry.
+func (f *Filter) Match(e *Entry) bool {
+	if f.ID != "" && !strings.HasPrefix(e.ID, f.ID) {
+		return false
+	}
+	if f.Client != "" && !strings.EqualFold(e.Client.Client, f.Client) {
+		return false
+	}
+	if f.NetworkID != 0 && e.Status.NetworkID != f.NetworkID {
+		return false
+	}
+	if e.LastSeen < f.Since {
+		return false
+	}
+	if f.Addr != "" {
+		if err := f.init(); err != nil {
+			return false
+		}
+		for _, a := range e.Addresses {
+			if f.addr.Match(a.Addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Query returns the peers passing the filter, most recently seen first. A nil
+// filter returns every peer.
+func (r *Registry) Query(f *Filter) ([]*Entry, error) {
+	if f == nil {
+		return r.Entries(), nil
+	}
+	if err := f.init(); err != nil {
+		return nil, err
+	}
+	return r.scan(f.Match, f.Limit), nil
+}
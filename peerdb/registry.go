@@ -0,0 +1,352 @@
+// Package peerdb is a persistent registry of every peer the collector has
+// talked to, keyed by node ID.
+package peerdb
+
+import (
+	"math/big"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"peerInfoCollect/common"
+	"peerInfoCollect/common/hexutil"
+	"peerInfoCollect/ethdb"
+	"peerInfoCollect/ethdb/memorydb"
+	"peerInfoCollect/log"
+	"peerInfoCollect/p2p/clientinfo"
+	"peerInfoCollect/p2p/geoip"
+	"peerInfoCollect/rlp"
+)
+
+// entryPrefix is the database key prefix of registry entries.
+var entryPrefix = []byte("peer-")
+
+const (
+	// cacheSize is the number of decoded entries kept in memory.
+	cacheSize = 4096
+
+	// maxAddresses is the number of addresses kept per peer. The least
+	// recently seen ones are dropped first.
+	maxAddresses = 16
+)
+
+// Address is a network address a peer was seen at.
+type Address struct {
+	Addr      string      `json:"addr"`
+	FirstSeen uint64      `json:"firstSeen"` // unix seconds
+	LastSeen  uint64      `json:"lastSeen"`  // unix seconds
+	Geo       *geoip.Info `json:"geo,omitempty" rlp:"optional"`
+}
+
+// Status contains the fields of the last eth status handshake of a peer.
+type Status struct {
+	ProtocolVersion uint32        `json:"protocolVersion"`
+	NetworkID       uint64        `json:"networkId"`
+	TD              *big.Int      `json:"td"`
+	Head            common.Hash   `json:"head"`
+	Genesis         common.Hash   `json:"genesis"`
+	ForkHash        hexutil.Bytes `json:"forkHash"`
+	ForkNext        uint64        `json:"forkNext"`
+	Time            uint64        `json:"time"` // unix seconds of the handshake, zero if never
+}
+
+// Entry is everything known about a single peer.
+type Entry struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"` // client name of the last session
+	Caps      []string  `json:"caps"` // RLPx capabilities of the last session
+	Addresses []Address `json:"addresses"`
+	Status    Status    `json:"status"`
+	FirstSeen uint64    `json:"firstSeen"` // unix seconds
+	LastSeen  uint64    `json:"lastSeen"`  // unix seconds
+	Sessions  uint64    `json:"sessions"`  // number of completed handshakes
+
+	Client clientinfo.Info `json:"clientInfo" rlp:"optional"` // parsed client name of the last session
+}
+
+// Addr returns the address the peer was last seen at.
+func (e *Entry) Addr() string {
+	var (
+		addr string
+		last uint64
+	)
+	for _, a := range e.Addresses {
+		if addr == "" || a.LastSeen >= last {
+			addr, last = a.Addr, a.LastSeen
+		}
+	}
+	return addr
+}
+
+// seen records that the peer was seen at the given address, returning
+// whether anything changed. Addresses are kept per IP, the port of inbound
+// connections is ephemeral and only the most recent one is stored.
+func (e *Entry) seen(addr string, now uint64) bool {
+	changed := e.LastSeen != now
+	if e.FirstSeen == 0 {
+		e.FirstSeen = now
+	}
+	e.LastSeen = now
+
+	if addr == "" {
+		return changed
+	}
+	host := addrHost(addr)
+	for i := range e.Addresses {
+		a := &e.Addresses[i]
+		if addrHost(a.Addr) == host {
+			changed = changed || a.LastSeen != now || a.Addr != addr
+			a.Addr, a.LastSeen = addr, now
+			if a.Geo == nil {
+				// The location databases may have been added since
+				if a.Geo = geoip.Lookup(addr); a.Geo != nil {
+					changed = true
+				}
+			}
+			return e.trimAddresses() || changed
+		}
+	}
+	e.Addresses = append(e.Addresses, Address{Addr: addr, FirstSeen: now, LastSeen: now, Geo: geoip.Lookup(addr)})
+	e.trimAddresses()
+	return true
+}
+
+// trimAddresses drops the least recently seen addresses beyond maxAddresses,
+// returning whether any were dropped.
+func (e *Entry) trimAddresses() bool {
+	if len(e.Addresses) <= maxAddresses {
+		return false
+	}
+	sort.SliceStable(e.Addresses, func(i, j int) bool {
+		return e.Addresses[i].LastSeen > e.Addresses[j].LastSeen
+	})
+	e.Addresses = e.Addresses[:maxAddresses]
+	return true
+}
+
+// addrHost returns the IP part of a host:port address.
+func addrHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func (e *Entry) copy() *Entry {
+	cpy := *e
+	cpy.Caps = append([]string(nil), e.Caps...)
+	cpy.Addresses = append([]Address(nil), e.Addresses...)
+	for i := range cpy.Addresses {
+		if geo := cpy.Addresses[i].Geo; geo != nil {
+			g := *geo
+			cpy.Addresses[i].Geo = &g
+		}
+	}
+	cpy.Status.ForkHash = common.CopyBytes(e.Status.ForkHash)
+	if e.Status.TD != nil {
+		cpy.Status.TD = new(big.Int).Set(e.Status.TD)
+	}
+	return &cpy
+}
+
+// Registry stores peer entries in a key-value database. All methods are safe
+// for concurrent use.
+type Registry struct {
+	lock  sync.Mutex
+	db    ethdb.KeyValueStore
+	count int        // number of entries in the database
+	cache *lru.Cache // node ID -> *Entry
+	now   func() time.Time
+}
+
+// New creates a registry backed by the given database. If db is nil, entries
+// are kept in memory.
+func New(db ethdb.KeyValueStore) *Registry {
+	if db == nil {
+		db = memorydb.New()
+	}
+	cache, _ := lru.New(cacheSize)
+	return &Registry{db: db, count: countEntries(db), cache: cache, now: time.Now}
+}
+
+// SetDatabase switches the registry to another database. Entries are not
+// carried over. If db is nil, the registry falls back to memory.
+func (r *Registry) SetDatabase(db ethdb.KeyValueStore) {
+	if db == nil {
+		db = memorydb.New()
+	}
+	count := countEntries(db)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.db, r.count = db, count
+	r.cache.Purge()
+}
+
+// Seen records that a peer was active at the given address.
+func (r *Registry) Seen(id string, addr string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e := r.load(id)
+	if e.seen(addr, r.timestamp()) {
+		r.store(e)
+	}
+}
+
+// Connected records a completed handshake of a peer. The status may be nil
+// if the peer didn't send one.
+func (r *Registry) Connected(id string, addr string, name string, caps []string, status *Status) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.timestamp()
+	e := r.load(id)
+	e.seen(addr, now)
+	e.Name = name
+	e.Client = clientinfo.Parse(name)
+	e.Caps = append([]string(nil), caps...)
+	e.Sessions++
+	if status != nil {
+		e.Status = *status
+		e.Status.Time = now
+	}
+	r.store(e)
+}
+
+// Addr returns the address a peer was last seen at.
+func (r *Registry) Addr(id string) (string, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e := r.load(id)
+	if e.FirstSeen == 0 {
+		return "", false
+	}
+	addr := e.Addr()
+	return addr, addr != ""
+}
+
+// Get returns the entry of a peer, or nil if it was never seen.
+func (r *Registry) Get(id string) *Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e := r.load(id)
+	if e.FirstSeen == 0 {
+		return nil
+	}
+	return e.copy()
+}
+
+// Entries returns all peers in the registry, most recently seen first.
+func (r *Registry) Entries() []*Entry {
+	return r.scan(nil, 0)
+}
+
+// Len returns the number of peers in the registry.
+func (r *Registry) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.count
+}
+
+// scan returns the entries passing match, most recently seen first. If limit
+// is positive, only the limit most recently seen ones are kept. The database
+// is iterated without holding the lock, iterators see a consistent snapshot.
+func (r *Registry) scan(match func(*Entry) bool, limit int) []*Entry {
+	r.lock.Lock()
+	db := r.db
+	r.lock.Unlock()
+
+	var entries []*Entry
+	trim := func() {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].LastSeen > entries[j].LastSeen
+		})
+		if limit > 0 && len(entries) > limit {
+			entries = entries[:limit]
+		}
+	}
+	it := db.NewIterator(entryPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		e := new(Entry)
+		if err := rlp.DecodeBytes(it.Value(), e); err != nil {
+			log.Warn("Skipping invalid peer registry entry", "key", string(it.Key()), "err", err)
+			continue
+		}
+		if match != nil && !match(e) {
+			continue
+		}
+		entries = append(entries, e)
+		if limit > 0 && len(entries) >= 2*limit {
+			trim()
+		}
+	}
+	trim()
+	return entries
+}
+
+// countEntries returns the number of entries in a database.
+func countEntries(db ethdb.KeyValueStore) int {
+	it := db.NewIterator(entryPrefix, nil)
+	defer it.Release()
+
+	n := 0
+	for it.Next() {
+		n++
+	}
+	return n
+}
+
+func (r *Registry) timestamp() uint64 {
+	return uint64(r.now().Unix())
+}
+
+// load retrieves an entry from the cache or the database, returning a fresh
+// entry for unknown peers. The lock must be held.
+func (r *Registry) load(id string) *Entry {
+	if e, ok := r.cache.Get(id); ok {
+		return e.(*Entry)
+	}
+	e := &Entry{ID: id}
+	if blob, err := r.db.Get(entryKey(id)); err == nil {
+		if err := rlp.DecodeBytes(blob, e); err != nil {
+			log.Warn("Discarding invalid peer registry entry", "id", id, "err", err)
+			e = &Entry{ID: id}
+		}
+	}
+	return e
+}
+
+// store writes an entry to the database and the cache. The lock must be held.
+func (r *Registry) store(e *Entry) {
+	blob, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		log.Error("Failed to encode peer registry entry", "id", e.ID, "err", err)
+		return
+	}
+	key := entryKey(e.ID)
+	known := r.cache.Contains(e.ID)
+	if !known {
+		known, _ = r.db.Has(key)
+	}
+	if err := r.db.Put(key, blob); err != nil {
+		log.Error("Failed to store peer registry entry", "id", e.ID, "err", err)
+		return
+	}
+	if !known {
+		r.count++
+	}
+	r.cache.Add(e.ID, e)
+}
+
+func entryKey(id string) []byte {
+	return append(common.CopyBytes(entryPrefix), id...)
+}
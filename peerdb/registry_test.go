@@ -0,0 +1,221 @@
+package peerdb
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"peerInfoCollect/common"
+	"peerInfoCollect/ethdb"
+	"peerInfoCollect/ethdb/memorydb"
+	"peerInfoCollect/p2p/clientinfo"
+)
+
+func newTestRegistry(db ethdb.KeyValueStore, clock *time.Time) *Registry {
+	r := New(db)
+	r.now = func() time.Time { return *clock }
+	return r
+}
+
+func TestRegistryPersistence(t *testing.T) {
+	var (
+		db    = memorydb.New()
+		clock = time.Unix(1000, 0)
+		r     = newTestRegistry(db, &clock)
+	)
+	if _, ok := r.Addr("aa"); ok {
+		t.Fatal("unknown peer has an address")
+	}
+	status := &Status{
+		ProtocolVersion: 66,
+		NetworkID:       1,
+		TD:              big.NewInt(1000),
+		Head:            common.HexToHash("0x01"),
+		Genesis:         common.HexToHash("0x02"),
+		ForkHash:        []byte{0x20, 0xc3, 0x27, 0xfc},
+	}
+	r.Connected("aa", "1.2.3.4:30303", "Geth/v1.10.17", []string{"eth/66"}, status)
+	clock = clock.Add(time.Minute)
+	r.Seen("aa", "5.6.7.8:30303")
+	clock = clock.Add(time.Minute)
+	r.Connected("aa", "5.6.7.8:30303", "Geth/v1.10.18", []string{"eth/66", "snap/1"}, nil)
+	r.Seen("bb", "9.9.9.9:30303")
+
+	// A fresh registry on the same database must see the same entries.
+	r = newTestRegistry(db, &clock)
+	if n := r.Len(); n != 2 {
+		t.Fatalf("have %d peers, want 2", n)
+	}
+	e := r.Get("aa")
+	if e == nil {
+		t.Fatal("peer not persisted")
+	}
+	want := &Entry{
+		ID:   "aa",
+		Name: "Geth/v1.10.18",
+		Caps: []string{"eth/66", "snap/1"},
+		Addresses: []Address{
+			{Addr: "1.2.3.4:30303", FirstSeen: 1000, LastSeen: 1000},
+			{Addr: "5.6.7.8:30303", FirstSeen: 1060, LastSeen: 1120},
+		},
+		FirstSeen: 1000,
+		LastSeen:  1120,
+		Sessions:  2,
+		Client:    clientinfo.Info{Client: "geth", Version: "1.10.18", Raw: "Geth/v1.10.18"},
+	}
+	want.Status = *status
+	want.Status.Time = 1000
+	if !reflect.DeepEqual(e, want) {
+		t.Fatalf("wrong entry:\nhave %+v\nwant %+v", e, want)
+	}
+	if addr, ok := r.Addr("aa"); !ok || addr != "5.6.7.8:30303" {
+		t.Fatalf("wrong address %q", addr)
+	}
+	// Entries are sorted by last sighting, ties keep the database order.
+	entries := r.Entries()
+	if len(entries) != 2 || entries[0].ID != "aa" || entries[1].ID != "bb" {
+		t.Fatalf("wrong entries: %+v", entries)
+	}
+	// Handed out entries must not alias the registry state.
+	e.Addresses[0].Addr = "mutated"
+	if r.Get("aa").Addresses[0].Addr != "1.2.3.4:30303" {
+		t.Fatal("registry entry modified through a copy")
+	}
+}
+
+func TestRegistrySetDatabase(t *testing.T) {
+	clock := time.Unix(1000, 0)
+	r := newTestRegistry(nil, &clock)
+	r.Seen("aa", "1.2.3.4:30303")
+
+	db := memorydb.New()
+	r.SetDatabase(db)
+	if r.Get("aa") != nil {
+		t.Fatal("cached entry survived database switch")
+	}
+	r.Seen("bb", "5.6.7.8:30303")
+	r.SetDatabase(nil)
+	if r.Len() != 0 {
+		t.Fatal("entries visible after detaching the database")
+	}
+	r.SetDatabase(db)
+	if r.Get("bb") == nil {
+		t.Fatal("entry not stored in the attached database")
+	}
+	if n := r.Len(); n != 1 {
+		t.Fatalf("have %d peers after reattaching, want 1", n)
+	}
+}
+
+func TestRegistryLimit(t *testing.T) {
+	clock := time.Unix(1000, 0)
+	r := newTestRegistry(nil, &clock)
+	for i := 0; i < 10; i++ {
+		clock = clock.Add(time.Minute)
+		r.Seen(fmt.Sprintf("aa%02d", i), "1.2.3.4:30303")
+	}
+	// Sightings of known peers, cached or not, must not be counted again.
+	r.cache.Purge()
+	r.Seen("aa00", "1.2.3.4:30303")
+	r.Seen("aa05", "1.2.3.4:30303")
+	if n := r.Len(); n != 10 {
+		t.Fatalf("have %d peers, want 10", n)
+	}
+	entries, err := r.Query(&Filter{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, e := range entries {
+		have = append(have, e.ID)
+	}
+	if want := []string{"aa00", "aa05", "aa09"}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+}
+
+func TestRegistryAddresses(t *testing.T) {
+	clock := time.Unix(1000, 0)
+	r := newTestRegistry(nil, &clock)
+
+	// Inbound connections from one IP use a new port every time.
+	r.Seen("aa", "1.2.3.4:50000")
+	clock = clock.Add(time.Minute)
+	r.Seen("aa", "1.2.3.4:50001")
+	want := []Address{{Addr: "1.2.3.4:50001", FirstSeen: 1000, LastSeen: 1060}}
+	if have := r.Get("aa").Addresses; !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong addresses:\nhave %+v\nwant %+v", have, want)
+	}
+
+	// The list is capped, dropping the least recently seen address.
+	for i := 0; i < maxAddresses; i++ {
+		clock = clock.Add(time.Minute)
+		r.Seen("aa", fmt.Sprintf("10.0.0.%d:30303", i))
+	}
+	addrs := r.Get("aa").Addresses
+	if len(addrs) != maxAddresses {
+		t.Fatalf("have %d addresses, want %d", len(addrs), maxAddresses)
+	}
+	for _, a := range addrs {
+		if a.Addr == "1.2.3.4:50001" {
+			t.Fatal("oldest address not dropped")
+		}
+	}
+}
+
+func TestRegistryQuery(t *testing.T) {
+	clock := time.Unix(1000, 0)
+	r := newTestRegistry(nil, &clock)
+	r.Connected("cc01", "[2001:db8::1]:30303", "Besu/v22.1.3/linux-x86_64/openjdk-java-11", nil, &Status{NetworkID: 1})
+	clock = clock.Add(time.Minute)
+	r.Connected("aa01", "1.2.3.4:30303", "Geth/v1.10.17-stable/linux-amd64/go1.18", nil, &Status{NetworkID: 1})
+	clock = clock.Add(time.Minute)
+	r.Connected("aa02", "1.2.9.9:30303", "Nethermind/v1.13.0/X64-Linux/6.0.4", nil, &Status{NetworkID: 5})
+	clock = clock.Add(time.Minute)
+	r.Connected("bb01", "5.6.7.8:30303", "geth/v1.10.18-stable/linux-amd64/go1.18", nil, &Status{NetworkID: 1})
+
+	tests := []struct {
+		filter *Filter
+		want   []string
+	}{
+		{nil, []string{"bb01", "aa02", "aa01", "cc01"}},
+		{&Filter{ID: "aa"}, []string{"aa02", "aa01"}},
+		{&Filter{Client: "GETH"}, []string{"bb01", "aa01"}},
+		{&Filter{NetworkID: 5}, []string{"aa02"}},
+		{&Filter{Since: 1120}, []string{"bb01", "aa02"}},
+
+		// Addresses are matched by IP, without port or brackets.
+		{&Filter{Addr: "1.2."}, []string{"aa02", "aa01"}},
+		{&Filter{Addr: "1.2.3.4"}, []string{"aa01"}},
+		{&Filter{Addr: "2001:"}, []string{"cc01"}},
+		{&Filter{Addr: "[2001:"}, nil},
+		{&Filter{Addr: "30303"}, nil},
+		{&Filter{Addr: "1.2.0.0/16"}, []string{"aa02", "aa01"}},
+		{&Filter{Addr: "5.6.7.8/32"}, []string{"bb01"}},
+		{&Filter{Addr: "2001:db8::/32"}, []string{"cc01"}},
+		{&Filter{Addr: "0.0.0.0/0"}, []string{"bb01", "aa02", "aa01"}},
+		{&Filter{Client: "geth", Limit: 1}, []string{"bb01"}},
+		{&Filter{Client: "erigon"}, nil},
+	}
+	for i, tt := range tests {
+		entries, err := r.Query(tt.filter)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		var have []string
+		for _, e := range entries {
+			have = append(have, e.ID)
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: have %v, want %v", i, have, tt.want)
+		}
+	}
+	if _, err := r.Query(&Filter{Addr: "1.2.3.4/99"}); err == nil {
+		t.Error("invalid CIDR accepted")
+	}
+	if (&Filter{Addr: "1.2.3.4/99"}).Match(r.Get("aa01")) {
+		t.Error("invalid CIDR matched")
+	}
+}
@@ -0,0 +1,228 @@
+package record
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"peerInfoCollect/p2p/geoip"
+)
+
+// Config contains the configuration of the observation sinks.
+type Config struct {
+	// Disable turns off publishing altogether, all records are dropped.
+	Disable bool `toml:",omitempty"`
+
+	// Redis is the redis server observations are published to, either as a
+	// redis:// URL or as a plain host:port. Empty disables the redis sink.
+	Redis string `toml:",omitempty"`
+
+	// Mongo is the mongodb server observations are inserted into, either as
+	// a mongodb:// URL or as a plain host:port. Empty disables the mongo sink.
+	Mongo string `toml:",omitempty"`
+
+	// Sinks lists additional sink URLs, e.g. file:///var/lib/collector.jsonl.
+	Sinks []string `toml:",omitempty"`
+
+	// Channels renames the default channels (BlockInfo, TxInfo, ...), so
+	// that several deployments can share one backend.
+	Channels map[string]string `toml:",omitempty"`
+
+	// Encoding is the wire format of published observations: legacy for the
+	// bare JSON records, json or rlp for versioned envelopes.
+	Encoding string `toml:",omitempty"`
+
+	// Collector identifies this collector instance in envelopes, defaults
+	// to the host name.
+	Collector string `toml:",omitempty"`
+
+	// QueueSize is the number of records buffered between the publishers and
+	// the sinks. Zero publishes synchronously from the caller's goroutine.
+	QueueSize    int    `toml:",omitempty"`
+	QueueWorkers int    `toml:",omitempty"` // Goroutines draining the queue
+	QueueBatch   int    `toml:",omitempty"` // Maximum records handed to a sink at once
+	QueueDrop    string `toml:",omitempty"` // Drop policy when full: newest, oldest or block
+
+	// SpoolDir is where records are kept while a sink is unreachable, one
+	// subdirectory per sink. Relative paths are resolved against the data
+	// directory, empty disables spooling.
+	SpoolDir     string        `toml:",omitempty"`
+	SpoolMaxSize int           `toml:",omitempty"` // Maximum size of each spool in megabytes
+	SpoolMaxAge  time.Duration `toml:",omitempty"` // Age after which spooled records are dropped
+
+	// GeoCity and GeoASN are MaxMind GeoLite2 City and ASN databases used
+	// to attach the location of the peer to observations and registry
+	// entries. Relative paths are resolved against the data directory,
+	// empty disables the respective lookups.
+	GeoCity string `toml:",omitempty"`
+	GeoASN  string `toml:",omitempty"`
+}
+
+// DefaultConfig is the default sink configuration of the collector. No sink
+// is enabled, observations are only published once a backend is configured.
+var DefaultConfig = Config{
+	Encoding:     string(EncodingLegacy),
+	QueueSize:    10000,
+	QueueWorkers: 4,
+	QueueBatch:   128,
+	QueueDrop:    string(DropNewest),
+	SpoolDir:     "recordspool",
+	SpoolMaxSize: 1024,
+	SpoolMaxAge:  7 * 24 * time.Hour,
+}
+
+// URLs returns the URLs of all sinks enabled by the configuration.
+func (c *Config) URLs() []string {
+	if c.Disable {
+		return nil
+	}
+	var urls []string
+	if c.Redis != "" {
+		urls = append(urls, withScheme("redis", c.Redis))
+	}
+	if c.Mongo != "" {
+		urls = append(urls, withScheme("mongodb", c.Mongo))
+	}
+	return append(urls, c.Sinks...)
+}
+
+// withScheme turns a plain host:port endpoint into a URL of the given scheme.
+func withScheme(scheme, endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	return scheme + "://" + endpoint
+}
+
+// ParseChannels parses a comma separated list of default=renamed channel
+// pairs as accepted by the --record.channels flag.
+func ParseChannels(spec string) (map[string]string, error) {
+	channels := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid channel mapping %q, want default=name", pair)
+		}
+		channels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return channels, nil
+}
+
+// Open installs the sinks described by the configuration as the process
+// wide destination for Publish.
+func Open(cfg *Config) error {
+	encoding, err := ParseEncoding(cfg.Encoding)
+	if err != nil {
+		return err
+	}
+	if encoding == EncodingRLP {
+		// Documents are built from the JSON encoding of the records
+		for _, rawurl := range cfg.URLs() {
+			if u, err := url.Parse(rawurl); err == nil && u.Scheme == "mongodb" {
+				return fmt.Errorf("%w: mongodb sink needs json or legacy records, not %s", errUnsupportedEncoding, encoding)
+			}
+		}
+	}
+	var drop DropPolicy
+	if cfg.QueueSize > 0 && cfg.QueueDrop != "" {
+		if drop, err = ParseDropPolicy(cfg.QueueDrop); err != nil {
+			return err
+		}
+	}
+	var spool func(string, Sink) (Sink, error)
+	if cfg.SpoolDir != "" {
+		spool = func(rawurl string, s Sink) (Sink, error) {
+			return NewSpoolSink(s, SpoolConfig{
+				Dir:     filepath.Join(cfg.SpoolDir, spoolName(rawurl)),
+				MaxSize: int64(cfg.SpoolMaxSize) * 1024 * 1024,
+				MaxAge:  cfg.SpoolMaxAge,
+			})
+		}
+	}
+	if err := geoip.Setup(cfg.GeoCity, cfg.GeoASN); err != nil {
+		return err
+	}
+	sinks, err := openSinks(cfg.URLs(), spool)
+	if err != nil {
+		geoip.Close()
+		return err
+	}
+	sink := NewMultiSink(sinks...)
+	if len(cfg.Channels) > 0 {
+		sink = &channelSink{Sink: sink, channels: cfg.Channels}
+	}
+	if cfg.QueueSize > 0 {
+		sink = NewQueueSink(sink, QueueConfig{
+			Size:    cfg.QueueSize,
+			Workers: cfg.QueueWorkers,
+			Batch:   cfg.QueueBatch,
+			Drop:    drop,
+		})
+	}
+	collector := cfg.Collector
+	if collector == "" {
+		collector = defaultCollectorID()
+	}
+	setFeedCollector(collector)
+	if encoding != EncodingLegacy {
+		sink = &envelopeSink{Sink: sink, collector: collector, encoding: encoding}
+	}
+	SetSink(sink)
+	return nil
+}
+
+// spoolName derives the spool directory of a sink from its URL, leaving out
+// any credentials.
+func spoolName(rawurl string) string {
+	name := rawurl
+	if u, err := url.Parse(rawurl); err == nil {
+		name = u.Scheme + "-" + u.Host + u.Path
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// channelSink publishes records under renamed channels.
+type channelSink struct {
+	Sink
+	channels map[string]string
+}
+
+func (s *channelSink) Publish(rec Record) error {
+	if name, ok := s.channels[rec.Channel()]; ok {
+		rec = &renamedRecord{Record: rec, channel: name}
+	}
+	return s.Sink.Publish(rec)
+}
+
+func (s *channelSink) PublishBatch(recs []Record) error {
+	renamed := make([]Record, len(recs))
+	for i, rec := range recs {
+		if name, ok := s.channels[rec.Channel()]; ok {
+			rec = &renamedRecord{Record: rec, channel: name}
+		}
+		renamed[i] = rec
+	}
+	return publishBatch(s.Sink, renamed)
+}
+
+// renamedRecord overrides the channel of a wrapped record.
+type renamedRecord struct {
+	Record
+	channel string
+}
+
+func (r *renamedRecord) Channel() string {
+	return r.channel
+}
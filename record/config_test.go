@@ -0,0 +1,67 @@
+package record
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigURLs(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want []string
+	}{
+		{cfg: Config{}, want: nil},
+		{cfg: DefaultConfig, want: nil},
+		{
+			cfg:  Config{Redis: "127.0.0.1:6379", Mongo: "localhost:27017", Sinks: []string{"file:///tmp/obs.jsonl"}},
+			want: []string{"redis://127.0.0.1:6379", "mongodb://localhost:27017", "file:///tmp/obs.jsonl"},
+		},
+		{cfg: Config{Disable: true, Redis: "127.0.0.1:6379"}, want: nil},
+	}
+	for i, test := range tests {
+		if have := test.cfg.URLs(); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("test %d: wrong urls %v, want %v", i, have, test.want)
+		}
+	}
+}
+
+func TestParseChannels(t *testing.T) {
+	have, err := ParseChannels("BlockInfo=stg.blocks, TxInfo=stg.txs,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{ChanBlockID: "stg.blocks", ChanTxID: "stg.txs"}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong channels %v, want %v", have, want)
+	}
+	for _, bad := range []string{"BlockInfo", "=x", "BlockInfo="} {
+		if _, err := ParseChannels(bad); err == nil {
+			t.Errorf("no error for %q", bad)
+		}
+	}
+}
+
+func TestOpenRenamesChannels(t *testing.T) {
+	cfg := &Config{
+		Sinks:    []string{"memory://"},
+		Channels: map[string]string{ChanTxID: "stg.txs"},
+	}
+	if err := Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	mem := active.(*channelSink).Sink.(multiSink)[0].(*MemorySink)
+	Publish(&TxRecordInfo{TxHash: "0x01"})
+	Publish(&BlockRecordInfo{BlockNum: 1})
+
+	if n := len(mem.Channel("stg.txs")); n != 1 {
+		t.Errorf("have %d renamed tx records, want 1", n)
+	}
+	if n := len(mem.Channel(ChanTxID)); n != 0 {
+		t.Errorf("have %d tx records on default channel, want 0", n)
+	}
+	if n := len(mem.Channel(ChanBlockID)); n != 1 {
+		t.Errorf("have %d block records, want 1", n)
+	}
+}
@@ -0,0 +1,331 @@
+package record
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"peerInfoCollect/rlp"
+)
+
+// SchemaVersion is the version of the observation envelope produced by this
+// collector. Decoders reject envelopes of newer versions.
+const SchemaVersion = 1
+
+var (
+	errUnsupportedVersion  = errors.New("unsupported schema version")
+	errUnknownKind         = errors.New("unknown observation kind")
+	errUnknownEncoding     = errors.New("unknown record encoding")
+	errUnsupportedEncoding = errors.New("unsupported record encoding")
+)
+
+// Kind identifies the type of payload carried in an envelope.
+type Kind uint8
+
+const (
+	KindBlock Kind = iota + 1
+	KindTx
+	KindPeer
+	KindPropagation
+	KindTxAnnounce
+	KindBlockAnnounce
+	KindPeerStatus
+	KindPeerSession
+	KindNodeCrawl
+	KindNodeEvent
+	KindDiscPacket
+)
+
+// Payload is a record that can be carried in a versioned envelope.
+type Payload interface {
+	Record
+	Kind() Kind
+}
+
+var (
+	kindsLock sync.RWMutex
+	kindNames = make(map[Kind]string)
+	kindIDs   = make(map[string]Kind)
+	kindNew   = make(map[Kind]func() Payload)
+)
+
+// RegisterKind makes a payload type known to the envelope decoders.
+func RegisterKind(kind Kind, name string, fn func() Payload) {
+	kindsLock.Lock()
+	defer kindsLock.Unlock()
+
+	if _, ok := kindNames[kind]; ok {
+		panic(fmt.Sprintf("observation kind %d already registered", kind))
+	}
+	kindNames[kind], kindIDs[name], kindNew[kind] = name, kind, fn
+}
+
+func init() {
+	RegisterKind(KindBlock, "block", func() Payload { return new(BlockRecordInfo) })
+	RegisterKind(KindTx, "tx", func() Payload { return new(TxRecordInfo) })
+	RegisterKind(KindPeer, "peer", func() Payload { return new(PeerRecordInfo) })
+	RegisterKind(KindPropagation, "propagation", func() Payload { return new(PropagationRecordInfo) })
+	RegisterKind(KindTxAnnounce, "txannounce", func() Payload { return new(TxAnnounceRecordInfo) })
+	RegisterKind(KindBlockAnnounce, "blockannounce", func() Payload { return new(BlockAnnounceRecordInfo) })
+	RegisterKind(KindPeerStatus, "peerstatus", func() Payload { return new(PeerStatusRecordInfo) })
+	RegisterKind(KindPeerSession, "peersession", func() Payload { return new(PeerSessionRecordInfo) })
+	RegisterKind(KindNodeCrawl, "nodecrawl", func() Payload { return new(NodeCrawlRecordInfo) })
+	RegisterKind(KindNodeEvent, "nodeevent", func() Payload { return new(NodeEventRecordInfo) })
+	RegisterKind(KindDiscPacket, "discpacket", func() Payload { return new(DiscPacketRecordInfo) })
+}
+
+func (k Kind) String() string {
+	kindsLock.RLock()
+	defer kindsLock.RUnlock()
+
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("kind(%d)", uint8(k))
+}
+
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *Kind) UnmarshalText(input []byte) error {
+	kindsLock.RLock()
+	defer kindsLock.RUnlock()
+
+	kind, ok := kindIDs[string(input)]
+	if !ok {
+		return fmt.Errorf("%w: %q", errUnknownKind, input)
+	}
+	*k = kind
+	return nil
+}
+
+// newPayload creates an empty payload of the given kind.
+func newPayload(kind Kind) (Payload, error) {
+	kindsLock.RLock()
+	defer kindsLock.RUnlock()
+
+	fn, ok := kindNew[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", errUnknownKind, kind)
+	}
+	return fn(), nil
+}
+
+// Envelope is the self-describing wire format of an observation.
+type Envelope struct {
+	Version   uint64    // Schema version the envelope was encoded with
+	Kind      Kind      // Type of the payload
+	Time      time.Time // Time the observation was made, nanosecond precision
+	Collector string    // Identifier of the collector instance
+	Payload   Payload   // The observation itself
+
+	encoding Encoding // Encoding used by Encode
+}
+
+// NewEnvelope wraps a payload observed now into an envelope.
+func NewEnvelope(collector string, payload Payload, encoding Encoding) *Envelope {
+	return &Envelope{
+		Version:   SchemaVersion,
+		Kind:      payload.Kind(),
+		Time:      time.Now(),
+		Collector: collector,
+		Payload:   payload,
+		encoding:  encoding,
+	}
+}
+
+// Channel implements Record, envelopes are published on the channel of
+// their payload.
+func (e *Envelope) Channel() string {
+	return e.Payload.Channel()
+}
+
+// Encode implements Record.
+func (e *Envelope) Encode() ([]byte, error) {
+	switch e.encoding {
+	case EncodingRLP:
+		return e.EncodeRLP()
+	default:
+		return e.EncodeJSON()
+	}
+}
+
+// jsonEnvelope is the JSON representation of an envelope.
+type jsonEnvelope struct {
+	Version   uint64          `json:"version"`
+	Kind      Kind            `json:"kind"`
+	Time      int64           `json:"time"`
+	Collector string          `json:"collector"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// EncodeJSON encodes the envelope as JSON, the payload is embedded as an
+// object and the time is given in nanoseconds since the Unix epoch.
+func (e *Envelope) EncodeJSON() ([]byte, error) {
+	payload, err := json.Marshal(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&jsonEnvelope{
+		Version:   e.Version,
+		Kind:      e.Kind,
+		Time:      e.Time.UnixNano(),
+		Collector: e.Collector,
+		Payload:   payload,
+	})
+}
+
+// MarshalJSON implements json.Marshaler, envelopes are always marshalled in
+// their JSON encoding.
+func (e *Envelope) MarshalJSON() ([]byte, error) {
+	return e.EncodeJSON()
+}
+
+// rlpEnvelope is the RLP representation of an envelope.
+type rlpEnvelope struct {
+	Version   uint64
+	Kind      uint64
+	Time      uint64
+	Collector string
+	Payload   rlp.RawValue
+}
+
+// EncodeRLP encodes the envelope as an RLP list, the payload is embedded as
+// the RLP encoding of the payload struct.
+func (e *Envelope) EncodeRLP() ([]byte, error) {
+	payload, err := rlp.EncodeToBytes(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(&rlpEnvelope{
+		Version:   e.Version,
+		Kind:      uint64(e.Kind),
+		Time:      uint64(e.Time.UnixNano()),
+		Collector: e.Collector,
+		Payload:   payload,
+	})
+}
+
+// DecodeEnvelope decodes an envelope in either encoding, telling them apart
+// by the first byte: JSON envelopes are objects, RLP envelopes are lists.
+func DecodeEnvelope(data []byte) (*Envelope, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty envelope")
+	}
+	if data[0] == '{' {
+		return DecodeJSONEnvelope(data)
+	}
+	return DecodeRLPEnvelope(data)
+}
+
+// DecodeJSONEnvelope decodes a JSON encoded envelope.
+func DecodeJSONEnvelope(data []byte) (*Envelope, error) {
+	var enc jsonEnvelope
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(enc.Version); err != nil {
+		return nil, err
+	}
+	payload, err := newPayload(enc.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(enc.Payload, payload); err != nil {
+		return nil, fmt.Errorf("invalid %v payload: %v", enc.Kind, err)
+	}
+	return &Envelope{
+		Version:   enc.Version,
+		Kind:      enc.Kind,
+		Time:      time.Unix(0, enc.Time),
+		Collector: enc.Collector,
+		Payload:   payload,
+		encoding:  EncodingJSON,
+	}, nil
+}
+
+// DecodeRLPEnvelope decodes an RLP encoded envelope.
+func DecodeRLPEnvelope(data []byte) (*Envelope, error) {
+	var enc rlpEnvelope
+	if err := rlp.DecodeBytes(data, &enc); err != nil {
+		return nil, err
+	}
+	if err := checkVersion(enc.Version); err != nil {
+		return nil, err
+	}
+	if enc.Kind > 0xff {
+		return nil, fmt.Errorf("%w: %d", errUnknownKind, enc.Kind)
+	}
+	kind := Kind(enc.Kind)
+	payload, err := newPayload(kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := rlp.DecodeBytes(enc.Payload, payload); err != nil {
+		return nil, fmt.Errorf("invalid %v payload: %v", kind, err)
+	}
+	return &Envelope{
+		Version:   enc.Version,
+		Kind:      kind,
+		Time:      time.Unix(0, int64(enc.Time)),
+		Collector: enc.Collector,
+		Payload:   payload,
+		encoding:  EncodingRLP,
+	}, nil
+}
+
+func checkVersion(version uint64) error {
+	if version == 0 || version > SchemaVersion {
+		return fmt.Errorf("%w: %d", errUnsupportedVersion, version)
+	}
+	return nil
+}
+
+// Encoding selects the wire format of published observations.
+type Encoding string
+
+const (
+	EncodingLegacy Encoding = "legacy" // bare JSON records without an envelope
+	EncodingJSON   Encoding = "json"   // JSON envelopes
+	EncodingRLP    Encoding = "rlp"    // RLP envelopes
+)
+
+// ParseEncoding validates the name of a record encoding.
+func ParseEncoding(name string) (Encoding, error) {
+	switch e := Encoding(name); e {
+	case EncodingLegacy, EncodingJSON, EncodingRLP:
+		return e, nil
+	case "":
+		return EncodingLegacy, nil
+	}
+	return "", fmt.Errorf("%w %q, want %q, %q or %q", errUnknownEncoding, name, EncodingLegacy, EncodingJSON, EncodingRLP)
+}
+
+// envelopeSink wraps every payload into an envelope before handing it on.
+// It sits in front of the queue, so the envelope time is the time the
+// observation was published, not the time it reached the backend.
+type envelopeSink struct {
+	Sink
+	collector string
+	encoding  Encoding
+}
+
+func (s *envelopeSink) Publish(rec Record) error {
+	if payload, ok := rec.(Payload); ok {
+		rec = NewEnvelope(s.collector, payload, s.encoding)
+	}
+	return s.Sink.Publish(rec)
+}
+
+// defaultCollectorID identifies the collector if none is configured.
+func defaultCollectorID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return host
+}
@@ -0,0 +1,106 @@
+package record
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"peerInfoCollect/p2p/geoip"
+)
+
+func TestEnvelopeRoundtrip(t *testing.T) {
+	payloads := []Payload{
+		&BlockRecordInfo{BlockNum: 14000000, BlockHash: "0x01", Data: `{"number":"0xd59f80"}`, PeerId: "aa", PeerAddress: "1.2.3.4:30303"},
+		&TxRecordInfo{TxHash: "0x02", Payload: "{}", PeerId: "bb", PeerAddr: "5.6.7.8:30303"},
+		&PeerRecordInfo{PeerId: "cc", PeerAddress: "9.9.9.9:30303", Time: 6},
+		&PropagationRecordInfo{Object: "block", Hash: "0x05", Number: 14000002, FirstSeen: 3, FirstPeer: "ab", FirstMessage: "NewBlock", Peers: 2, Sightings: []PropagationSighting{{PeerId: "ab", Message: "NewBlock"}, {PeerId: "cd", Message: "NewBlockHashes", Delay: 8}}, Dropped: 1},
+		&TxAnnounceRecordInfo{PeerId: "dd", Time: 1, Hashes: []TxAnnounceHash{{Hash: "0x03", Fetched: FetchedOther, FetchedFrom: "ee", Delay: 5}}},
+		&BlockAnnounceRecordInfo{PeerId: "ff", Hash: "0x04", Number: 14000001, Time: 2, Mode: AnnounceHash, Pushed: true, PushDelay: 7, Geo: &geoip.Info{Country: "DE", City: "Berlin", ASN: 3320, Org: "Deutsche Telekom AG"}},
+		&PeerStatusRecordInfo{PeerId: "gg", Enode: "enode://gg@1.2.3.4:30303", Name: "Geth/v1.10.17", Caps: []string{"eth/66"}, Inbound: true, NetworkID: 1, TD: "1000", Error: "not match", Duration: 9, Client: "geth", ClientVersion: "1.10.17", OS: "linux", Arch: "amd64", Runtime: "go1.18"},
+		&PeerSessionRecordInfo{PeerId: "hh", Enode: "enode://hh@1.2.3.4:30303", Event: SessionDisconnected, Reason: "too many peers", RemoteRequested: true, Duration: 11, Messages: []MessageCount{{Code: 0x01, Count: 3}, {Code: 0x07, Count: 1}}, BytesIn: 1024, BytesOut: 512},
+		&NodeCrawlRecordInfo{PeerId: "ii", Enode: "enode://ii@1.2.3.4:30303", Seq: 3, Name: "Geth/v1.10.17", Caps: []string{"eth/66", "snap/1"}, ListenPort: 30303, ProtocolVersion: 66, NetworkID: 1, TD: "1000", ForkHash: "0x20c327fc", Client: "geth", ClientVersion: "1.10.17", Duration: 13},
+		&NodeEventRecordInfo{PeerId: "jj", Enode: "enode://jj@1.2.3.4:30303", PeerAddr: "1.2.3.4:30303", Seq: 4, Event: NodeRemoved, FirstResponse: 1, LastResponse: 2, Time: 3},
+		&DiscPacketRecordInfo{PeerId: "kk", PeerAddr: "1.2.3.4:30303", Protocol: "discv4", Packet: "PING/v4", Seq: 5, Size: 120, Duration: 14, Time: 4},
+	}
+	for _, payload := range payloads {
+		for _, encoding := range []Encoding{EncodingJSON, EncodingRLP} {
+			env := NewEnvelope("collector-1", payload, encoding)
+			data, err := env.Encode()
+			if err != nil {
+				t.Fatalf("%v/%s: encode failed: %v", payload.Kind(), encoding, err)
+			}
+			if encoding == EncodingJSON && data[0] != '{' || encoding == EncodingRLP && data[0] < 0xc0 {
+				t.Fatalf("%v/%s: wrong encoding %x", payload.Kind(), encoding, data)
+			}
+			dec, err := DecodeEnvelope(data)
+			if err != nil {
+				t.Fatalf("%v/%s: decode failed: %v", payload.Kind(), encoding, err)
+			}
+			if dec.Version != SchemaVersion || dec.Kind != payload.Kind() || dec.Collector != "collector-1" {
+				t.Errorf("%v/%s: wrong header %+v", payload.Kind(), encoding, dec)
+			}
+			if !dec.Time.Equal(env.Time.Round(0)) {
+				t.Errorf("%v/%s: wrong time %v, want %v", payload.Kind(), encoding, dec.Time, env.Time)
+			}
+			if !reflect.DeepEqual(dec.Payload, payload) {
+				t.Errorf("%v/%s: wrong payload %+v, want %+v", payload.Kind(), encoding, dec.Payload, payload)
+			}
+			if dec.Channel() != payload.Channel() {
+				t.Errorf("%v/%s: wrong channel %s", payload.Kind(), encoding, dec.Channel())
+			}
+		}
+	}
+}
+
+func TestEnvelopeDecodeErrors(t *testing.T) {
+	future := &Envelope{Version: SchemaVersion + 1, Kind: KindTx, Time: time.Now(), Payload: &TxRecordInfo{}}
+	for _, encode := range []func() ([]byte, error){future.EncodeJSON, future.EncodeRLP} {
+		data, _ := encode()
+		if _, err := DecodeEnvelope(data); !errors.Is(err, errUnsupportedVersion) {
+			t.Errorf("wrong error for future version: %v", err)
+		}
+	}
+	unknown := []byte(`{"version":1,"kind":"nosuch","time":0,"collector":"","payload":{}}`)
+	if _, err := DecodeEnvelope(unknown); !errors.Is(err, errUnknownKind) {
+		t.Errorf("wrong error for unknown kind: %v", err)
+	}
+	for _, bad := range [][]byte{nil, []byte("{"), {0xc1}} {
+		if _, err := DecodeEnvelope(bad); err == nil {
+			t.Errorf("no error decoding %x", bad)
+		}
+	}
+	if err := new(BlockRecordInfo).Decode([]byte("garbage")); err == nil {
+		t.Error("no error decoding garbage record")
+	}
+}
+
+func TestOpenEnvelopes(t *testing.T) {
+	cfg := &Config{Sinks: []string{"memory://"}, Encoding: "rlp", Collector: "test"}
+	if err := Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	mem := active.(*envelopeSink).Sink.(multiSink)[0].(*MemorySink)
+	Publish(&BlockRecordInfo{BlockNum: 1})
+
+	recs := mem.Channel(ChanBlockID)
+	if len(recs) != 1 {
+		t.Fatalf("have %d records, want 1", len(recs))
+	}
+	data, _ := recs[0].Encode()
+	env, err := DecodeRLPEnvelope(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Collector != "test" || env.Payload.(*BlockRecordInfo).BlockNum != 1 {
+		t.Fatalf("wrong envelope %+v", env)
+	}
+	if _, err := ParseEncoding("xml"); !errors.Is(err, errUnknownEncoding) {
+		t.Fatalf("wrong error for unknown encoding: %v", err)
+	}
+	if err := Open(&Config{Mongo: "127.0.0.1:27017", Encoding: "rlp"}); !errors.Is(err, errUnsupportedEncoding) {
+		t.Fatalf("wrong error for rlp records in mongodb: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package record
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"peerInfoCollect/event"
+	"peerInfoCollect/p2p/geoip"
+)
+
+var (
+	feedLock      sync.RWMutex
+	feedSubs      = make(map[*feedSub]struct{})
+	feedCollector atomic.Value // string, identifier of the collector in feed envelopes
+)
+
+// feedSub is a feed subscription.
+type feedSub struct {
+	ch chan<- *Envelope
+}
+
+func init() {
+	setFeedCollector(defaultCollectorID())
+}
+
+// SubscribeEnvelopes delivers every payload passed to Publish, wrapped in an
+// envelope, to the given channel. The feed is independent of the installed
+// sinks and their encoding. Publish never waits for subscribers: the channel
+// should be buffered, envelopes not fitting into it are dropped.
+func SubscribeEnvelopes(ch chan<- *Envelope) event.Subscription {
+	sub := &feedSub{ch: ch}
+	feedLock.Lock()
+	feedSubs[sub] = struct{}{}
+	feedLock.Unlock()
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		feedLock.Lock()
+		delete(feedSubs, sub)
+		feedLock.Unlock()
+		return nil
+	})
+}
+
+// setFeedCollector sets the collector identifier of feed envelopes.
+func setFeedCollector(collector string) {
+	feedCollector.Store(collector)
+}
+
+// deliver hands a record to the feed subscribers, if it is a payload.
+// Subscribers whose channel is full miss the record.
+func deliver(rec Record) {
+	payload, ok := rec.(Payload)
+	if !ok {
+		return
+	}
+	feedLock.RLock()
+	defer feedLock.RUnlock()
+
+	if len(feedSubs) == 0 {
+		return
+	}
+	env := NewEnvelope(feedCollector.Load().(string), payload, EncodingJSON)
+	for sub := range feedSubs {
+		select {
+		case sub.ch <- env:
+		default:
+			feedDropMeter.Mark(1)
+		}
+	}
+}
+
+// Origin returns the ID and address of the peer a record was observed from,
+// empty if the record isn't tied to a single peer.
+func Origin(rec Record) (id string, addr string) {
+	id, addr, _ = peerFields(rec)
+	return id, addr
+}
+
+// locate attaches the location of the peer address to a record, if the
+// record is tied to a single peer and the address can be resolved.
+func locate(rec Record) {
+	_, addr, geo := peerFields(rec)
+	if geo == nil || *geo != nil || addr == "" {
+		return
+	}
+	*geo = geoip.Lookup(addr)
+}
+
+// peerFields returns the peer ID and address of a record along with its
+// location field, all empty if the record isn't tied to a single peer.
+func peerFields(rec Record) (id string, addr string, geo **geoip.Info) {
+	if env, ok := rec.(*Envelope); ok {
+		rec = env.Payload
+	}
+	switch r := rec.(type) {
+	case *BlockRecordInfo:
+		return r.PeerId, r.PeerAddress, &r.Geo
+	case *TxRecordInfo:
+		return r.PeerId, r.PeerAddr, &r.Geo
+	case *PeerRecordInfo:
+		return r.PeerId, r.PeerAddress, &r.Geo
+	case *TxAnnounceRecordInfo:
+		return r.PeerId, r.PeerAddr, &r.Geo
+	case *BlockAnnounceRecordInfo:
+		return r.PeerId, r.PeerAddr, &r.Geo
+	case *PeerStatusRecordInfo:
+		return r.PeerId, r.PeerAddr, &r.Geo
+	case *PeerSessionRecordInfo:
+		return r.PeerId, r.PeerAddr, &r.Geo
+	case *NodeCrawlRecordInfo:
+		return r.PeerId, r.PeerAddr, &r.Geo
+	case *NodeEventRecordInfo:
+		return r.PeerId, r.PeerAddr, &r.Geo
+	case *DiscPacketRecordInfo:
+		return r.PeerId, r.PeerAddr, &r.Geo
+	}
+	return "", "", nil
+}
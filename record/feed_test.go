@@ -0,0 +1,98 @@
+package record
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFeed(t *testing.T) {
+	sink := NewMemorySink()
+	SetSink(sink)
+	defer Close()
+
+	ch := make(chan *Envelope, 2)
+	sub := SubscribeEnvelopes(ch)
+	defer sub.Unsubscribe()
+
+	Publish(&BlockRecordInfo{BlockNum: 1})
+	Publish(&rawRecord{channel: "raw", data: []byte("x")})
+
+	select {
+	case env := <-ch:
+		if env.Kind != KindBlock || env.Payload.(*BlockRecordInfo).BlockNum != 1 {
+			t.Fatalf("wrong envelope delivered: %+v", env)
+		}
+		blob, err := json.Marshal(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := DecodeEnvelope(blob)
+		if err != nil {
+			t.Fatalf("delivered envelope doesn't decode: %v", err)
+		}
+		if dec.Kind != KindBlock {
+			t.Fatalf("wrong decoded kind %v", dec.Kind)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("envelope not delivered")
+	}
+	select {
+	case env := <-ch:
+		t.Fatalf("non-payload record delivered: %+v", env)
+	default:
+	}
+	if n := len(sink.Records()); n != 2 {
+		t.Fatalf("sink received %d records, want 2", n)
+	}
+}
+
+func TestFeedFull(t *testing.T) {
+	SetSink(NewMemorySink())
+	defer Close()
+
+	ch := make(chan *Envelope, 1)
+	sub := SubscribeEnvelopes(ch)
+	defer sub.Unsubscribe()
+
+	// Publish must not wait for a subscriber which doesn't keep up.
+	done := make(chan struct{})
+	go func() {
+		for i := uint64(1); i <= 3; i++ {
+			Publish(&BlockRecordInfo{BlockNum: i})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+	if env := <-ch; env.Payload.(*BlockRecordInfo).BlockNum != 1 {
+		t.Fatalf("wrong envelope delivered: %+v", env)
+	}
+	sub.Unsubscribe()
+	Publish(&BlockRecordInfo{BlockNum: 4})
+	select {
+	case env := <-ch:
+		t.Fatalf("envelope delivered after unsubscribing: %+v", env)
+	default:
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	tests := []struct {
+		rec      Record
+		id, addr string
+	}{
+		{&BlockRecordInfo{PeerId: "aa", PeerAddress: "1.2.3.4:30303"}, "aa", "1.2.3.4:30303"},
+		{&TxRecordInfo{PeerId: "bb", PeerAddr: "5.6.7.8:30303"}, "bb", "5.6.7.8:30303"},
+		{NewEnvelope("c", &PeerSessionRecordInfo{PeerId: "cc", PeerAddr: "9.9.9.9:30303"}, EncodingJSON), "cc", "9.9.9.9:30303"},
+		{&PropagationRecordInfo{FirstPeer: "dd"}, "", ""},
+	}
+	for i, tt := range tests {
+		if id, addr := Origin(tt.rec); id != tt.id || addr != tt.addr {
+			t.Errorf("test %d: have %q %q, want %q %q", i, id, addr, tt.id, tt.addr)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+)
+
+func init() {
+	RegisterSink("file", openFileSink)
+}
+
+// fileEntry is a single line written by the file sink.
+type fileEntry struct {
+	Channel string          `json:"channel"`
+	Record  json.RawMessage `json:"record"`
+}
+
+// fileSink appends every record as a line of JSON to a local file.
+type fileSink struct {
+	lock sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+}
+
+func openFileSink(u *url.URL) (Sink, error) {
+	path := u.Path
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return nil, errors.New("file sink needs a path")
+	}
+	return NewFileSink(path)
+}
+
+// NewFileSink creates a sink appending newline delimited JSON to path.
+func NewFileSink(path string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: f, buf: bufio.NewWriter(f)}, nil
+}
+
+func (s *fileSink) Publish(rec Record) error {
+	data, err := rec.Encode()
+	if err != nil {
+		return Permanent(err)
+	}
+	line, err := json.Marshal(&fileEntry{Channel: rec.Channel(), Record: data})
+	if err != nil {
+		return Permanent(err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, err := s.buf.Write(line); err != nil {
+		return err
+	}
+	return s.buf.WriteByte('\n')
+}
+
+func (s *fileSink) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.buf.Flush()
+}
+
+func (s *fileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.buf.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,68 @@
+package record
+
+import (
+	"net/url"
+	"sync"
+)
+
+func init() {
+	RegisterSink("memory", func(*url.URL) (Sink, error) { return NewMemorySink(), nil })
+}
+
+// MemorySink keeps every published record in memory. It is mostly useful
+// for tests that need to inspect what the collector would have sent out.
+type MemorySink struct {
+	lock    sync.Mutex
+	records []Record
+	closed  bool
+}
+
+// NewMemorySink creates an empty in-memory sink.
+func NewMemorySink() *MemorySink {
+	return new(MemorySink)
+}
+
+func (s *MemorySink) Publish(rec Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return errSinkClosed
+	}
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *MemorySink) Flush() error {
+	return nil
+}
+
+func (s *MemorySink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	return nil
+}
+
+// Records returns a copy of the records published so far.
+func (s *MemorySink) Records() []Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Record(nil), s.records...)
+}
+
+// Channel returns the records published so far on the given channel.
+func (s *MemorySink) Channel(name string) []Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var recs []Record
+	for _, rec := range s.records {
+		if rec.Channel() == name {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
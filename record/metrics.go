@@ -0,0 +1,20 @@
+package record
+
+import (
+	"peerInfoCollect/metrics"
+)
+
+var (
+	queueDepthGauge   = metrics.NewRegisteredGauge("record/queue/depth", nil)
+	queueDropMeter    = metrics.NewRegisteredMeter("record/queue/drop", nil)
+	publishMeter      = metrics.NewRegisteredMeter("record/publish/records", nil)
+	publishBatchTimer = metrics.NewRegisteredTimer("record/publish/latency", nil)
+	publishErrorMeter = metrics.NewRegisteredMeter("record/publish/errors", nil)
+	feedDropMeter     = metrics.NewRegisteredMeter("record/feed/drop", nil)
+
+	spoolSizeGauge    = metrics.NewRegisteredGauge("record/spool/size", nil)
+	spoolDropMeter    = metrics.NewRegisteredMeter("record/spool/drop", nil)
+	spoolReplayMeter  = metrics.NewRegisteredMeter("record/spool/replay", nil)
+	spoolRejectMeter  = metrics.NewRegisteredMeter("record/spool/reject", nil)
+	spoolCorruptMeter = metrics.NewRegisteredMeter("record/spool/corrupt", nil)
+)
@@ -0,0 +1,62 @@
+package record
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultMongoDatabase is used when the sink URL doesn't name a database.
+const defaultMongoDatabase = "blockRecord"
+
+func init() {
+	RegisterSink("mongodb", openMongoSink)
+}
+
+// mongoSink inserts every record as a document into the collection named
+// after the record's channel.
+type mongoSink struct {
+	client *mongo.Client
+	db     *mongo.Database
+}
+
+func openMongoSink(u *url.URL) (Sink, error) {
+	dbname := strings.TrimPrefix(u.Path, "/")
+	if dbname == "" {
+		dbname = defaultMongoDatabase
+	}
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(u.String()))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(context.TODO(), nil); err != nil {
+		client.Disconnect(context.TODO())
+		return nil, err
+	}
+	return &mongoSink{client: client, db: client.Database(dbname)}, nil
+}
+
+func (s *mongoSink) Publish(rec Record) error {
+	data, err := rec.Encode()
+	if err != nil {
+		return Permanent(err)
+	}
+	var doc bson.M
+	if err := bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+		return Permanent(err)
+	}
+	_, err = s.db.Collection(rec.Channel()).InsertOne(context.TODO(), doc)
+	return err
+}
+
+func (s *mongoSink) Flush() error {
+	return nil
+}
+
+func (s *mongoSink) Close() error {
+	return s.client.Disconnect(context.TODO())
+}
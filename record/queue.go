@@ -0,0 +1,244 @@
+package record
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"peerInfoCollect/log"
+)
+
+// DropPolicy decides what happens to a record published into a full queue.
+type DropPolicy string
+
+const (
+	DropNewest DropPolicy = "newest" // discard the record being published
+	DropOldest DropPolicy = "oldest" // evict the oldest queued record to make room
+	DropNone   DropPolicy = "block"  // wait until there is room in the queue
+)
+
+// ErrQueueFull is returned by Publish if a record was dropped because the
+// queue was full.
+var ErrQueueFull = errors.New("record queue full")
+
+// ParseDropPolicy validates the name of a drop policy.
+func ParseDropPolicy(name string) (DropPolicy, error) {
+	switch p := DropPolicy(name); p {
+	case DropNewest, DropOldest, DropNone:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown drop policy %q, want %q, %q or %q", name, DropNewest, DropOldest, DropNone)
+}
+
+// BatchSink is implemented by sinks that can deliver several records in one
+// round trip, e.g. by pipelining the commands to the backend.
+type BatchSink interface {
+	Sink
+	PublishBatch(recs []Record) error
+}
+
+// publishBatch delivers a batch of records to a sink, in one go if the sink
+// supports it or one by one otherwise. Failures to reach the sink are
+// reported in preference to permanent rejections of single records.
+func publishBatch(s Sink, recs []Record) error {
+	if bs, ok := s.(BatchSink); ok {
+		return bs.PublishBatch(recs)
+	}
+	var first error
+	for _, rec := range recs {
+		if err := s.Publish(rec); err != nil && (first == nil || IsPermanent(first) && !IsPermanent(err)) {
+			first = err
+		}
+	}
+	return first
+}
+
+// QueueConfig configures the asynchronous publishing queue.
+type QueueConfig struct {
+	Size    int        // Maximum number of records waiting to be published
+	Workers int        // Number of goroutines publishing to the sink
+	Batch   int        // Maximum number of records published in one go
+	Drop    DropPolicy // What to do with records when the queue is full
+}
+
+// queueSink decouples publishers from a slow sink. Publish only enqueues the
+// record, a set of workers hands them over to the wrapped sink in batches.
+type queueSink struct {
+	sink   Sink
+	cfg    QueueConfig
+	queue  chan Record
+	closed chan struct{}
+	wg     sync.WaitGroup
+
+	closeLock sync.RWMutex // held for reading while enqueueing, for writing on close
+	closing   bool
+
+	lock    sync.Mutex
+	cond    *sync.Cond // signalled whenever pending drops to zero
+	pending int        // records enqueued but not yet published
+}
+
+// NewQueueSink wraps a sink into a bounded, non-blocking publishing queue.
+func NewQueueSink(sink Sink, cfg QueueConfig) Sink {
+	if cfg.Size <= 0 {
+		cfg.Size = 1
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Batch <= 0 {
+		cfg.Batch = 1
+	}
+	if cfg.Drop == "" {
+		cfg.Drop = DropNewest
+	}
+	q := &queueSink{
+		sink:   sink,
+		cfg:    cfg,
+		queue:  make(chan Record, cfg.Size),
+		closed: make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.lock)
+
+	q.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go q.loop()
+	}
+	return q
+}
+
+// Publish enqueues a record, applying the drop policy if the queue is full.
+func (q *queueSink) Publish(rec Record) error {
+	q.closeLock.RLock()
+	defer q.closeLock.RUnlock()
+
+	if q.closing {
+		return errSinkClosed
+	}
+	q.lock.Lock()
+	q.pending++
+	q.lock.Unlock()
+
+	for {
+		select {
+		case q.queue <- rec:
+			queueDepthGauge.Update(int64(len(q.queue)))
+			return nil
+		default:
+		}
+		switch q.cfg.Drop {
+		case DropOldest:
+			select {
+			case <-q.queue:
+				queueDropMeter.Mark(1)
+				q.done(1)
+			default:
+			}
+		case DropNone:
+			// Workers only stop once the queue is closed, which can't happen
+			// while we're holding the close lock.
+			q.queue <- rec
+			queueDepthGauge.Update(int64(len(q.queue)))
+			return nil
+		default:
+			queueDropMeter.Mark(1)
+			q.done(1)
+			return ErrQueueFull
+		}
+	}
+}
+
+// Flush waits until every record enqueued so far has been published and then
+// flushes the wrapped sink.
+func (q *queueSink) Flush() error {
+	q.lock.Lock()
+	for q.pending > 0 {
+		q.cond.Wait()
+	}
+	q.lock.Unlock()
+
+	return q.sink.Flush()
+}
+
+// Close stops accepting records, publishes everything still queued and
+// closes the wrapped sink.
+func (q *queueSink) Close() error {
+	q.closeLock.Lock()
+	if q.closing {
+		q.closeLock.Unlock()
+		return errSinkClosed
+	}
+	q.closing = true
+	q.closeLock.Unlock()
+
+	close(q.closed)
+	q.wg.Wait()
+	return q.sink.Close()
+}
+
+// done marks n records as no longer pending.
+func (q *queueSink) done(n int) {
+	q.lock.Lock()
+	q.pending -= n
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+	q.lock.Unlock()
+}
+
+// loop collects batches of queued records and publishes them until the sink
+// is closed and the queue is drained.
+func (q *queueSink) loop() {
+	defer q.wg.Done()
+
+	batch := make([]Record, 0, q.cfg.Batch)
+	for {
+		select {
+		case rec := <-q.queue:
+			batch = append(batch[:0], rec)
+		case <-q.closed:
+			// Drain whatever is left before shutting down
+			for {
+				select {
+				case rec := <-q.queue:
+					batch = append(batch[:0], rec)
+					q.fill(&batch)
+					q.publish(batch)
+				default:
+					return
+				}
+			}
+		}
+		q.fill(&batch)
+		q.publish(batch)
+	}
+}
+
+// fill tops up a batch with records already waiting in the queue.
+func (q *queueSink) fill(batch *[]Record) {
+	for len(*batch) < q.cfg.Batch {
+		select {
+		case rec := <-q.queue:
+			*batch = append(*batch, rec)
+		default:
+			return
+		}
+	}
+}
+
+// publish hands a batch over to the wrapped sink.
+func (q *queueSink) publish(batch []Record) {
+	queueDepthGauge.Update(int64(len(q.queue)))
+	defer q.done(len(batch))
+
+	start := time.Now()
+	err := publishBatch(q.sink, batch)
+	publishBatchTimer.UpdateSince(start)
+	publishMeter.Mark(int64(len(batch)))
+
+	if err != nil {
+		publishErrorMeter.Mark(1)
+		log.Debug("Failed to publish records", "count", len(batch), "err", err)
+	}
+}
@@ -0,0 +1,151 @@
+package record
+
+import (
+	"sync"
+	"testing"
+)
+
+// gatedSink is a batch capable memory sink that blocks publishing until the
+// gate is opened, simulating a slow backend.
+type gatedSink struct {
+	*MemorySink
+	entered chan struct{}
+	gate    chan struct{}
+	lock    sync.Mutex
+	batches []int
+}
+
+func newGatedSink() *gatedSink {
+	return &gatedSink{
+		MemorySink: NewMemorySink(),
+		entered:    make(chan struct{}, 1),
+		gate:       make(chan struct{}),
+	}
+}
+
+func (s *gatedSink) Publish(rec Record) error {
+	return s.PublishBatch([]Record{rec})
+}
+
+func (s *gatedSink) PublishBatch(recs []Record) error {
+	select {
+	case s.entered <- struct{}{}:
+	default:
+	}
+	<-s.gate
+	s.lock.Lock()
+	s.batches = append(s.batches, len(recs))
+	s.lock.Unlock()
+	for _, rec := range recs {
+		s.MemorySink.Publish(rec)
+	}
+	return nil
+}
+
+// stall publishes a record and waits until the single worker is blocked
+// publishing it, so that the queue itself is empty.
+func stall(t *testing.T, q *queueSink, sink *gatedSink) {
+	if err := q.Publish(&TxRecordInfo{TxHash: "stall"}); err != nil {
+		t.Fatal(err)
+	}
+	<-sink.entered
+}
+
+func txHashes(recs []Record) []string {
+	hashes := make([]string, len(recs))
+	for i, rec := range recs {
+		hashes[i] = rec.(*TxRecordInfo).TxHash
+	}
+	return hashes
+}
+
+func TestQueueDropNewest(t *testing.T) {
+	sink := newGatedSink()
+	q := NewQueueSink(sink, QueueConfig{Size: 2, Workers: 1, Batch: 10, Drop: DropNewest}).(*queueSink)
+	stall(t, q, sink)
+
+	for i, hash := range []string{"a", "b", "c"} {
+		err := q.Publish(&TxRecordInfo{TxHash: hash})
+		if i < 2 && err != nil {
+			t.Fatalf("publish %s failed: %v", hash, err)
+		}
+		if i == 2 && err != ErrQueueFull {
+			t.Fatalf("publish %s: wrong error %v, want %v", hash, err, ErrQueueFull)
+		}
+	}
+	close(sink.gate)
+	if err := q.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if have := txHashes(sink.Records()); len(have) != 3 || have[1] != "a" || have[2] != "b" {
+		t.Fatalf("wrong records published: %v", have)
+	}
+	// The two records queued behind the stalled one must have gone out in
+	// a single batch.
+	if len(sink.batches) != 2 || sink.batches[1] != 2 {
+		t.Fatalf("wrong batches: %v", sink.batches)
+	}
+	q.Close()
+}
+
+func TestQueueDropOldest(t *testing.T) {
+	sink := newGatedSink()
+	q := NewQueueSink(sink, QueueConfig{Size: 2, Workers: 1, Batch: 10, Drop: DropOldest}).(*queueSink)
+	stall(t, q, sink)
+
+	for _, hash := range []string{"a", "b", "c"} {
+		if err := q.Publish(&TxRecordInfo{TxHash: hash}); err != nil {
+			t.Fatalf("publish %s failed: %v", hash, err)
+		}
+	}
+	close(sink.gate)
+	q.Flush()
+
+	if have := txHashes(sink.Records()); len(have) != 3 || have[1] != "b" || have[2] != "c" {
+		t.Fatalf("wrong records published: %v", have)
+	}
+	q.Close()
+}
+
+func TestQueueBlock(t *testing.T) {
+	sink := newGatedSink()
+	q := NewQueueSink(sink, QueueConfig{Size: 1, Workers: 1, Batch: 10, Drop: DropNone}).(*queueSink)
+	stall(t, q, sink)
+	q.Publish(&TxRecordInfo{TxHash: "a"})
+
+	done := make(chan error)
+	go func() { done <- q.Publish(&TxRecordInfo{TxHash: "b"}) }()
+	select {
+	case <-done:
+		t.Fatal("publish into full queue didn't block")
+	default:
+	}
+	close(sink.gate)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	if have := txHashes(sink.Records()); len(have) != 3 {
+		t.Fatalf("wrong records published: %v", have)
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	sink := NewMemorySink()
+	q := NewQueueSink(sink, QueueConfig{Size: 100, Workers: 4, Batch: 8})
+
+	for i := 0; i < 50; i++ {
+		if err := q.Publish(&BlockRecordInfo{BlockNum: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(sink.Records()); n != 50 {
+		t.Fatalf("have %d records after close, want 50", n)
+	}
+	if err := q.Publish(&BlockRecordInfo{}); err != errSinkClosed {
+		t.Fatalf("wrong error after close: %v", err)
+	}
+}
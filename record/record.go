@@ -3,16 +3,25 @@ package record
 import (
 	"context"
 	"encoding/json"
-	"github.com/go-redis/redis/v8"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"peerInfoCollect/log"
+	"peerInfoCollect/p2p/geoip"
 )
 
 const (
 	ChanBlockID  = "BlockInfo"
 	ChanTxID = "TxInfo"
+	ChanPeerID = "PeerInfo"
+	ChanPropagationID = "PropagationInfo"
+	ChanTxAnnounceID = "TxAnnounceInfo"
+	ChanBlockAnnounceID = "BlockAnnounceInfo"
+	ChanPeerStatusID = "PeerStatusInfo"
+	ChanPeerSessionID = "PeerSessionInfo"
+	ChanNodeCrawlID = "NodeCrawlInfo"
+	ChanNodeEventID = "NodeEventInfo"
+	ChanDiscPacketID = "DiscPacketInfo"
 )
 
 /**
@@ -82,6 +91,10 @@ func FindInfoWithNumber(c *mongo.Collection,num uint64) (*RecordInfo,error) {
 /**
 redis db record
 **/
+// BlockRecordInfo and TxRecordInfo keep the layout of the original redis
+// records, which the legacy encoding publishes unchanged: the header and the
+// transaction are nested JSON strings and Timestamp is the local time in Go's
+// default format.
 type BlockRecordInfo struct {
 	BlockNum   uint64    `json:"blocknum"`
 	BlockHash  string    `json:"blockhash"`
@@ -89,14 +102,23 @@ type BlockRecordInfo struct {
 	Timestamp  string    `json:"timestamp"`
 	PeerId     string    `json:"peerid"`
 	PeerAddress string   `json:"peeraddress"`
+	Geo        *geoip.Info `json:"geo,omitempty" rlp:"optional"`
+}
+
+func(b *BlockRecordInfo) Channel() string {
+	return ChanBlockID
 }
 
 func(b *BlockRecordInfo) Encode() ([]byte,error) {
 	return json.Marshal(b)
 }
 
-func(b *BlockRecordInfo) Decode(data []byte) {
-	json.Unmarshal(data,b)
+func (b *BlockRecordInfo) Kind() Kind {
+	return KindBlock
+}
+
+func (b *BlockRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, b)
 }
 
 
@@ -105,42 +127,369 @@ type TxRecordInfo struct {
 	Payload   string  `json:"payload"`
 	PeerId    string  `json:"peerid"`
 	PeerAddr  string  `json:"peeraddr"`
+	Geo        *geoip.Info `json:"geo,omitempty" rlp:"optional"`
+}
+
+func (t *TxRecordInfo) Channel() string {
+	return ChanTxID
 }
 
 func (t *TxRecordInfo) Encode() ([]byte,error)  {
 	return json.Marshal(t)
 }
 
-func (t *TxRecordInfo) Decode(data []byte) {
-	json.Unmarshal(data,t)
+func (t *TxRecordInfo) Kind() Kind {
+	return KindTx
 }
 
-var RdbClient *redis.Client
+func (t *TxRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, t)
+}
 
-func GetRdbCli() *redis.Client{
-	if RdbClient == nil {
-		RdbClient = initRengine()
-		return RdbClient
-	}
-	return RdbClient
+// PeerRecordInfo is published when a peer passes the status handshake.
+// Timestamp keeps the legacy redis layout, Time is the machine readable one.
+type PeerRecordInfo struct {
+	PeerId      string  `json:"peerid"`
+	PeerAddress string  `json:"peeraddress"`
+	Timestamp   string  `json:"timestamp"`
+	Geo        *geoip.Info `json:"geo,omitempty" rlp:"nil,optional"` // nil encodes as an empty list ahead of Time
+	Time        uint64      `json:"time" rlp:"optional"`               // unix nanoseconds of the handshake
 }
 
-func initRengine() *redis.Client {
-	 rdb := redis.NewClient(&redis.Options{
-		Addr:       "172.31.41.210:6379",
-		Password:   "",
-		DB:         0,
-		PoolSize:   100,
-	})
+func (p *PeerRecordInfo) Channel() string {
+	return ChanPeerID
+}
 
-	return rdb
+func (p *PeerRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(p)
 }
 
-func PubMessage(client * redis.Client,tp,msg string) error {
-	err := client.Publish(context.Background(),tp,msg).Err()
-	if err != nil {
-		return err
-	}
-	return nil
+func (p *PeerRecordInfo) Kind() Kind {
+	return KindPeer
+}
+
+func (p *PeerRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, p)
+}
+
+// PropagationRecordInfo summarizes how a block or transaction spread to us
+// across peers, emitted once the object aged out of the tracker.
+type PropagationRecordInfo struct {
+	Object       string                `json:"object"` // "block" or "tx"
+	Hash         string                `json:"hash"`
+	Number       uint64                `json:"number"` // block number, zero for transactions
+	FirstSeen    uint64                `json:"firstseen"` // unix nanoseconds of the first sighting
+	FirstPeer    string                `json:"firstpeer"`
+	FirstMessage string                `json:"firstmessage"`
+	Peers        uint64                `json:"peers"` // number of distinct peers
+	Sightings    []PropagationSighting `json:"sightings"`
+	Dropped      uint64                `json:"dropped"` // sightings beyond the tracking limit
+}
+
+// PropagationSighting is a single sighting of a propagated object.
+type PropagationSighting struct {
+	PeerId  string `json:"peerid"`
+	Message string `json:"message"`
+	Delay   uint64 `json:"delay"` // nanoseconds since the first sighting
+}
+
+func (p *PropagationRecordInfo) Channel() string {
+	return ChanPropagationID
+}
+
+func (p *PropagationRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(p)
+}
+
+func (p *PropagationRecordInfo) Kind() Kind {
+	return KindPropagation
+}
+
+func (p *PropagationRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, p)
+}
+
+// Fetch outcomes of an announced transaction hash.
+const (
+	FetchedSelf  = "self"  // body retrieved from the announcing peer
+	FetchedOther = "other" // body retrieved from a different peer
+	FetchedNone  = "none"  // body never retrieved while the announcement was tracked
+)
+
+// TxAnnounceRecordInfo is a NewPooledTransactionHashes announcement of a
+// peer, emitted once the fetch outcome of every announced hash is known or
+// the announcement aged out.
+type TxAnnounceRecordInfo struct {
+	PeerId   string           `json:"peerid"`
+	PeerAddr string           `json:"peeraddr"`
+	Time     uint64           `json:"time"` // unix nanoseconds of the arrival
+	Hashes   []TxAnnounceHash `json:"hashes"`
+	Geo      *geoip.Info      `json:"geo,omitempty" rlp:"optional"`
+}
+
+// TxAnnounceHash is the fetch outcome of a single announced hash.
+type TxAnnounceHash struct {
+	Hash        string `json:"hash"`
+	Fetched     string `json:"fetched"`     // FetchedSelf, FetchedOther or FetchedNone
+	FetchedFrom string `json:"fetchedfrom"` // peer the body was retrieved from
+	Delay       uint64 `json:"delay"`       // nanoseconds between announcement and retrieval
+}
+
+func (t *TxAnnounceRecordInfo) Channel() string {
+	return ChanTxAnnounceID
+}
+
+func (t *TxAnnounceRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(t)
+}
+
+func (t *TxAnnounceRecordInfo) Kind() Kind {
+	return KindTxAnnounce
+}
+
+func (t *TxAnnounceRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, t)
+}
+
+// Ways a peer can make a block known to us.
+const (
+	AnnounceHash  = "announce" // hash announced before, or instead of, the block
+	AnnounceBlock = "push"     // block pushed without a prior announcement
+)
+
+// BlockAnnounceRecordInfo is the first arrival of a block from a peer, either
+// as a NewBlockHashes announcement or as a pushed NewBlock. A NewBlock
+// following the same peer's announcement is folded into the announcement.
+type BlockAnnounceRecordInfo struct {
+	PeerId    string      `json:"peerid"`
+	PeerAddr  string      `json:"peeraddr"`
+	Hash      string      `json:"hash"`
+	Number    uint64      `json:"number"`
+	Time      uint64      `json:"time"`      // unix nanoseconds of the arrival
+	Mode      string      `json:"mode"`      // AnnounceHash or AnnounceBlock
+	Pushed    bool        `json:"pushed"`    // announced block later pushed by the same peer
+	PushDelay uint64      `json:"pushdelay"` // nanoseconds between announcement and push
+	Geo       *geoip.Info `json:"geo,omitempty" rlp:"optional"`
+}
+
+func (b *BlockAnnounceRecordInfo) Channel() string {
+	return ChanBlockAnnounceID
+}
+
+func (b *BlockAnnounceRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(b)
+}
+
+func (b *BlockAnnounceRecordInfo) Kind() Kind {
+	return KindBlockAnnounce
+}
+
+func (b *BlockAnnounceRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, b)
 }
 
+// PeerStatusRecordInfo is the eth status handshake of a remote peer, recorded
+// for accepted and rejected peers alike.
+type PeerStatusRecordInfo struct {
+	PeerId          string      `json:"peerid"`
+	Enode           string      `json:"enode"`
+	PeerAddr        string      `json:"peeraddr"`
+	Name            string      `json:"name"` // client name as reported in the RLPx hello
+	Caps            []string    `json:"caps"`
+	Inbound         bool        `json:"inbound"`
+	ProtocolVersion uint32      `json:"protocolversion"`
+	NetworkID       uint64      `json:"networkid"`
+	TD              string      `json:"td"` // decimal total difficulty
+	Head            string      `json:"head"`
+	Genesis         string      `json:"genesis"`
+	ForkHash        string      `json:"forkhash"`
+	ForkNext        uint64      `json:"forknext"`
+	Accepted        bool        `json:"accepted"`
+	Error           string      `json:"error"`    // reason the peer was rejected
+	Duration        uint64      `json:"duration"` // nanoseconds the handshake took
+	Time            uint64      `json:"time"`     // unix nanoseconds the status arrived
+	Network         string      `json:"network"`  // name of the matched network, empty if rejected
+	Client          string      `json:"client"`   // client parsed from the name, empty if unparseable
+	ClientVersion   string      `json:"clientversion"`
+	ClientBuild     string      `json:"clientbuild"`
+	OS              string      `json:"os"`
+	Arch            string      `json:"arch"`
+	Runtime         string      `json:"runtime"`
+	Geo             *geoip.Info `json:"geo,omitempty" rlp:"optional"`
+}
+
+func (p *PeerStatusRecordInfo) Channel() string {
+	return ChanPeerStatusID
+}
+
+func (p *PeerStatusRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(p)
+}
+
+func (p *PeerStatusRecordInfo) Kind() Kind {
+	return KindPeerStatus
+}
+
+func (p *PeerStatusRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, p)
+}
+
+// Events of a peer session.
+const (
+	SessionConnected    = "connected"
+	SessionDisconnected = "disconnected"
+)
+
+// MessageCount is the number of messages of a code received from a peer.
+type MessageCount struct {
+	Code  uint64 `json:"code"`
+	Count uint64 `json:"count"`
+}
+
+// PeerSessionRecordInfo is the start or the end of an eth session with a peer.
+// The traffic and message fields are only filled in at the end.
+type PeerSessionRecordInfo struct {
+	PeerId          string         `json:"peerid"`
+	Enode           string         `json:"enode"`
+	PeerAddr        string         `json:"peeraddr"`
+	Name            string         `json:"name"`
+	Inbound         bool           `json:"inbound"`
+	Network         string         `json:"network"`
+	Event           string         `json:"event"`           // connected or disconnected
+	Reason          string         `json:"reason"`          // p2p disconnect reason
+	RemoteRequested bool           `json:"remoterequested"` // whether the peer asked for the disconnect
+	Duration        uint64         `json:"duration"`        // nanoseconds the session lasted
+	Messages        []MessageCount `json:"messages"`        // messages received by code
+	BytesIn         uint64         `json:"bytesin"`
+	BytesOut        uint64         `json:"bytesout"`
+	Head            string         `json:"head"` // last head announced by the peer
+	TD              string         `json:"td"`   // decimal total difficulty of the head
+	Time            uint64         `json:"time"` // unix nanoseconds of the event
+	Geo             *geoip.Info    `json:"geo,omitempty" rlp:"optional"`
+}
+
+func (p *PeerSessionRecordInfo) Channel() string {
+	return ChanPeerSessionID
+}
+
+func (p *PeerSessionRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(p)
+}
+
+func (p *PeerSessionRecordInfo) Kind() Kind {
+	return KindPeerSession
+}
+
+func (p *PeerSessionRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, p)
+}
+
+// NodeCrawlRecordInfo is the result of dialing a node found by the crawler:
+// the RLPx hello and, if the node speaks eth, its status. The connection is
+// closed right after the status arrived.
+type NodeCrawlRecordInfo struct {
+	PeerId          string      `json:"peerid"`
+	Enode           string      `json:"enode"`
+	PeerAddr        string      `json:"peeraddr"`
+	Seq             uint64      `json:"seq"`  // sequence number of the dialed node record
+	Name            string      `json:"name"` // client name as reported in the RLPx hello
+	Caps            []string    `json:"caps"`
+	ListenPort      uint64      `json:"listenport"`
+	ProtocolVersion uint32      `json:"protocolversion"` // zero if no status was received
+	NetworkID       uint64      `json:"networkid"`
+	TD              string      `json:"td"` // decimal total difficulty
+	Head            string      `json:"head"`
+	Genesis         string      `json:"genesis"`
+	ForkHash        string      `json:"forkhash"`
+	ForkNext        uint64      `json:"forknext"`
+	Client          string      `json:"client"` // client parsed from the name, empty if unparseable
+	ClientVersion   string      `json:"clientversion"`
+	Error           string      `json:"error"`    // reason the dial, hello or status failed
+	Duration        uint64      `json:"duration"` // nanoseconds from dial to disconnect
+	Time            uint64      `json:"time"`     // unix nanoseconds the dial started
+	Geo             *geoip.Info `json:"geo,omitempty" rlp:"optional"`
+}
+
+func (n *NodeCrawlRecordInfo) Channel() string {
+	return ChanNodeCrawlID
+}
+
+func (n *NodeCrawlRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(n)
+}
+
+func (n *NodeCrawlRecordInfo) Kind() Kind {
+	return KindNodeCrawl
+}
+
+func (n *NodeCrawlRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, n)
+}
+
+// Events of a crawled node set.
+const (
+	NodeAdded   = "added"
+	NodeRemoved = "removed"
+)
+
+// NodeEventRecordInfo is the addition of a node to the set maintained by the
+// crawler, or its removal after it stopped answering.
+type NodeEventRecordInfo struct {
+	PeerId        string      `json:"peerid"`
+	Enode         string      `json:"enode"`
+	PeerAddr      string      `json:"peeraddr"` // discovery endpoint of the node
+	Seq           uint64      `json:"seq"`
+	Event         string      `json:"event"` // added or removed
+	Score         uint64      `json:"score"`
+	FirstResponse uint64      `json:"firstresponse"` // unix nanoseconds, zero if the node never answered
+	LastResponse  uint64      `json:"lastresponse"`
+	Time          uint64      `json:"time"` // unix nanoseconds of the event
+	Geo           *geoip.Info `json:"geo,omitempty" rlp:"optional"`
+}
+
+func (n *NodeEventRecordInfo) Channel() string {
+	return ChanNodeEventID
+}
+
+func (n *NodeEventRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(n)
+}
+
+func (n *NodeEventRecordInfo) Kind() Kind {
+	return KindNodeEvent
+}
+
+func (n *NodeEventRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, n)
+}
+
+// DiscPacketRecordInfo is an inbound discovery packet, as seen by the sampled
+// discovery tap.
+type DiscPacketRecordInfo struct {
+	PeerId   string      `json:"peerid"` // sender node ID, empty if the packet didn't reveal it
+	PeerAddr string      `json:"peeraddr"`
+	Protocol string      `json:"protocol"` // discv4 or discv5
+	Packet   string      `json:"packet"`   // packet type, e.g. PING/v4
+	Seq      uint64      `json:"seq"`      // ENR sequence number claimed by the sender, zero if none
+	Size     uint64      `json:"size"`
+	Error    string      `json:"error"`    // reason the packet was rejected
+	Duration uint64      `json:"duration"` // nanoseconds spent decoding and handling the packet
+	Time     uint64      `json:"time"`     // unix nanoseconds the packet was received
+	Geo      *geoip.Info `json:"geo,omitempty" rlp:"optional"`
+}
+
+func (d *DiscPacketRecordInfo) Channel() string {
+	return ChanDiscPacketID
+}
+
+func (d *DiscPacketRecordInfo) Encode() ([]byte,error)  {
+	return json.Marshal(d)
+}
+
+func (d *DiscPacketRecordInfo) Kind() Kind {
+	return KindDiscPacket
+}
+
+func (d *DiscPacketRecordInfo) Decode(data []byte) error {
+	return json.Unmarshal(data, d)
+}
@@ -0,0 +1,63 @@
+package record
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func init() {
+	RegisterSink("redis", openRedisSink)
+}
+
+// redisSink publishes every record on the redis pub/sub channel of its kind.
+type redisSink struct {
+	client *redis.Client
+}
+
+func openRedisSink(u *url.URL) (Sink, error) {
+	opts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, err
+	}
+	opts.PoolSize = 100
+	return NewRedisSink(redis.NewClient(opts)), nil
+}
+
+// NewRedisSink creates a sink publishing over an existing redis client.
+func NewRedisSink(client *redis.Client) Sink {
+	return &redisSink{client: client}
+}
+
+func (s *redisSink) Publish(rec Record) error {
+	data, err := rec.Encode()
+	if err != nil {
+		return Permanent(err)
+	}
+	return s.client.Publish(context.Background(), rec.Channel(), string(data)).Err()
+}
+
+// PublishBatch pipelines the publication of several records into a single
+// round trip to the server.
+func (s *redisSink) PublishBatch(recs []Record) error {
+	ctx := context.Background()
+	pipe := s.client.Pipeline()
+	for _, rec := range recs {
+		data, err := rec.Encode()
+		if err != nil {
+			return Permanent(err)
+		}
+		pipe.Publish(ctx, rec.Channel(), string(data))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisSink) Flush() error {
+	return nil
+}
+
+func (s *redisSink) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,249 @@
+package record
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+
+	"peerInfoCollect/log"
+	"peerInfoCollect/p2p/geoip"
+)
+
+// Record is a single observation produced by the collector. Every record knows
+// the channel it belongs to and how to serialize itself.
+type Record interface {
+	Channel() string
+	Encode() ([]byte, error)
+}
+
+// Sink is a destination for collected observations. Implementations must be
+// safe for concurrent use, the eth handlers publish from every peer goroutine.
+type Sink interface {
+	// Publish delivers a single block, transaction or peer observation.
+	Publish(rec Record) error
+
+	// Flush blocks until all previously published records have been handed
+	// over to the backend.
+	Flush() error
+
+	// Close flushes and releases all resources held by the sink.
+	Close() error
+}
+
+// permanentError marks a record the sink will never accept.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a permanent rejection of a record, e.g. because it
+// can't be encoded for the backend. Such records are not retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// IsPermanent reports whether err rejects a record permanently rather than
+// signalling that the backend is unreachable.
+func IsPermanent(err error) bool {
+	var perr *permanentError
+	return errors.As(err, &perr)
+}
+
+// SinkOpener creates a sink from its URL representation.
+type SinkOpener func(u *url.URL) (Sink, error)
+
+var (
+	errUnknownSink = errors.New("unknown record sink")
+	errSinkClosed  = errors.New("record sink closed")
+
+	openersLock sync.RWMutex
+	openers     = make(map[string]SinkOpener)
+)
+
+// RegisterSink makes a sink implementation available under the given URL
+// scheme. It panics if the scheme is already taken.
+func RegisterSink(scheme string, opener SinkOpener) {
+	openersLock.Lock()
+	defer openersLock.Unlock()
+
+	if _, ok := openers[scheme]; ok {
+		panic(fmt.Sprintf("record sink %q already registered", scheme))
+	}
+	openers[scheme] = opener
+}
+
+// Sinks returns the sorted list of registered sink schemes.
+func Sinks() []string {
+	openersLock.RLock()
+	defer openersLock.RUnlock()
+
+	schemes := make([]string, 0, len(openers))
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// OpenSink creates a sink from a URL, selecting the implementation by the URL
+// scheme, e.g. redis://host:6379/0, mongodb://host:27017/db or file:///path.
+func OpenSink(rawurl string) (Sink, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid record sink %q: %v", rawurl, err)
+	}
+	openersLock.RLock()
+	opener, ok := openers[u.Scheme]
+	openersLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnknownSink, u.Scheme)
+	}
+	return opener(u)
+}
+
+// multiSink fans every call out to a list of sinks.
+type multiSink []Sink
+
+// NewMultiSink creates a sink that delivers every record to all given sinks.
+func NewMultiSink(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) Publish(rec Record) error {
+	var first error
+	for _, s := range m {
+		if err := s.Publish(rec); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (m multiSink) PublishBatch(recs []Record) error {
+	var first error
+	for _, s := range m {
+		if err := publishBatch(s, recs); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (m multiSink) Flush() error {
+	var first error
+	for _, s := range m {
+		if err := s.Flush(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (m multiSink) Close() error {
+	var first error
+	for _, s := range m {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+var (
+	activeLock sync.RWMutex
+	active     Sink = multiSink(nil)
+)
+
+// Setup opens a sink for each URL and installs the fan-out of all of them as
+// the process wide destination for Publish. Any previously installed sinks
+// are closed.
+func Setup(urls ...string) error {
+	sinks, err := openSinks(urls, nil)
+	if err != nil {
+		return err
+	}
+	SetSink(NewMultiSink(sinks...))
+	return nil
+}
+
+// openSinks opens a sink for each URL, optionally wrapping every one of them.
+// Either all sinks are opened or none.
+func openSinks(urls []string, wrap func(rawurl string, s Sink) (Sink, error)) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(urls))
+	for _, rawurl := range urls {
+		s, err := OpenSink(rawurl)
+		if err == nil && wrap != nil {
+			var wrapped Sink
+			if wrapped, err = wrap(rawurl, s); err != nil {
+				s.Close()
+			}
+			s = wrapped
+		}
+		if err != nil {
+			for _, opened := range sinks {
+				opened.Close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// SetSink installs the process wide destination for Publish, closing the
+// previous one.
+func SetSink(s Sink) {
+	if s == nil {
+		s = multiSink(nil)
+	}
+	activeLock.Lock()
+	prev := active
+	active = s
+	activeLock.Unlock()
+
+	if err := prev.Close(); err != nil {
+		log.Warn("Failed to close record sink", "err", err)
+	}
+}
+
+// Publish delivers a record to the installed sinks and the feed subscribers,
+// attaching the location of the peer it was observed from.
+func Publish(rec Record) error {
+	locate(rec)
+
+	activeLock.RLock()
+	err := active.Publish(rec)
+	activeLock.RUnlock()
+
+	deliver(rec)
+	return err
+}
+
+// Flush flushes the installed sinks.
+func Flush() error {
+	activeLock.RLock()
+	defer activeLock.RUnlock()
+
+	return active.Flush()
+}
+
+// Close closes the installed sinks and the location databases and reverts to
+// discarding records.
+func Close() error {
+	activeLock.Lock()
+	prev := active
+	active = multiSink(nil)
+	activeLock.Unlock()
+
+	err := prev.Close()
+	if geoErr := geoip.Close(); err == nil {
+		err = geoErr
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSink(t *testing.T) {
+	for _, scheme := range []string{"file", "memory", "mongodb", "redis"} {
+		found := false
+		for _, s := range Sinks() {
+			found = found || s == scheme
+		}
+		if !found {
+			t.Errorf("sink %q not registered", scheme)
+		}
+	}
+	if _, err := OpenSink("nosuch://host"); !errors.Is(err, errUnknownSink) {
+		t.Fatalf("wrong error for unknown sink: %v", err)
+	}
+	s, err := OpenSink("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*MemorySink); !ok {
+		t.Fatalf("wrong sink type %T", s)
+	}
+}
+
+func TestMultiSink(t *testing.T) {
+	var (
+		a = NewMemorySink()
+		b = NewMemorySink()
+		m = NewMultiSink(a, b)
+	)
+	m.Publish(&BlockRecordInfo{BlockNum: 1})
+	m.Publish(&TxRecordInfo{TxHash: "0x01"})
+	m.Publish(&PeerRecordInfo{PeerId: "aa"})
+
+	for i, s := range []*MemorySink{a, b} {
+		if n := len(s.Records()); n != 3 {
+			t.Errorf("sink %d: have %d records, want 3", i, n)
+		}
+		for _, ch := range []string{ChanBlockID, ChanTxID, ChanPeerID} {
+			if n := len(s.Channel(ch)); n != 1 {
+				t.Errorf("sink %d: have %d records on %s, want 1", i, n, ch)
+			}
+		}
+	}
+	// Closing the fan-out must close every member, a failing member must
+	// not prevent delivery to the others.
+	a.Close()
+	if err := m.Publish(&TxRecordInfo{}); err != errSinkClosed {
+		t.Fatalf("wrong publish error: %v", err)
+	}
+	if n := len(b.Records()); n != 4 {
+		t.Fatalf("have %d records, want 4", n)
+	}
+	m.Close()
+	if err := b.Publish(&TxRecordInfo{}); err != errSinkClosed {
+		t.Fatalf("sink not closed by fan-out")
+	}
+}
+
+func TestSetupPublish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	if err := Setup("memory://", "file://"+path); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	mem := active.(multiSink)[0].(*MemorySink)
+	if err := Publish(&BlockRecordInfo{BlockNum: 42, BlockHash: "0xaa"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mem.Channel(ChanBlockID)); n != 1 {
+		t.Fatalf("have %d block records, want 1", n)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var (
+		lines = bufio.NewScanner(f)
+		entry fileEntry
+		block BlockRecordInfo
+	)
+	if !lines.Scan() {
+		t.Fatal("file sink wrote nothing")
+	}
+	if err := json.Unmarshal(lines.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	block.Decode(entry.Record)
+	if entry.Channel != ChanBlockID || block.BlockNum != 42 || block.BlockHash != "0xaa" {
+		t.Fatalf("wrong file entry: %s", lines.Bytes())
+	}
+	// Once closed, records are silently discarded.
+	Close()
+	if err := Publish(&TxRecordInfo{}); err != nil {
+		t.Fatalf("publish after close failed: %v", err)
+	}
+}
@@ -0,0 +1,728 @@
+package record
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"peerInfoCollect/log"
+)
+
+const (
+	spoolSegmentExt    = ".seg"     // File extension of the spool segments
+	spoolCursorFile    = "CURSOR"   // File holding the replay progress
+	spoolRejectedFile  = "REJECTED" // Dead-letter file of records the sink refused
+	spoolCorruptFile   = "CORRUPT"  // Segment remainders following a corrupt entry
+	spoolEntryHeader   = 8          // Length and checksum preceding every entry
+	spoolReplayBatch   = 256        // Maximum number of entries replayed at once
+	spoolReplayRetries = 5          // Failed replays after which the head entry is probed
+)
+
+// spoolRetryBackoff is the time to wait before retrying a failed replay.
+var spoolRetryBackoff = 3 * time.Second
+
+var errSpoolCorrupt = errors.New("corrupt spool entry")
+
+// SpoolConfig configures the on-disk spool of a sink.
+type SpoolConfig struct {
+	Dir         string        // Directory holding the segment files
+	SegmentSize int64         // Size after which a new segment file is started
+	MaxSize     int64         // Total size after which the oldest segments are dropped
+	MaxAge      time.Duration // Age after which segments are dropped, zero keeps them forever
+}
+
+// spoolCursor is the position of the next entry to replay.
+type spoolCursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// rawRecord is a record read back from the spool.
+type rawRecord struct {
+	channel string
+	data    []byte
+}
+
+func (r *rawRecord) Channel() string         { return r.channel }
+func (r *rawRecord) Encode() ([]byte, error) { return r.data, nil }
+
+// spoolSink keeps records the wrapped sink failed to accept in a write-ahead
+// log of segment files and replays them in order once the sink recovers.
+// While a backlog exists, new records are appended to the spool as well, so
+// the sink sees them in their original order.
+type spoolSink struct {
+	sink Sink
+	cfg  SpoolConfig
+
+	lock     sync.Mutex
+	head     uint64 // Number of the oldest segment on disk
+	tail     uint64 // Number of the segment being appended to
+	tailFile *os.File
+	tailSize int64
+	sizes    map[uint64]int64 // Sizes of all segments on disk
+	size     int64            // Total size of all segments
+	cursor   spoolCursor
+	backlog  bool
+
+	rejected     *os.File // Dead-letter file, opened on first use
+	rejectedSize int64
+
+	failures  int         // Consecutive failed replays at failedAt
+	failedAt  spoolCursor // Replay position of the last failure
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewSpoolSink wraps a sink with a durable on-disk spool in cfg.Dir. Records
+// left over from a previous run are replayed in the background.
+func NewSpoolSink(sink Sink, cfg SpoolConfig) (Sink, error) {
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = 64 * 1024 * 1024
+	}
+	// Only whole segments are dropped to enforce the size cap, keep them
+	// small enough for the cap to hold.
+	if cfg.MaxSize > 0 && cfg.SegmentSize > cfg.MaxSize/4 {
+		cfg.SegmentSize = cfg.MaxSize / 4
+		if cfg.SegmentSize == 0 {
+			cfg.SegmentSize = 1
+		}
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &spoolSink{
+		sink:   sink,
+		cfg:    cfg,
+		sizes:  make(map[uint64]int64),
+		closed: make(chan struct{}),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s, nil
+}
+
+// open loads the segments and the replay cursor from disk, truncating any
+// partially written entry at the end of the last segment.
+func (s *spoolSink) open() error {
+	files, err := ioutil.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	var segments []uint64
+	for _, f := range files {
+		var n uint64
+		if !strings.HasSuffix(f.Name(), spoolSegmentExt) {
+			continue
+		}
+		if _, err := fmt.Sscanf(f.Name(), "%d"+spoolSegmentExt, &n); err != nil {
+			continue
+		}
+		segments = append(segments, n)
+		s.sizes[n] = f.Size()
+		s.size += f.Size()
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	if len(segments) > 0 {
+		s.head, s.tail = segments[0], segments[len(segments)-1]
+	}
+	// Drop the torn write of a crash, if any
+	valid, err := validLength(s.segmentPath(s.tail), s.sizes[s.tail])
+	if err != nil {
+		return err
+	}
+	if valid != s.sizes[s.tail] {
+		log.Warn("Truncating corrupt record spool", "segment", s.tail, "size", s.sizes[s.tail], "valid", valid)
+		s.size -= s.sizes[s.tail] - valid
+		s.sizes[s.tail] = valid
+	}
+	s.tailFile, err = os.OpenFile(s.segmentPath(s.tail), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if err := s.tailFile.Truncate(s.sizes[s.tail]); err != nil {
+		return err
+	}
+	if _, err := s.tailFile.Seek(s.sizes[s.tail], io.SeekStart); err != nil {
+		return err
+	}
+	s.tailSize = s.sizes[s.tail]
+
+	// Resume replaying where the previous run stopped
+	s.cursor = spoolCursor{Segment: s.head}
+	if blob, err := ioutil.ReadFile(filepath.Join(s.cfg.Dir, spoolCursorFile)); err == nil {
+		var cursor spoolCursor
+		if err := json.Unmarshal(blob, &cursor); err != nil {
+			log.Warn("Invalid record spool cursor, replaying everything", "err", err)
+		} else if cursor.Segment >= s.head && cursor.Segment <= s.tail && cursor.Offset <= s.sizes[cursor.Segment] {
+			s.cursor = cursor
+		}
+	}
+	s.backlog = !s.drained()
+	if s.backlog {
+		log.Info("Replaying spooled records", "dir", s.cfg.Dir, "size", s.size)
+	}
+	return nil
+}
+
+// validLength returns the length of the prefix of a segment made up of
+// complete, uncorrupted entries.
+func validLength(path string, size int64) (int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var offset int64
+	r := io.LimitReader(f, size)
+	for {
+		_, n, err := readEntry(r, size-offset)
+		if err != nil {
+			return offset, nil
+		}
+		offset += n
+	}
+}
+
+func (s *spoolSink) segmentPath(n uint64) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("%08d%s", n, spoolSegmentExt))
+}
+
+// drained reports whether the cursor caught up with the end of the spool.
+// The lock must be held.
+func (s *spoolSink) drained() bool {
+	return s.cursor.Segment == s.tail && s.cursor.Offset == s.tailSize
+}
+
+func (s *spoolSink) Publish(rec Record) error {
+	s.lock.Lock()
+	if s.backlog {
+		defer s.lock.Unlock()
+		return s.append(rec)
+	}
+	s.lock.Unlock()
+
+	if err := s.sink.Publish(rec); err != nil {
+		if IsPermanent(err) {
+			s.reject(rec, err)
+			return err
+		}
+		return s.spool([]Record{rec}, err)
+	}
+	return nil
+}
+
+func (s *spoolSink) PublishBatch(recs []Record) error {
+	s.lock.Lock()
+	if s.backlog {
+		defer s.lock.Unlock()
+		for _, rec := range recs {
+			if err := s.append(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s.lock.Unlock()
+
+	err := publishBatch(s.sink, recs)
+	if err == nil {
+		return nil
+	}
+	if !IsPermanent(err) {
+		return s.spool(recs, err)
+	}
+	// Some record was refused, find out which by delivering them one by one
+	for i, rec := range recs {
+		if err := s.sink.Publish(rec); err != nil {
+			if IsPermanent(err) {
+				s.reject(rec, err)
+				continue
+			}
+			return s.spool(recs[i:], err)
+		}
+	}
+	return err
+}
+
+// spool appends records the sink refused and switches to backlog mode.
+func (s *spoolSink) spool(recs []Record, cause error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.backlog {
+		log.Warn("Record sink unavailable, spooling to disk", "dir", s.cfg.Dir, "err", cause)
+		s.backlog = true
+	}
+	for _, rec := range recs {
+		if err := s.append(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reject moves a record the sink refused to the dead-letter file, unless that
+// would grow it beyond the size cap of the spool.
+func (s *spoolSink) reject(rec Record, cause error) {
+	spoolRejectMeter.Mark(1)
+	log.Debug("Record rejected by sink", "channel", rec.Channel(), "err", cause)
+
+	entry, err := encodeEntry(rec)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.cfg.MaxSize > 0 && s.rejectedSize+int64(len(entry)) > s.cfg.MaxSize {
+		return
+	}
+	if s.rejected == nil {
+		f, err := os.OpenFile(filepath.Join(s.cfg.Dir, spoolRejectedFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Warn("Failed to open rejected record file", "err", err)
+			return
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			log.Warn("Failed to open rejected record file", "err", err)
+			return
+		}
+		s.rejected, s.rejectedSize = f, info.Size()
+		log.Warn("Record sink rejected a record, keeping it aside", "file", f.Name(), "err", cause)
+	}
+	if _, err := s.rejected.Write(entry); err != nil {
+		log.Warn("Failed to write rejected record", "err", err)
+		return
+	}
+	s.rejectedSize += int64(len(entry))
+}
+
+// encodeEntry frames a record as a spool entry.
+func encodeEntry(rec Record) ([]byte, error) {
+	data, err := rec.Encode()
+	if err != nil {
+		return nil, err
+	}
+	channel := rec.Channel()
+	if len(channel) > 255 {
+		return nil, fmt.Errorf("channel name too long: %q", channel)
+	}
+	body := make([]byte, 0, 1+len(channel)+len(data))
+	body = append(body, byte(len(channel)))
+	body = append(body, channel...)
+	body = append(body, data...)
+
+	entry := make([]byte, spoolEntryHeader, spoolEntryHeader+len(body))
+	binary.BigEndian.PutUint32(entry[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(entry[4:8], crc32.ChecksumIEEE(body))
+	return append(entry, body...), nil
+}
+
+// append writes a single record to the end of the spool. The lock must be held.
+func (s *spoolSink) append(rec Record) error {
+	entry, err := encodeEntry(rec)
+	if err != nil {
+		return err
+	}
+	if s.tailSize > 0 && s.tailSize+int64(len(entry)) > s.cfg.SegmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.tailFile.Write(entry); err != nil {
+		return err
+	}
+	s.tailSize += int64(len(entry))
+	s.sizes[s.tail] = s.tailSize
+	s.size += int64(len(entry))
+	spoolSizeGauge.Update(s.size)
+
+	// Enforce the size cap by dropping the oldest data
+	for s.cfg.MaxSize > 0 && s.size > s.cfg.MaxSize && s.head < s.tail {
+		log.Warn("Record spool full, dropping oldest segment", "segment", s.head, "size", s.sizes[s.head])
+		spoolDropMeter.Mark(1)
+		s.dropHead()
+	}
+	return nil
+}
+
+// rotate starts a new tail segment. The lock must be held.
+func (s *spoolSink) rotate() error {
+	if err := s.tailFile.Sync(); err != nil {
+		return err
+	}
+	if err := s.tailFile.Close(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.segmentPath(s.tail+1), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.tail++
+	s.tailFile, s.tailSize = f, 0
+	s.sizes[s.tail] = 0
+	return nil
+}
+
+// dropHead deletes the oldest segment, moving the cursor past it if needed.
+// The lock must be held and the head must not be the tail.
+func (s *spoolSink) dropHead() {
+	if err := os.Remove(s.segmentPath(s.head)); err != nil {
+		log.Warn("Failed to remove spool segment", "segment", s.head, "err", err)
+	}
+	s.size -= s.sizes[s.head]
+	delete(s.sizes, s.head)
+	s.head++
+	if s.cursor.Segment < s.head {
+		s.cursor = spoolCursor{Segment: s.head}
+		s.saveCursor()
+	}
+	spoolSizeGauge.Update(s.size)
+}
+
+// saveCursor atomically persists the replay cursor. The lock must be held.
+func (s *spoolSink) saveCursor() {
+	blob, _ := json.Marshal(&s.cursor)
+	tmp := filepath.Join(s.cfg.Dir, spoolCursorFile+".tmp")
+	if err := ioutil.WriteFile(tmp, blob, 0644); err != nil {
+		log.Warn("Failed to save spool cursor", "err", err)
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(s.cfg.Dir, spoolCursorFile)); err != nil {
+		log.Warn("Failed to save spool cursor", "err", err)
+	}
+}
+
+func (s *spoolSink) Flush() error {
+	s.lock.Lock()
+	err := s.tailFile.Sync()
+	s.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return s.sink.Flush()
+}
+
+// Close stops replaying and closes the spool files and the wrapped sink. Only
+// the first call has any effect.
+func (s *spoolSink) Close() error {
+	err := errSinkClosed
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.wg.Wait()
+
+		s.lock.Lock()
+		s.saveCursor()
+		err = s.tailFile.Close()
+		if s.rejected != nil {
+			s.rejected.Close()
+		}
+		s.lock.Unlock()
+
+		if cerr := s.sink.Close(); cerr != nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+// loop replays the backlog whenever there is one and applies retention.
+func (s *spoolSink) loop() {
+	defer s.wg.Done()
+
+	retry := time.NewTimer(0)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-retry.C:
+			s.expire()
+
+			delay := spoolRetryBackoff
+			if progress, err := s.replay(); err != nil {
+				log.Debug("Record spool replay failed", "err", err)
+			} else if progress {
+				delay = 0
+			}
+			retry.Reset(delay)
+
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// expire drops segments older than the retention limit.
+func (s *spoolSink) expire() {
+	if s.cfg.MaxAge <= 0 {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for s.head < s.tail {
+		info, err := os.Stat(s.segmentPath(s.head))
+		if err != nil || time.Since(info.ModTime()) < s.cfg.MaxAge {
+			return
+		}
+		log.Warn("Dropping expired record spool segment", "segment", s.head, "age", time.Since(info.ModTime()))
+		spoolDropMeter.Mark(1)
+		s.dropHead()
+	}
+}
+
+// replay publishes the next batch of spooled records. It reports whether any
+// progress was made.
+func (s *spoolSink) replay() (bool, error) {
+	s.lock.Lock()
+	if !s.backlog {
+		s.lock.Unlock()
+		return false, nil
+	}
+	if s.drained() {
+		// Everything was replayed, start over with a fresh segment
+		s.backlog = false
+		if s.tailSize > 0 {
+			if err := s.rotate(); err != nil {
+				s.backlog = true
+				s.lock.Unlock()
+				return false, err
+			}
+			s.cursor = spoolCursor{Segment: s.tail}
+		}
+		for s.head < s.tail {
+			s.dropHead()
+		}
+		s.saveCursor()
+		s.lock.Unlock()
+
+		log.Info("Record spool replayed, sink recovered", "dir", s.cfg.Dir)
+		return false, nil
+	}
+	cursor := s.cursor
+	if cursor.Offset >= s.sizes[cursor.Segment] && cursor.Segment < s.tail {
+		// Segment exhausted, move on to the next one
+		s.cursor = spoolCursor{Segment: cursor.Segment + 1}
+		if s.head == cursor.Segment {
+			s.dropHead()
+		}
+		s.saveCursor()
+		s.lock.Unlock()
+		return true, nil
+	}
+	limit := s.sizes[cursor.Segment]
+	next, nextLimit := cursor.Segment < s.tail, s.sizes[cursor.Segment+1]
+	s.lock.Unlock()
+
+	// Read and deliver the next batch of entries outside of the lock. The
+	// entries preceding a corrupt one are delivered first.
+	recs, ends, err := s.readEntries(cursor, limit, spoolReplayBatch)
+	if errors.Is(err, errSpoolCorrupt) && len(recs) == 0 {
+		s.setAsideCorrupt(cursor, limit)
+		return true, nil
+	}
+	if len(recs) == 0 {
+		return false, err
+	}
+	if err := publishBatch(s.sink, recs); err == nil {
+		spoolReplayMeter.Mark(int64(len(recs)))
+		s.failures = 0
+		s.advance(cursor, ends[len(ends)-1])
+		return true, nil
+	}
+	// Deliver one by one, setting aside the records the sink refuses. A head
+	// entry ending its segment is probed with the first one of the next.
+	probe := s.failedRepeatedly(cursor)
+	if probe && len(recs) == 1 && next {
+		more, moreEnds, err := s.readEntries(spoolCursor{Segment: cursor.Segment + 1}, nextLimit, 1)
+		if err == nil {
+			recs, ends = append(recs, more...), append(ends, moreEnds...)
+		}
+	}
+	end, err := s.replaySingly(recs, ends, cursor, probe)
+	if end == cursor {
+		return false, err
+	}
+	s.failures = 0
+	s.advance(cursor, end)
+	return true, nil
+}
+
+// readEntries reads up to max entries of a segment, starting at cursor and
+// ending at limit. It returns the position after each entry. If a corrupt
+// entry is hit, the entries before it are returned along with the error.
+func (s *spoolSink) readEntries(cursor spoolCursor, limit int64, max int) ([]Record, []spoolCursor, error) {
+	f, err := os.Open(s.segmentPath(cursor.Segment))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(cursor.Offset, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	var (
+		r    = io.LimitReader(f, limit-cursor.Offset)
+		recs []Record
+		ends []spoolCursor
+	)
+	for len(recs) < max {
+		rec, n, err := readEntry(r, limit-cursor.Offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return recs, ends, fmt.Errorf("segment %d offset %d: %w", cursor.Segment, cursor.Offset, err)
+		}
+		cursor.Offset += n
+		recs, ends = append(recs, rec), append(ends, cursor)
+	}
+	return recs, ends, nil
+}
+
+// setAsideCorrupt moves the rest of a segment, from a corrupt entry at cursor
+// up to limit, to the corrupt file and continues replaying after it. The
+// corrupt file doesn't grow beyond the size cap of the spool.
+func (s *spoolSink) setAsideCorrupt(cursor spoolCursor, limit int64) {
+	spoolCorruptMeter.Mark(1)
+	path := filepath.Join(s.cfg.Dir, spoolCorruptFile)
+	log.Warn("Corrupt record spool entry, setting the rest of the segment aside", "segment", cursor.Segment, "offset", cursor.Offset, "size", limit-cursor.Offset, "file", path)
+
+	if err := copySegmentRange(s.segmentPath(cursor.Segment), path, cursor.Offset, limit, s.cfg.MaxSize); err != nil {
+		log.Warn("Failed to set corrupt spool entries aside", "err", err)
+	}
+	s.advance(cursor, spoolCursor{Segment: cursor.Segment, Offset: limit})
+}
+
+// copySegmentRange appends the bytes from offset to limit of a segment to the
+// file at dst, unless that would grow it beyond max (if positive).
+func copySegmentRange(src, dst string, offset, limit, max int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+	if max > 0 && info.Size()+limit-offset > max {
+		return errors.New("corrupt file full")
+	}
+	_, err = io.CopyN(out, in, limit-offset)
+	return err
+}
+
+// advance moves the replay cursor from cursor to end, dropping the head
+// segment if end lies beyond it.
+func (s *spoolSink) advance(cursor, end spoolCursor) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The size cap may have dropped the segment meanwhile, keep the cursor
+	if s.cursor != cursor {
+		return
+	}
+	s.cursor = end
+	if end.Segment > cursor.Segment && s.head == cursor.Segment {
+		s.dropHead()
+	}
+	s.saveCursor()
+}
+
+// failedRepeatedly counts a failed replay at cursor and reports whether the
+// replays from there failed too often to blame an unreachable sink alone.
+func (s *spoolSink) failedRepeatedly(cursor spoolCursor) bool {
+	if s.failedAt != cursor {
+		s.failedAt, s.failures = cursor, 0
+	}
+	s.failures++
+	return s.failures >= spoolReplayRetries
+}
+
+// replaySingly publishes spooled records one at a time, returning the position
+// up to which they were delivered or set aside. Records refused permanently
+// are moved to the dead-letter file. When probing, a head record the sink
+// fails to take while accepting its successor is set aside as well.
+func (s *spoolSink) replaySingly(recs []Record, ends []spoolCursor, cursor spoolCursor, probe bool) (spoolCursor, error) {
+	for i := 0; i < len(recs); i++ {
+		err := s.sink.Publish(recs[i])
+		switch {
+		case err == nil:
+			spoolReplayMeter.Mark(1)
+		case IsPermanent(err):
+			s.reject(recs[i], err)
+		case probe && i == 0 && len(recs) > 1:
+			if s.sink.Publish(recs[1]) != nil {
+				return cursor, err
+			}
+			spoolReplayMeter.Mark(1)
+			s.reject(recs[0], err)
+			i++
+		default:
+			return cursor, err
+		}
+		cursor = ends[i]
+	}
+	return cursor, nil
+}
+
+// readEntry reads a single spooled record from at most max bytes of input,
+// returning the number of bytes consumed. A clean end of input is reported as
+// io.EOF.
+func readEntry(r io.Reader, max int64) (Record, int64, error) {
+	var header [spoolEntryHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errSpoolCorrupt
+		}
+		return nil, 0, err
+	}
+	// Check the length before trusting it with an allocation
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	if length > max-spoolEntryHeader {
+		return nil, 0, errSpoolCorrupt
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, errSpoolCorrupt
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errSpoolCorrupt
+	}
+	if len(body) == 0 || len(body) < 1+int(body[0]) {
+		return nil, 0, errSpoolCorrupt
+	}
+	rec := &rawRecord{
+		channel: string(body[1 : 1+body[0]]),
+		data:    body[1+body[0]:],
+	}
+	return rec, int64(spoolEntryHeader + len(body)), nil
+}
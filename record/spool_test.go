@@ -0,0 +1,354 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errSinkDown = errors.New("sink down")
+
+// flakySink is a memory sink that can be switched off.
+type flakySink struct {
+	*MemorySink
+	lock sync.Mutex
+	down bool
+}
+
+func (s *flakySink) setDown(down bool) {
+	s.lock.Lock()
+	s.down = down
+	s.lock.Unlock()
+}
+
+func (s *flakySink) Publish(rec Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.down {
+		return errSinkDown
+	}
+	return s.MemorySink.Publish(rec)
+}
+
+// waitRecords waits until the sink received n records and returns their
+// transaction hashes.
+func waitRecords(t *testing.T, s *MemorySink, n int) []string {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if recs := s.Records(); len(recs) >= n {
+			hashes := make([]string, len(recs))
+			for i, rec := range recs {
+				var tx TxRecordInfo
+				data, _ := rec.Encode()
+				tx.Decode(data)
+				hashes[i] = tx.TxHash
+			}
+			return hashes
+		}
+	}
+	t.Fatalf("timeout waiting for %d records, have %d", n, len(s.Records()))
+	return nil
+}
+
+func publishTxs(t *testing.T, s Sink, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := s.Publish(&TxRecordInfo{TxHash: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkOrder(t *testing.T, hashes []string, n int) {
+	t.Helper()
+	if len(hashes) != n {
+		t.Fatalf("have %d records, want %d", len(hashes), n)
+	}
+	for i, hash := range hashes {
+		if hash != fmt.Sprint(i) {
+			t.Fatalf("record %d out of order: %s", i, hash)
+		}
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	defer func(old time.Duration) { spoolRetryBackoff = old }(spoolRetryBackoff)
+	spoolRetryBackoff = 10 * time.Millisecond
+
+	var (
+		dir   = t.TempDir()
+		inner = &flakySink{MemorySink: NewMemorySink()}
+	)
+	inner.setDown(true)
+	s, err := NewSpoolSink(inner, SpoolConfig{Dir: dir, SegmentSize: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// While the sink is down everything ends up on disk, spread over
+	// several segments.
+	publishTxs(t, s, 0, 20)
+	if n := len(inner.Records()); n != 0 {
+		t.Fatalf("have %d records while down", n)
+	}
+	if segs, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt)); len(segs) < 2 {
+		t.Fatalf("have %d segments, want several", len(segs))
+	}
+	// Once it recovers, the spool is replayed before any new record.
+	inner.setDown(false)
+	publishTxs(t, s, 20, 30)
+	checkOrder(t, waitRecords(t, inner.MemorySink, 30), 30)
+
+	// After draining, the segments are cleaned up.
+	s.Close()
+	if segs, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt)); len(segs) > 1 {
+		t.Fatalf("have %d segments left after replay", len(segs))
+	}
+}
+
+func TestSpoolResume(t *testing.T) {
+	defer func(old time.Duration) { spoolRetryBackoff = old }(spoolRetryBackoff)
+	spoolRetryBackoff = 10 * time.Millisecond
+
+	var (
+		dir   = t.TempDir()
+		inner = &flakySink{MemorySink: NewMemorySink()}
+	)
+	inner.setDown(true)
+	s, err := NewSpoolSink(inner, SpoolConfig{Dir: dir, SegmentSize: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishTxs(t, s, 0, 10)
+	s.Close()
+
+	// Simulate a torn write at the end of the last segment.
+	segs, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt))
+	f, err := os.OpenFile(segs[len(segs)-1], os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 0xde, 0xad})
+	f.Close()
+
+	// A new spool over the same directory replays the previous run.
+	inner = &flakySink{MemorySink: NewMemorySink()}
+	s, err = NewSpoolSink(inner, SpoolConfig{Dir: dir, SegmentSize: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishTxs(t, s, 10, 15)
+	checkOrder(t, waitRecords(t, inner.MemorySink, 15), 15)
+	s.Close()
+
+	blob, err := ioutil.ReadFile(filepath.Join(dir, spoolCursorFile))
+	if err != nil || len(blob) == 0 {
+		t.Fatalf("cursor not persisted: %v", err)
+	}
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		inner = &flakySink{MemorySink: NewMemorySink()}
+	)
+	inner.setDown(true)
+	s, err := NewSpoolSink(inner, SpoolConfig{Dir: dir, SegmentSize: 128, MaxSize: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	publishTxs(t, s, 0, 100)
+
+	spool := s.(*spoolSink)
+	spool.lock.Lock()
+	defer spool.lock.Unlock()
+
+	if spool.size > 512 {
+		t.Fatalf("spool size %d above cap", spool.size)
+	}
+	if spool.cursor.Segment != spool.head {
+		t.Fatalf("cursor %d not moved to head %d", spool.cursor.Segment, spool.head)
+	}
+}
+
+func TestSpoolMaxSizeDefaultSegments(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		inner = &flakySink{MemorySink: NewMemorySink()}
+	)
+	inner.setDown(true)
+	s, err := NewSpoolSink(inner, SpoolConfig{Dir: dir, MaxSize: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// A cap below the default segment size must hold as well.
+	publishTxs(t, s, 0, 100)
+
+	spool := s.(*spoolSink)
+	spool.lock.Lock()
+	defer spool.lock.Unlock()
+
+	if spool.cfg.SegmentSize != 128 {
+		t.Fatalf("wrong segment size %d", spool.cfg.SegmentSize)
+	}
+	if spool.size > 512 {
+		t.Fatalf("spool size %d above cap", spool.size)
+	}
+}
+
+func TestSpoolCorrupt(t *testing.T) {
+	defer func(old time.Duration) { spoolRetryBackoff = old }(spoolRetryBackoff)
+	spoolRetryBackoff = 10 * time.Millisecond
+
+	var (
+		dir   = t.TempDir()
+		inner = &flakySink{MemorySink: NewMemorySink()}
+	)
+	inner.setDown(true)
+	s, err := NewSpoolSink(inner, SpoolConfig{Dir: dir, SegmentSize: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishTxs(t, s, 0, 10)
+	s.Close()
+
+	// Find the entries of the first segment, which isn't the tail.
+	segs, _ := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt))
+	if len(segs) < 2 {
+		t.Fatalf("need several segments, have %d", len(segs))
+	}
+	blob, err := ioutil.ReadFile(segs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		sizes []int64
+		r     = bytes.NewReader(blob)
+	)
+	for {
+		_, n, err := readEntry(r, int64(r.Len()))
+		if err != nil {
+			break
+		}
+		sizes = append(sizes, n)
+	}
+	if len(sizes) < 2 {
+		t.Fatalf("need several entries in the first segment, have %d", len(sizes))
+	}
+	// Claim a huge length for its second entry.
+	binary.BigEndian.PutUint32(blob[sizes[0]:], 0xffffffff)
+	if err := ioutil.WriteFile(segs[0], blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying skips the rest of the segment instead of getting stuck.
+	inner = &flakySink{MemorySink: NewMemorySink()}
+	s, err = NewSpoolSink(inner, SpoolConfig{Dir: dir, SegmentSize: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishTxs(t, s, 10, 12)
+	lost := len(sizes) - 1
+	hashes := waitRecords(t, inner.MemorySink, 12-lost)
+	s.Close()
+
+	want := []string{"0"}
+	for i := len(sizes); i < 12; i++ {
+		want = append(want, fmt.Sprint(i))
+	}
+	if fmt.Sprint(hashes) != fmt.Sprint(want) {
+		t.Fatalf("wrong records %v, want %v", hashes, want)
+	}
+	info, err := os.Stat(filepath.Join(dir, spoolCorruptFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(blob))-sizes[0] {
+		t.Fatalf("wrong size of corrupt file %d, want %d", info.Size(), int64(len(blob))-sizes[0])
+	}
+}
+
+// pickySink is a flaky sink refusing some transactions, either permanently
+// or with an error that looks like an outage.
+type pickySink struct {
+	*flakySink
+	permanent, transient string
+}
+
+func (s *pickySink) Publish(rec Record) error {
+	var tx TxRecordInfo
+	data, _ := rec.Encode()
+	tx.Decode(data)
+	switch tx.TxHash {
+	case s.permanent:
+		return Permanent(errors.New("bad record"))
+	case s.transient:
+		return errSinkDown
+	}
+	return s.flakySink.Publish(rec)
+}
+
+func TestSpoolRejected(t *testing.T) {
+	defer func(old time.Duration) { spoolRetryBackoff = old }(spoolRetryBackoff)
+	spoolRetryBackoff = 10 * time.Millisecond
+
+	var (
+		dir   = t.TempDir()
+		inner = &pickySink{flakySink: &flakySink{MemorySink: NewMemorySink()}, permanent: "3", transient: "5"}
+	)
+	inner.setDown(true)
+	s, err := NewSpoolSink(inner, SpoolConfig{Dir: dir, SegmentSize: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishTxs(t, s, 0, 10)
+
+	// Once the sink is back, neither refused record holds up the others.
+	inner.setDown(false)
+	hashes := waitRecords(t, inner.MemorySink, 8)
+	want := []string{"0", "1", "2", "4", "6", "7", "8", "9"}
+	if fmt.Sprint(hashes) != fmt.Sprint(want) {
+		t.Fatalf("wrong records %v, want %v", hashes, want)
+	}
+	// Refused records published directly are set aside as well.
+	if err := s.Publish(&TxRecordInfo{TxHash: "3"}); !IsPermanent(err) {
+		t.Fatalf("wrong error %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != errSinkClosed {
+		t.Fatalf("wrong error on second close: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, spoolRejectedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var rejected []string
+	for {
+		rec, _, err := readEntry(f, 1<<30)
+		if err != nil {
+			break
+		}
+		var tx TxRecordInfo
+		data, _ := rec.Encode()
+		tx.Decode(data)
+		rejected = append(rejected, tx.TxHash)
+	}
+	if fmt.Sprint(rejected) != "[3 5 3]" {
+		t.Fatalf("wrong rejected records %v", rejected)
+	}
+}